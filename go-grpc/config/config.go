@@ -0,0 +1,85 @@
+// Package config loads optional settings files for both services.
+//
+// A config file is a flat YAML or JSON object whose keys are flag names,
+// limited to the settings in Config, e.g.
+//
+//	listen: ":8081"
+//	service-a: "127.0.0.1:50051"
+//	timeout: 2s
+//
+// Values from the file fill in any flag not given on the command line, so
+// flags always win over the file.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the settings file shared by both services: listen addresses,
+// timeouts, TLS paths, logging, tracing and the wire codec. A field the file
+// leaves out is nil. Each service reads the fields it has a flag for and
+// ignores the rest, e.g. A ignores service-a and B ignores admin-listen.
+type Config struct {
+	Listen        *string `yaml:"listen"`
+	MetricsListen *string `yaml:"metrics-listen"`
+	AdminListen   *string `yaml:"admin-listen"`
+	ServiceA      *string `yaml:"service-a"`
+
+	Timeout         *time.Duration `yaml:"timeout"`
+	ServerTimeout   *time.Duration `yaml:"server-timeout"`
+	ShutdownTimeout *time.Duration `yaml:"shutdown-timeout"`
+
+	TLSCert  *string `yaml:"tls-cert"`
+	TLSKey   *string `yaml:"tls-key"`
+	TLSCA    *string `yaml:"tls-ca"`
+	ClientCA *string `yaml:"client-ca"`
+
+	LogFormat    *string `yaml:"log-format"`
+	LogLevel     *string `yaml:"log-level"`
+	OTLPEndpoint *string `yaml:"otlp-endpoint"`
+	Codec        *string `yaml:"codec"`
+}
+
+// Load reads the config file at path. YAML and JSON files are both read by
+// the YAML decoder, since JSON is valid YAML. Unknown keys and values of the
+// wrong type are errors, so typos in the file don't go unnoticed.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read config file: %w", err)
+	}
+
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Use copies the file's value v into dst, unless the file left it out (v is
+// nil) or the flag called name was given on the command line. Call it after
+// fs.Parse for each setting the service takes from the file. name must be a
+// flag defined in fs.
+func Use[T any](fs *flag.FlagSet, name string, dst, v *T) {
+	if fs.Lookup(name) == nil {
+		panic("config: no flag named " + name)
+	}
+	if v == nil {
+		return
+	}
+	given := false
+	fs.Visit(func(f *flag.Flag) { given = given || f.Name == name })
+	if !given {
+		*dst = *v
+	}
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfig writes content to a file called name in a temp dir and
+// returns its path.
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testFlags returns a flag set like service B's, with pointers to its
+// values.
+func testFlags() (*flag.FlagSet, *string, *time.Duration) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	listen := fs.String("listen", ":8081", "")
+	timeout := fs.Duration("timeout", time.Second, "")
+	return fs, listen, timeout
+}
+
+// use applies cfg's listen and timeout to fs as service B does.
+func use(fs *flag.FlagSet, listen *string, timeout *time.Duration, cfg Config) {
+	Use(fs, "listen", listen, cfg.Listen)
+	Use(fs, "timeout", timeout, cfg.Timeout)
+}
+
+func TestFileOnly(t *testing.T) {
+	for name, content := range map[string]string{
+		"b.yaml": "listen: \":9000\"\ntimeout: 3s\n",
+		"b.json": `{"listen": ":9000", "timeout": "3s"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, name, content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fs, listen, timeout := testFlags()
+			if err := fs.Parse(nil); err != nil {
+				t.Fatal(err)
+			}
+			use(fs, listen, timeout, cfg)
+			if *listen != ":9000" || *timeout != 3*time.Second {
+				t.Errorf("listen, timeout = %q, %s; want the file's :9000, 3s", *listen, *timeout)
+			}
+		})
+	}
+}
+
+func TestFlagOverridesFile(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "b.yaml", "listen: \":9000\"\ntimeout: 3s\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, listen, timeout := testFlags()
+	if err := fs.Parse([]string{"-listen", ":7000"}); err != nil {
+		t.Fatal(err)
+	}
+	use(fs, listen, timeout, cfg)
+	if *listen != ":7000" {
+		t.Errorf("listen = %q, want the flag's :7000", *listen)
+	}
+	if *timeout != 3*time.Second {
+		t.Errorf("timeout = %s, want the file's 3s", *timeout)
+	}
+}
+
+func TestMissingSettingKeepsDefault(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "b.yaml", "timeout: 3s\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen != nil {
+		t.Errorf("Listen = %q, want nil for a setting the file leaves out", *cfg.Listen)
+	}
+	fs, listen, timeout := testFlags()
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	use(fs, listen, timeout, cfg)
+	if *listen != ":8081" {
+		t.Errorf("listen = %q, want the default :8081", *listen)
+	}
+}
+
+func TestEmptyFile(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "empty.yaml", ""))
+	if err != nil {
+		t.Fatalf("Load of an empty file: %v", err)
+	}
+	if cfg != (Config{}) {
+		t.Errorf("cfg = %+v, want nothing set", cfg)
+	}
+}
+
+func TestBadFiles(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load accepted a missing file")
+	}
+	for name, content := range map[string]string{
+		"malformed.yaml": "listen: [unclosed\n",
+		"malformed.json": `{"listen": `,
+		"nested.yaml":    "listen:\n  addr: a\n",
+		"unknown.yaml":   "lisen: \":9000\"\n",
+		"duration.yaml":  "timeout: soon\n",
+		"bare-int.yaml":  "timeout: 3\n",
+	} {
+		if _, err := Load(writeConfig(t, name, content)); err == nil {
+			t.Errorf("Load accepted %s", name)
+		}
+	}
+}
+
+func TestUseUnknownFlagPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Use of an undefined flag didn't panic")
+		}
+	}()
+	fs, listen, _ := testFlags()
+	v := ":9000"
+	Use(fs, "lisen", listen, &v)
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Environment variables supply flag defaults, named after the service and
+// flag: SERVICE_A_LISTEN for service A's -listen, SERVICE_B_SERVICE_A for
+// service B's -service-a, and so on. They are read before flag.Parse, so the
+// overall precedence, highest first, is
+//
+//	command-line flag > -config file > environment variable > built-in default
+//
+// i.e. the environment only changes what a flag defaults to.
+
+// EnvOr returns the value of the environment variable key, or fallback if it
+// is unset or empty.
+func EnvOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// EnvDurationOr is EnvOr for time.Duration flags. An unparsable value is a
+// configuration error and exits, like an invalid flag would.
+func EnvDurationOr(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("invalid duration %q for %s: %v", v, key, err)
+	}
+	return d
+}
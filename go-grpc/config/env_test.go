@@ -0,0 +1,65 @@
+package config
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestEnvOr(t *testing.T) {
+	t.Setenv("SERVICE_B_SERVICE_A", "a.internal:50051")
+	t.Setenv("SERVICE_B_EMPTY", "")
+	if got := EnvOr("SERVICE_B_SERVICE_A", "127.0.0.1:50051"); got != "a.internal:50051" {
+		t.Errorf("set: EnvOr = %q, want the environment's value", got)
+	}
+	if got := EnvOr("SERVICE_B_EMPTY", "fallback"); got != "fallback" {
+		t.Errorf("empty: EnvOr = %q, want the fallback", got)
+	}
+	if got := EnvOr("SERVICE_B_UNSET_FOR_TEST", "fallback"); got != "fallback" {
+		t.Errorf("unset: EnvOr = %q, want the fallback", got)
+	}
+}
+
+func TestEnvDurationOr(t *testing.T) {
+	t.Setenv("SERVICE_B_TIMEOUT", "750ms")
+	if got := EnvDurationOr("SERVICE_B_TIMEOUT", time.Second); got != 750*time.Millisecond {
+		t.Errorf("EnvDurationOr = %s, want 750ms", got)
+	}
+	if got := EnvDurationOr("SERVICE_B_UNSET_FOR_TEST", time.Second); got != time.Second {
+		t.Errorf("unset: EnvDurationOr = %s, want the 1s fallback", got)
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	t.Setenv("SERVICE_A_LISTEN", ":6000")
+	t.Setenv("SERVICE_A_TIMEOUT", "4s")
+	t.Setenv("SERVICE_A_METRICS_LISTEN", ":6001")
+
+	// Each flag takes its default from the environment, as in main.
+	parse := func(args []string, cfg Config) (listen, metrics string, timeout time.Duration) {
+		t.Helper()
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.StringVar(&listen, "listen", EnvOr("SERVICE_A_LISTEN", ":50051"), "")
+		fs.StringVar(&metrics, "metrics-listen", EnvOr("SERVICE_A_METRICS_LISTEN", ""), "")
+		fs.DurationVar(&timeout, "timeout", EnvDurationOr("SERVICE_A_TIMEOUT", time.Second), "")
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		Use(fs, "listen", &listen, cfg.Listen)
+		Use(fs, "metrics-listen", &metrics, cfg.MetricsListen)
+		Use(fs, "timeout", &timeout, cfg.Timeout)
+		return listen, metrics, timeout
+	}
+
+	fileListen, fileTimeout := ":8000", 2*time.Second
+	listen, metrics, timeout := parse([]string{"-listen", ":7000"}, Config{Listen: &fileListen, Timeout: &fileTimeout})
+	if listen != ":7000" {
+		t.Errorf("listen = %q, want the flag to beat file and environment", listen)
+	}
+	if timeout != 2*time.Second {
+		t.Errorf("timeout = %s, want the file to beat the environment", timeout)
+	}
+	if metrics != ":6001" {
+		t.Errorf("metrics = %q, want the environment to beat the built-in default", metrics)
+	}
+}
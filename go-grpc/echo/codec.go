@@ -0,0 +1,146 @@
+package echo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/proto"
+)
+
+// --------------------
+// gRPC codecs (so you don't need protoc)
+// --------------------
+
+// Content-subtype names the codecs are registered under. A client selects one
+// with grpc.CallContentSubtype; the server picks the matching codec from the
+// request's content-type header.
+const (
+	JSONCodecName  = "json"
+	ProtoCodecName = "proto"
+)
+
+// jsonEncoder is a buffer with a json.Encoder writing into it, pooled so
+// Marshal doesn't set both up per message. Buffers grown past
+// maxPooledJSONBuffer by an unusually large message are dropped rather than
+// kept alive by the pool.
+type jsonEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+const maxPooledJSONBuffer = 64 << 10
+
+var jsonEncoders = sync.Pool{New: func() any {
+	e := new(jsonEncoder)
+	e.enc = json.NewEncoder(&e.buf)
+	return e
+}}
+
+// jsonCodec marshals through a pooled jsonEncoder and copies the result out,
+// so the buffer can go back to the pool. json.Decoder can't be reset onto
+// new input, so Unmarshal calls json.Unmarshal directly. See
+// BenchmarkJSONCodecMarshal.
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return JSONCodecName }
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	e := jsonEncoders.Get().(*jsonEncoder)
+	defer func() {
+		if e.buf.Cap() <= maxPooledJSONBuffer {
+			jsonEncoders.Put(e)
+		}
+	}()
+	e.buf.Reset()
+	if err := e.enc.Encode(v); err != nil {
+		// grpc turns this into a bare Internal status; log the cause.
+		log.Printf("json codec: marshal %T: %v", v, err)
+		return nil, fmt.Errorf("json codec: %w", err)
+	}
+	// Encode ends every value with a newline json.Marshal doesn't add.
+	return bytes.Clone(bytes.TrimSuffix(e.buf.Bytes(), []byte("\n"))), nil
+}
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// protoCodec speaks the protobuf wire format. The message types in this
+// package are plain structs, so they are encoded from their `proto` struct
+// tags; real proto.Message values (e.g. those used by grpc's own services)
+// are passed through to the protobuf runtime so replacing grpc's default
+// "proto" codec doesn't break them.
+type protoCodec struct{}
+
+func (protoCodec) Name() string { return ProtoCodecName }
+func (protoCodec) Marshal(v any) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Marshal(m)
+	}
+	b, err := marshalWire(v)
+	if err != nil {
+		return nil, fmt.Errorf("proto codec: %w", err)
+	}
+	return b, nil
+}
+func (protoCodec) Unmarshal(data []byte, v any) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+	if err := unmarshalWire(data, v); err != nil {
+		return fmt.Errorf("proto codec: %w", err)
+	}
+	return nil
+}
+
+// ValidateCodec checks that name, as given to either service's -codec, is a
+// registered grpc codec. Without it, an unknown name only surfaces on the
+// first call, as an Internal "no codec registered for content-subtype"
+// error. grpc matches content-subtypes in lower case, so name must be too.
+func ValidateCodec(name string) error {
+	if encoding.GetCodec(strings.ToLower(name)) == nil {
+		return fmt.Errorf("no codec registered as %q (want %s or %s)", name, JSONCodecName, ProtoCodecName)
+	}
+	return nil
+}
+
+// EncodedSize returns the length of v encoded by the JSON codec, or 0 if v is
+// nil or can't be encoded. It is what the services log as a message's size;
+// the proto encoding of the same message is usually smaller.
+func EncodedSize(v any) int {
+	if v == nil {
+		return 0
+	}
+	b, err := jsonCodec{}.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// RegisterCodecs registers the JSON and proto codecs with grpc, as both
+// services' mains do through MustRegisterJSONCodec and RegisterProtoCodec.
+// The package has no init side effects: importing it for the message types
+// leaves grpc's codec registry, including its default "proto" codec,
+// untouched.
+func RegisterCodecs() {
+	MustRegisterJSONCodec()
+	RegisterProtoCodec()
+}
+
+// MustRegisterJSONCodec registers the JSON codec under JSONCodecName. Like
+// encoding.RegisterCodec it panics rather than fail, so a bad registration
+// stops the service at startup instead of failing every call that selects
+// the codec.
+func MustRegisterJSONCodec() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// RegisterProtoCodec replaces grpc's default "proto" codec with protoCodec,
+// which also encodes this package's plain message structs.
+func RegisterProtoCodec() {
+	encoding.RegisterCodec(protoCodec{})
+}
@@ -0,0 +1,159 @@
+package echo
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// jsonRegisteredOnImport records whether grpc knew a "json" codec before
+// any test ran, i.e. from importing the package alone.
+var jsonRegisteredOnImport bool
+
+func TestMain(m *testing.M) {
+	jsonRegisteredOnImport = encoding.GetCodec(JSONCodecName) != nil
+	os.Exit(m.Run())
+}
+
+func TestImportDoesNotRegisterCodecs(t *testing.T) {
+	if jsonRegisteredOnImport {
+		t.Fatal(`importing echo registered the "json" codec`)
+	}
+}
+
+func TestMustRegisterJSONCodec(t *testing.T) {
+	MustRegisterJSONCodec()
+	if _, ok := encoding.GetCodec(JSONCodecName).(jsonCodec); !ok {
+		t.Errorf("codec for %q after MustRegisterJSONCodec is %T, want jsonCodec", JSONCodecName, encoding.GetCodec(JSONCodecName))
+	}
+}
+
+func TestRegisterCodecs(t *testing.T) {
+	RegisterCodecs()
+	for _, name := range []string{JSONCodecName, ProtoCodecName} {
+		c := encoding.GetCodec(name)
+		if c == nil {
+			t.Fatalf("no %q codec after RegisterCodecs", name)
+		}
+		if c.Name() != name {
+			t.Errorf("codec for %q is named %q", name, c.Name())
+		}
+	}
+	if _, ok := encoding.GetCodec(ProtoCodecName).(protoCodec); !ok {
+		t.Error(`RegisterCodecs didn't replace grpc's default "proto" codec`)
+	}
+}
+
+func TestValidateCodec(t *testing.T) {
+	RegisterCodecs()
+	for _, name := range []string{JSONCodecName, ProtoCodecName, "JSON"} {
+		if err := ValidateCodec(name); err != nil {
+			t.Errorf("ValidateCodec(%q) = %v, want nil", name, err)
+		}
+	}
+	err := ValidateCodec("msgpack")
+	if err == nil || !strings.Contains(err.Error(), `"msgpack"`) {
+		t.Errorf(`ValidateCodec("msgpack") = %v, want an error naming it`, err)
+	}
+}
+
+func TestCodecsRoundTripEchoRequest(t *testing.T) {
+	codecs := []encoding.Codec{jsonCodec{}, protoCodec{}}
+	reqs := []*EchoRequest{
+		{Msg: "hello"},
+		{Msg: "héllo, 世界", Transform: "upper"},
+		{},
+	}
+	for _, c := range codecs {
+		for _, want := range reqs {
+			b, err := c.Marshal(want)
+			if err != nil {
+				t.Fatalf("%s: Marshal(%+v): %v", c.Name(), want, err)
+			}
+			got := new(EchoRequest)
+			if err := c.Unmarshal(b, got); err != nil {
+				t.Fatalf("%s: Unmarshal(%q): %v", c.Name(), b, err)
+			}
+			if *got != *want {
+				t.Errorf("%s: round trip = %+v, want %+v", c.Name(), got, want)
+			}
+		}
+	}
+}
+
+func TestProtoCodecRoundTripRepeatedField(t *testing.T) {
+	want := &BatchEchoRequest{Msgs: []string{"a", "", "c"}}
+	b, err := protoCodec{}.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	got := new(BatchEchoRequest)
+	if err := (protoCodec{}).Unmarshal(b, got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestProtoCodecRejectsMalformedInput(t *testing.T) {
+	// Field 1, bytes type, claiming 5 bytes with only 2 present.
+	err := protoCodec{}.Unmarshal([]byte{0x0a, 0x05, 'h', 'i'}, new(EchoRequest))
+	if err == nil {
+		t.Fatal("Unmarshal of truncated input succeeded")
+	}
+}
+
+func TestJSONCodecMarshalMatchesEncodingJSON(t *testing.T) {
+	for _, v := range []any{
+		&EchoRequest{Msg: "<b>&</b>", Transform: "upper"},
+		&BatchEchoRequest{Msgs: []string{"a", "b"}},
+		&EchoResponse{},
+	} {
+		got, err := jsonCodec{}.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal(%+v): %v", v, err)
+		}
+		want, _ := json.Marshal(v)
+		if !bytes.Equal(got, want) {
+			t.Errorf("Marshal(%+v) = %s, want %s", v, got, want)
+		}
+	}
+}
+
+func TestJSONCodecMarshalResultOutlivesPool(t *testing.T) {
+	first, _ := jsonCodec{}.Marshal(&EchoRequest{Msg: "first"})
+	jsonCodec{}.Marshal(&EchoRequest{Msg: "second, and longer"})
+	if want := `{"msg":"first"}`; string(first) != want {
+		t.Errorf("first result = %s after another Marshal, want %s", first, want)
+	}
+}
+
+func TestJSONCodecMarshalErrorIsLogged(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	if _, err := (jsonCodec{}).Marshal(map[string]any{"ch": make(chan int)}); err == nil {
+		t.Fatal("Marshal of a channel succeeded")
+	}
+	if !strings.Contains(out.String(), "json codec: marshal map[string]interface {}") {
+		t.Errorf("log = %q, want the failed marshal logged with its type", out.String())
+	}
+}
+
+func BenchmarkJSONCodecMarshal(b *testing.B) {
+	req := &EchoRequest{Msg: "hello, benchmark", Transform: "upper"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := (jsonCodec{}).Marshal(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
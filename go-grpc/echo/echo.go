@@ -0,0 +1,651 @@
+// Package echo holds the EchoService contract shared by service A and
+// service B: message types, codecs, and the hand-written equivalents of the
+// server and client stubs protoc would generate.
+package echo
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+)
+
+// --------------------
+// "Proto" message types (plain structs)
+// --------------------
+//
+// The json tags drive the JSON codec; the proto tags give each field the
+// number it would have in echo.proto, for the proto codec.
+
+type EchoRequest struct {
+	Msg string `json:"msg" proto:"1"`
+	// Transform is applied by Echo: "none" (or empty), "upper", "lower" or
+	// "title". Other methods taking an EchoRequest ignore it.
+	Transform string `json:"transform,omitempty" proto:"2"`
+}
+
+// UnmarshalJSON also accepts the message as "message", which some clients
+// send instead of "msg". When both are present "msg" wins. Marshaling always
+// writes "msg".
+func (r *EchoRequest) UnmarshalJSON(data []byte) error {
+	// plain has EchoRequest's fields but not this method, so decoding into
+	// it doesn't recurse. The outer Msg shadows plain's so "msg" and
+	// "message" can each be told apart from absent.
+	type plain EchoRequest
+	var v struct {
+		plain
+		Msg     *string `json:"msg"`
+		Message *string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*r = EchoRequest(v.plain)
+	switch {
+	case v.Msg != nil:
+		r.Msg = *v.Msg
+	case v.Message != nil:
+		r.Msg = *v.Message
+	}
+	return nil
+}
+
+type EchoResponse struct {
+	Echo string `json:"echo" proto:"1"`
+}
+
+type BatchEchoRequest struct {
+	Msgs []string `json:"msgs" proto:"1"`
+}
+
+type BatchEchoResponse struct {
+	Echoes []string `json:"echoes" proto:"1"`
+}
+
+type RepeatEchoRequest struct {
+	Msg   string `json:"msg" proto:"1"`
+	Count int32  `json:"count" proto:"2"`
+}
+
+// ClientStreamEchoResponse is ClientStreamEcho's single reply: the
+// messages the client sent, concatenated in order, and how many there were.
+type ClientStreamEchoResponse struct {
+	Echo  string `json:"echo" proto:"1"`
+	Count int32  `json:"count" proto:"2"`
+}
+
+// EchoChunkRequest asks for the runes [Offset, Offset+Limit) of Msg. A Limit
+// of 0 means "to the end".
+type EchoChunkRequest struct {
+	Msg    string `json:"msg" proto:"1"`
+	Offset int32  `json:"offset" proto:"2"`
+	Limit  int32  `json:"limit" proto:"3"`
+}
+
+type EchoChunkResponse struct {
+	Chunk string `json:"chunk" proto:"1"`
+	// Total is the length of Msg in runes, for paging through it.
+	Total int32 `json:"total" proto:"2"`
+}
+
+// PingRequest and PongResponse carry wall-clock timestamps (Unix
+// nanoseconds) so the caller can estimate round-trip time and clock skew.
+type PingRequest struct {
+	SentAtUnixNano int64 `json:"sent_at_unix_nano" proto:"1"`
+}
+
+type PongResponse struct {
+	ReceivedAtUnixNano int64 `json:"received_at_unix_nano" proto:"1"`
+	SentAtUnixNano     int64 `json:"sent_at_unix_nano" proto:"2"`
+}
+
+// HashRequest asks for the digest of Msg. Algorithm is "sha256" (the
+// default when empty) or "sha512".
+type HashRequest struct {
+	Msg       string `json:"msg" proto:"1"`
+	Algorithm string `json:"algorithm,omitempty" proto:"2"`
+}
+
+type HashResponse struct {
+	// Digest is the lower-case hex digest of Msg.
+	Digest    string `json:"digest" proto:"1"`
+	Algorithm string `json:"algorithm" proto:"2"`
+}
+
+type StatsRequest struct{}
+
+// StatsResponse counts the Echo calls service A has answered successfully,
+// and the bytes of msg they carried, since it started or its stats were
+// last reset.
+type StatsResponse struct {
+	EchoCalls int64 `json:"echo_calls" proto:"1"`
+	EchoBytes int64 `json:"echo_bytes" proto:"2"`
+}
+
+type VersionRequest struct{}
+
+type VersionResponse struct {
+	Version   string `json:"version" proto:"1"`
+	Commit    string `json:"commit" proto:"2"`
+	BuildDate string `json:"build_date" proto:"3"`
+}
+
+type HealthRequest struct{}
+
+type HealthResponse struct {
+	Status string `json:"status" proto:"1"`
+}
+
+// --------------------
+// Manual service definition (similar to generated pb.go)
+// --------------------
+
+// ServiceName is the fully-qualified gRPC service name shared by both services.
+const ServiceName = "echo.EchoService"
+
+// RequestIDMetadataKey is the gRPC metadata key service B uses to pass its
+// X-Request-Id to service A.
+const RequestIDMetadataKey = "x-request-id"
+
+// APIKeyMetadataKey is the gRPC metadata key carrying service B's API key.
+const APIKeyMetadataKey = "x-api-key"
+
+// SchemaVersionMetadataKey is the gRPC metadata key on which callers state
+// the version of the EchoService message schema they were built against.
+const SchemaVersionMetadataKey = "schema-version"
+
+// SchemaVersion is the message schema version of this package. Bump it when
+// a change to the message types would be misread by an older peer.
+const SchemaVersion = 1
+
+type EchoServiceServer interface {
+	Echo(context.Context, *EchoRequest) (*EchoResponse, error)
+	Health(context.Context, *HealthRequest) (*HealthResponse, error)
+	EchoStream(EchoService_EchoStreamServer) error
+	ReverseEcho(context.Context, *EchoRequest) (*EchoResponse, error)
+	BatchEcho(context.Context, *BatchEchoRequest) (*BatchEchoResponse, error)
+	RepeatEcho(*RepeatEchoRequest, EchoService_RepeatEchoServer) error
+	ClientStreamEcho(EchoService_ClientStreamEchoServer) error
+	GetVersion(context.Context, *VersionRequest) (*VersionResponse, error)
+	EchoChunk(context.Context, *EchoChunkRequest) (*EchoChunkResponse, error)
+	Ping(context.Context, *PingRequest) (*PongResponse, error)
+	Hash(context.Context, *HashRequest) (*HashResponse, error)
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+}
+
+func RegisterEchoServiceServer(s *grpc.Server, srv EchoServiceServer) {
+	s.RegisterService(&EchoService_ServiceDesc, srv)
+}
+
+func _EchoService_Echo_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(EchoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	baseHandler := func(ctx context.Context, req any) (any, error) {
+		return srv.(EchoServiceServer).Echo(ctx, req.(*EchoRequest))
+	}
+	if interceptor == nil {
+		return baseHandler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ServiceName + "/Echo",
+	}
+	return interceptor(ctx, in, info, baseHandler)
+}
+
+func _EchoService_Health_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(HealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	baseHandler := func(ctx context.Context, req any) (any, error) {
+		return srv.(EchoServiceServer).Health(ctx, req.(*HealthRequest))
+	}
+	if interceptor == nil {
+		return baseHandler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ServiceName + "/Health",
+	}
+	return interceptor(ctx, in, info, baseHandler)
+}
+
+func _EchoService_ReverseEcho_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(EchoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	baseHandler := func(ctx context.Context, req any) (any, error) {
+		return srv.(EchoServiceServer).ReverseEcho(ctx, req.(*EchoRequest))
+	}
+	if interceptor == nil {
+		return baseHandler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ServiceName + "/ReverseEcho",
+	}
+	return interceptor(ctx, in, info, baseHandler)
+}
+
+func _EchoService_BatchEcho_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(BatchEchoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	baseHandler := func(ctx context.Context, req any) (any, error) {
+		return srv.(EchoServiceServer).BatchEcho(ctx, req.(*BatchEchoRequest))
+	}
+	if interceptor == nil {
+		return baseHandler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ServiceName + "/BatchEcho",
+	}
+	return interceptor(ctx, in, info, baseHandler)
+}
+
+func _EchoService_GetVersion_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(VersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	baseHandler := func(ctx context.Context, req any) (any, error) {
+		return srv.(EchoServiceServer).GetVersion(ctx, req.(*VersionRequest))
+	}
+	if interceptor == nil {
+		return baseHandler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ServiceName + "/GetVersion",
+	}
+	return interceptor(ctx, in, info, baseHandler)
+}
+
+func _EchoService_EchoChunk_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(EchoChunkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	baseHandler := func(ctx context.Context, req any) (any, error) {
+		return srv.(EchoServiceServer).EchoChunk(ctx, req.(*EchoChunkRequest))
+	}
+	if interceptor == nil {
+		return baseHandler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ServiceName + "/EchoChunk",
+	}
+	return interceptor(ctx, in, info, baseHandler)
+}
+
+func _EchoService_Ping_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(PingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	baseHandler := func(ctx context.Context, req any) (any, error) {
+		return srv.(EchoServiceServer).Ping(ctx, req.(*PingRequest))
+	}
+	if interceptor == nil {
+		return baseHandler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ServiceName + "/Ping",
+	}
+	return interceptor(ctx, in, info, baseHandler)
+}
+
+func _EchoService_Hash_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(HashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	baseHandler := func(ctx context.Context, req any) (any, error) {
+		return srv.(EchoServiceServer).Hash(ctx, req.(*HashRequest))
+	}
+	if interceptor == nil {
+		return baseHandler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ServiceName + "/Hash",
+	}
+	return interceptor(ctx, in, info, baseHandler)
+}
+
+func _EchoService_Stats_Handler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	baseHandler := func(ctx context.Context, req any) (any, error) {
+		return srv.(EchoServiceServer).Stats(ctx, req.(*StatsRequest))
+	}
+	if interceptor == nil {
+		return baseHandler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ServiceName + "/Stats",
+	}
+	return interceptor(ctx, in, info, baseHandler)
+}
+
+func _EchoService_EchoStream_Handler(srv any, stream grpc.ServerStream) error {
+	return srv.(EchoServiceServer).EchoStream(&echoServiceEchoStreamServer{stream})
+}
+
+type EchoService_EchoStreamServer interface {
+	Send(*EchoResponse) error
+	Recv() (*EchoRequest, error)
+	grpc.ServerStream
+}
+
+type echoServiceEchoStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *echoServiceEchoStreamServer) Send(m *EchoResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *echoServiceEchoStreamServer) Recv() (*EchoRequest, error) {
+	m := new(EchoRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _EchoService_RepeatEcho_Handler(srv any, stream grpc.ServerStream) error {
+	m := new(RepeatEchoRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EchoServiceServer).RepeatEcho(m, &echoServiceRepeatEchoServer{stream})
+}
+
+type EchoService_RepeatEchoServer interface {
+	Send(*EchoResponse) error
+	grpc.ServerStream
+}
+
+type echoServiceRepeatEchoServer struct {
+	grpc.ServerStream
+}
+
+func (x *echoServiceRepeatEchoServer) Send(m *EchoResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _EchoService_ClientStreamEcho_Handler(srv any, stream grpc.ServerStream) error {
+	return srv.(EchoServiceServer).ClientStreamEcho(&echoServiceClientStreamEchoServer{stream})
+}
+
+type EchoService_ClientStreamEchoServer interface {
+	SendAndClose(*ClientStreamEchoResponse) error
+	Recv() (*EchoRequest, error)
+	grpc.ServerStream
+}
+
+type echoServiceClientStreamEchoServer struct {
+	grpc.ServerStream
+}
+
+func (x *echoServiceClientStreamEchoServer) SendAndClose(m *ClientStreamEchoResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *echoServiceClientStreamEchoServer) Recv() (*EchoRequest, error) {
+	m := new(EchoRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var EchoService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*EchoServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Echo", Handler: _EchoService_Echo_Handler},
+		{MethodName: "Health", Handler: _EchoService_Health_Handler},
+		{MethodName: "ReverseEcho", Handler: _EchoService_ReverseEcho_Handler},
+		{MethodName: "BatchEcho", Handler: _EchoService_BatchEcho_Handler},
+		{MethodName: "GetVersion", Handler: _EchoService_GetVersion_Handler},
+		{MethodName: "EchoChunk", Handler: _EchoService_EchoChunk_Handler},
+		{MethodName: "Ping", Handler: _EchoService_Ping_Handler},
+		{MethodName: "Hash", Handler: _EchoService_Hash_Handler},
+		{MethodName: "Stats", Handler: _EchoService_Stats_Handler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "EchoStream",
+			Handler:       _EchoService_EchoStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "RepeatEcho",
+			Handler:       _EchoService_RepeatEcho_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ClientStreamEcho",
+			Handler:       _EchoService_ClientStreamEcho_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "echo.proto",
+}
+
+// --------------------
+// Manual client stub (similar to generated pb.go)
+// --------------------
+
+type EchoServiceClient interface {
+	Echo(ctx context.Context, in *EchoRequest, opts ...grpc.CallOption) (*EchoResponse, error)
+	Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
+	EchoStream(ctx context.Context, opts ...grpc.CallOption) (EchoService_EchoStreamClient, error)
+	ReverseEcho(ctx context.Context, in *EchoRequest, opts ...grpc.CallOption) (*EchoResponse, error)
+	BatchEcho(ctx context.Context, in *BatchEchoRequest, opts ...grpc.CallOption) (*BatchEchoResponse, error)
+	RepeatEcho(ctx context.Context, in *RepeatEchoRequest, opts ...grpc.CallOption) (EchoService_RepeatEchoClient, error)
+	ClientStreamEcho(ctx context.Context, opts ...grpc.CallOption) (EchoService_ClientStreamEchoClient, error)
+	GetVersion(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error)
+	EchoChunk(ctx context.Context, in *EchoChunkRequest, opts ...grpc.CallOption) (*EchoChunkResponse, error)
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PongResponse, error)
+	Hash(ctx context.Context, in *HashRequest, opts ...grpc.CallOption) (*HashResponse, error)
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+}
+
+type echoServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEchoServiceClient(cc grpc.ClientConnInterface) EchoServiceClient {
+	return &echoServiceClient{cc: cc}
+}
+
+func (c *echoServiceClient) Echo(ctx context.Context, in *EchoRequest, opts ...grpc.CallOption) (*EchoResponse, error) {
+	out := new(EchoResponse)
+	err := c.cc.Invoke(ctx, "/"+ServiceName+"/Echo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *echoServiceClient) Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error) {
+	out := new(HealthResponse)
+	err := c.cc.Invoke(ctx, "/"+ServiceName+"/Health", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *echoServiceClient) ReverseEcho(ctx context.Context, in *EchoRequest, opts ...grpc.CallOption) (*EchoResponse, error) {
+	out := new(EchoResponse)
+	err := c.cc.Invoke(ctx, "/"+ServiceName+"/ReverseEcho", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *echoServiceClient) BatchEcho(ctx context.Context, in *BatchEchoRequest, opts ...grpc.CallOption) (*BatchEchoResponse, error) {
+	out := new(BatchEchoResponse)
+	err := c.cc.Invoke(ctx, "/"+ServiceName+"/BatchEcho", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *echoServiceClient) GetVersion(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error) {
+	out := new(VersionResponse)
+	err := c.cc.Invoke(ctx, "/"+ServiceName+"/GetVersion", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *echoServiceClient) EchoChunk(ctx context.Context, in *EchoChunkRequest, opts ...grpc.CallOption) (*EchoChunkResponse, error) {
+	out := new(EchoChunkResponse)
+	err := c.cc.Invoke(ctx, "/"+ServiceName+"/EchoChunk", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *echoServiceClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PongResponse, error) {
+	out := new(PongResponse)
+	err := c.cc.Invoke(ctx, "/"+ServiceName+"/Ping", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *echoServiceClient) Hash(ctx context.Context, in *HashRequest, opts ...grpc.CallOption) (*HashResponse, error) {
+	out := new(HashResponse)
+	err := c.cc.Invoke(ctx, "/"+ServiceName+"/Hash", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *echoServiceClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, "/"+ServiceName+"/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *echoServiceClient) EchoStream(ctx context.Context, opts ...grpc.CallOption) (EchoService_EchoStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EchoService_ServiceDesc.Streams[0], "/"+ServiceName+"/EchoStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &echoServiceEchoStreamClient{stream}, nil
+}
+
+type EchoService_EchoStreamClient interface {
+	Send(*EchoRequest) error
+	Recv() (*EchoResponse, error)
+	grpc.ClientStream
+}
+
+type echoServiceEchoStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *echoServiceEchoStreamClient) Send(m *EchoRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *echoServiceEchoStreamClient) Recv() (*EchoResponse, error) {
+	m := new(EchoResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *echoServiceClient) RepeatEcho(ctx context.Context, in *RepeatEchoRequest, opts ...grpc.CallOption) (EchoService_RepeatEchoClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EchoService_ServiceDesc.Streams[1], "/"+ServiceName+"/RepeatEcho", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &echoServiceRepeatEchoClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type EchoService_RepeatEchoClient interface {
+	Recv() (*EchoResponse, error)
+	grpc.ClientStream
+}
+
+type echoServiceRepeatEchoClient struct {
+	grpc.ClientStream
+}
+
+func (x *echoServiceRepeatEchoClient) Recv() (*EchoResponse, error) {
+	m := new(EchoResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *echoServiceClient) ClientStreamEcho(ctx context.Context, opts ...grpc.CallOption) (EchoService_ClientStreamEchoClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EchoService_ServiceDesc.Streams[2], "/"+ServiceName+"/ClientStreamEcho", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &echoServiceClientStreamEchoClient{stream}, nil
+}
+
+type EchoService_ClientStreamEchoClient interface {
+	Send(*EchoRequest) error
+	CloseAndRecv() (*ClientStreamEchoResponse, error)
+	grpc.ClientStream
+}
+
+type echoServiceClientStreamEchoClient struct {
+	grpc.ClientStream
+}
+
+func (x *echoServiceClientStreamEchoClient) Send(m *EchoRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *echoServiceClientStreamEchoClient) CloseAndRecv() (*ClientStreamEchoResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ClientStreamEchoResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
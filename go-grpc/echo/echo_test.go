@@ -0,0 +1,49 @@
+package echo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEchoRequestUnmarshalBothSpellings(t *testing.T) {
+	tests := []struct {
+		in   string
+		want EchoRequest
+	}{
+		{`{"msg":"hi"}`, EchoRequest{Msg: "hi"}},
+		{`{"message":"hi"}`, EchoRequest{Msg: "hi"}},
+		{`{"message":"hi","transform":"upper"}`, EchoRequest{Msg: "hi", Transform: "upper"}},
+		{`{"msg":"wins","message":"loses"}`, EchoRequest{Msg: "wins"}},
+		{`{"msg":"","message":"loses"}`, EchoRequest{}},
+		{`{}`, EchoRequest{}},
+	}
+	for _, tt := range tests {
+		var got EchoRequest
+		if err := json.Unmarshal([]byte(tt.in), &got); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	var req EchoRequest
+	if err := json.Unmarshal([]byte(`{"msg":1}`), &req); err == nil {
+		t.Error(`Unmarshal({"msg":1}) succeeded, want a type error`)
+	}
+}
+
+func TestEchoRequestMarshalsMsg(t *testing.T) {
+	c := jsonCodec{}
+	var req EchoRequest
+	if err := c.Unmarshal([]byte(`{"message":"hi"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Marshal(&req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"msg":"hi"}`; got != want {
+		t.Errorf("Marshal = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,190 @@
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// --------------------
+// Minimal protobuf wire encoding for the plain-struct messages
+// --------------------
+//
+// Each encoded field carries a `proto:"N"` tag holding its field number, the
+// same number it would have in echo.proto. Supported Go kinds are string,
+// []byte, bool, signed/unsigned integers (varint), float64 (fixed64) and
+// []string (repeated string). Fields without a tag are skipped.
+
+func marshalWire(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot marshal %T: want pointer to struct", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	var b []byte
+	for i := 0; i < rt.NumField(); i++ {
+		num, ok, err := fieldNumber(rt.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		f := rv.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			if f.Len() > 0 {
+				b = protowire.AppendTag(b, num, protowire.BytesType)
+				b = protowire.AppendString(b, f.String())
+			}
+		case reflect.Bool:
+			if f.Bool() {
+				b = protowire.AppendTag(b, num, protowire.VarintType)
+				b = protowire.AppendVarint(b, 1)
+			}
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			if f.Int() != 0 {
+				b = protowire.AppendTag(b, num, protowire.VarintType)
+				b = protowire.AppendVarint(b, uint64(f.Int()))
+			}
+		case reflect.Uint, reflect.Uint32, reflect.Uint64:
+			if f.Uint() != 0 {
+				b = protowire.AppendTag(b, num, protowire.VarintType)
+				b = protowire.AppendVarint(b, f.Uint())
+			}
+		case reflect.Float64:
+			if f.Float() != 0 {
+				b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+				b = protowire.AppendFixed64(b, math.Float64bits(f.Float()))
+			}
+		case reflect.Slice:
+			switch f.Type().Elem().Kind() {
+			case reflect.Uint8:
+				if f.Len() > 0 {
+					b = protowire.AppendTag(b, num, protowire.BytesType)
+					b = protowire.AppendBytes(b, f.Bytes())
+				}
+			case reflect.String:
+				for j := 0; j < f.Len(); j++ {
+					b = protowire.AppendTag(b, num, protowire.BytesType)
+					b = protowire.AppendString(b, f.Index(j).String())
+				}
+			default:
+				return nil, fmt.Errorf("%s.%s: unsupported slice type %s", rt.Name(), rt.Field(i).Name, f.Type())
+			}
+		default:
+			return nil, fmt.Errorf("%s.%s: unsupported type %s", rt.Name(), rt.Field(i).Name, f.Type())
+		}
+	}
+	return b, nil
+}
+
+func unmarshalWire(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal into %T: want pointer to struct", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	fields := make(map[protowire.Number]int, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		num, ok, err := fieldNumber(rt.Field(i))
+		if err != nil {
+			return err
+		}
+		if ok {
+			fields[num] = i
+		}
+	}
+
+	rv.SetZero()
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		idx, known := fields[num]
+		if !known {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+
+		f := rv.Field(idx)
+		switch typ {
+		case protowire.BytesType:
+			val, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			switch {
+			case f.Kind() == reflect.String:
+				f.SetString(string(val))
+			case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Uint8:
+				f.SetBytes(append([]byte(nil), val...))
+			case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+				f.Set(reflect.Append(f, reflect.ValueOf(string(val))))
+			default:
+				return wireTypeError(rt, idx, typ)
+			}
+		case protowire.VarintType:
+			val, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			switch f.Kind() {
+			case reflect.Bool:
+				f.SetBool(val != 0)
+			case reflect.Int, reflect.Int32, reflect.Int64:
+				f.SetInt(int64(val))
+			case reflect.Uint, reflect.Uint32, reflect.Uint64:
+				f.SetUint(val)
+			default:
+				return wireTypeError(rt, idx, typ)
+			}
+		case protowire.Fixed64Type:
+			val, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			if f.Kind() != reflect.Float64 {
+				return wireTypeError(rt, idx, typ)
+			}
+			f.SetFloat(math.Float64frombits(val))
+		default:
+			return wireTypeError(rt, idx, typ)
+		}
+	}
+	return nil
+}
+
+func fieldNumber(sf reflect.StructField) (protowire.Number, bool, error) {
+	tag, ok := sf.Tag.Lookup("proto")
+	if !ok {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(tag)
+	if err != nil || !protowire.Number(n).IsValid() {
+		return 0, false, errors.New("invalid proto field number on " + sf.Name + ": " + strconv.Quote(tag))
+	}
+	return protowire.Number(n), true, nil
+}
+
+func wireTypeError(rt reflect.Type, idx int, typ protowire.Type) error {
+	return fmt.Errorf("%s.%s: unexpected wire type %d", rt.Name(), rt.Field(idx).Name, typ)
+}
@@ -0,0 +1,137 @@
+// Package logging writes the per-request log records both services emit,
+// either as the original key=value text lines or as log/slog JSON records.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Supported -log-format values.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config selects how a Logger formats and filters records.
+type Config struct {
+	// Format is FormatText or FormatJSON; empty means FormatText.
+	Format string
+	// Level is the minimum level written.
+	Level slog.Level
+	// SampleRate is the fraction (0..1) of records below slog.LevelWarn
+	// that are kept. Records at warn and above are never sampled out.
+	SampleRate float64
+}
+
+// Logger emits one record per request from alternating key/value pairs.
+type Logger struct {
+	text       *log.Logger
+	json       *slog.Logger
+	level      slog.Level
+	sampleRate float64
+}
+
+// New returns a Logger writing records to w according to cfg.
+func New(w io.Writer, cfg Config) (*Logger, error) {
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return nil, fmt.Errorf("sample rate %v out of range [0, 1]", cfg.SampleRate)
+	}
+	l := &Logger{level: cfg.Level, sampleRate: cfg.SampleRate}
+	switch cfg.Format {
+	case FormatText, "":
+		l.text = log.New(w, "", log.LstdFlags)
+	case FormatJSON:
+		l.json = slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: cfg.Level}))
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want %s or %s)", cfg.Format, FormatText, FormatJSON)
+	}
+	return l, nil
+}
+
+// ParseLevel parses a -log-level value: debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+	}
+	return level, nil
+}
+
+// CodeLevel picks the log level for a finished RPC: successes at debug,
+// errors the caller caused at warn, and everything else at error.
+func CodeLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK:
+		return slog.LevelDebug
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.OutOfRange, codes.DeadlineExceeded:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// Request logs a request record at level, e.g.
+//
+//	l.Request(slog.LevelDebug, "service", "A", "endpoint", "/echo.EchoService/Echo", "status", "OK", "latency_ms", 3)
+//
+// Keys are written in the order given.
+func (l *Logger) Request(level slog.Level, kv ...any) {
+	if !l.keep(level) {
+		return
+	}
+	if l.json != nil {
+		l.json.Log(context.Background(), level, "request", kv...)
+		return
+	}
+	l.text.Print("level=" + level.String() + " " + formatText(kv))
+}
+
+// keep reports whether a record at level passes the level filter and, for
+// records below warn, the sampler.
+func (l *Logger) keep(level slog.Level) bool {
+	if level < l.level {
+		return false
+	}
+	if level >= slog.LevelWarn || l.sampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < l.sampleRate
+}
+
+// formatText renders kv as space-separated key=value pairs, quoting string
+// values that would otherwise be ambiguous.
+func formatText(kv []any) string {
+	var b strings.Builder
+	for i := 0; i < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprint(&b, kv[i])
+		b.WriteByte('=')
+		if i+1 >= len(kv) {
+			b.WriteString("!MISSING")
+			break
+		}
+		switch v := kv[i+1].(type) {
+		case string:
+			if v == "" || strings.ContainsAny(v, " \"=\t\n") {
+				v = strconv.Quote(v)
+			}
+			b.WriteString(v)
+		default:
+			fmt.Fprint(&b, v)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestJSONRecordKeys(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New(&out, Config{Format: FormatJSON, Level: slog.LevelDebug, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Request(slog.LevelWarn, "service", "B", "endpoint", "/call-echo", "status", "error", "http_status", 503, "latency_ms", int64(7))
+
+	var rec map[string]any
+	if err := json.Unmarshal(out.Bytes(), &rec); err != nil {
+		t.Fatalf("record %q is not JSON: %v", out.String(), err)
+	}
+	want := map[string]any{
+		"level":       "WARN",
+		"msg":         "request",
+		"service":     "B",
+		"endpoint":    "/call-echo",
+		"status":      "error",
+		"http_status": float64(503),
+		"latency_ms":  float64(7),
+	}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s = %v, want %v", k, rec[k], v)
+		}
+	}
+	if _, ok := rec["time"]; !ok {
+		t.Error("record has no time")
+	}
+}
+
+func TestTextRecord(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New(&out, Config{Level: slog.LevelDebug, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Request(slog.LevelDebug, "service", "A", "status", "OK", "error", "two words", "empty", "")
+
+	want := `level=DEBUG service=A status=OK error="two words" empty=""`
+	if line := strings.TrimSpace(out.String()); !strings.HasSuffix(line, want) {
+		t.Errorf("line = %q, want it to end with %q", line, want)
+	}
+}
+
+func TestNewRejectsUnknownFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, Config{Format: "xml", SampleRate: 1}); err == nil {
+		t.Error("New accepted format xml")
+	}
+}
+
+func TestErrorsAreNeverSampledOut(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New(&out, Config{Level: slog.LevelDebug, SampleRate: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Request(slog.LevelDebug, "status", "ok")
+		l.Request(slog.LevelWarn, "status", "warn")
+		l.Request(slog.LevelError, "status", "error")
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 200 {
+		t.Fatalf("kept %d records, want the 200 warn and error ones", len(lines))
+	}
+	if strings.Contains(out.String(), "status=ok") {
+		t.Error("a debug record survived a sample rate of 0")
+	}
+}
+
+func TestSamplingKeepsAFraction(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New(&out, Config{Level: slog.LevelDebug, SampleRate: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		l.Request(slog.LevelDebug, "status", "ok")
+	}
+	if n := strings.Count(out.String(), "\n"); n < 350 || n > 650 {
+		t.Errorf("kept %d of 1000 records at rate 0.5", n)
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New(&out, Config{Level: slog.LevelWarn, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Request(slog.LevelInfo, "status", "info")
+	l.Request(slog.LevelWarn, "status", "warn")
+	if got := out.String(); strings.Contains(got, "status=info") || !strings.Contains(got, "status=warn") {
+		t.Errorf("output at level warn = %q", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]slog.Level{"debug": slog.LevelDebug, "info": slog.LevelInfo, "warn": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLevel(s); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel accepted loud")
+	}
+}
+
+func TestNewRejectsBadSampleRate(t *testing.T) {
+	for _, rate := range []float64{-0.1, 1.5} {
+		if _, err := New(&bytes.Buffer{}, Config{SampleRate: rate}); err == nil {
+			t.Errorf("New accepted sample rate %v", rate)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// --------------------
+// Audit log
+// --------------------
+
+// auditFlushInterval is how often buffered audit records are written out.
+const auditFlushInterval = time.Second
+
+// auditRecord is one line of the audit log: a finished RPC.
+type auditRecord struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	RequestID string    `json:"request_id"`
+	Status    string    `json:"status"`
+}
+
+// auditLog appends a JSON record per RPC to -audit-log. Records go through
+// a buffered writer that is flushed every auditFlushInterval and on close,
+// so a crash can lose at most the last interval. Write failures are logged
+// but never fail the RPC being audited.
+type auditLog struct {
+	mu   sync.Mutex
+	f    *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newAuditLog opens path for appending, creating it if needed, and starts
+// the periodic flush. It returns nil (no auditing) when path is empty.
+func newAuditLog(path string) (*auditLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	a := &auditLog{
+		f:    f,
+		w:    bufio.NewWriter(f),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	a.enc = json.NewEncoder(a.w)
+	go a.flushLoop()
+	return a, nil
+}
+
+func (a *auditLog) flushLoop() {
+	defer close(a.done)
+	t := time.NewTicker(auditFlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-t.C:
+			a.mu.Lock()
+			a.flushLocked()
+			a.mu.Unlock()
+		}
+	}
+}
+
+func (a *auditLog) flushLocked() {
+	if err := a.w.Flush(); err != nil {
+		log.Printf("service=A audit=flush_failed error=%q", err.Error())
+	}
+}
+
+func (a *auditLog) record(ctx context.Context, method string, err error) {
+	rec := auditRecord{
+		Time:      time.Now().UTC(),
+		Method:    method,
+		RequestID: requestIDFromIncoming(ctx),
+		Status:    status.Code(err).String(),
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.enc.Encode(rec); err != nil {
+		log.Printf("service=A audit=write_failed method=%s error=%q", method, err.Error())
+	}
+}
+
+// Close stops the periodic flush, writes out any buffered records and
+// closes the file. Call it once the server has stopped taking RPCs.
+func (a *auditLog) Close() error {
+	close(a.stop)
+	<-a.done
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.w.Flush(); err != nil {
+		a.f.Close()
+		return fmt.Errorf("flush audit log: %w", err)
+	}
+	return a.f.Close()
+}
+
+func (a *auditLog) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		a.record(ctx, info.FullMethod, err)
+		return resp, err
+	}
+}
+
+func (a *auditLog) streamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		a.record(ss.Context(), info.FullMethod, err)
+		return err
+	}
+}
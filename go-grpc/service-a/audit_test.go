@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"grpc-echo-json/echo"
+)
+
+// readAudit returns the records in the audit log at path.
+func readAudit(t *testing.T, path string) []auditRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var recs []auditRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec auditRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("audit line %q: %v", sc.Text(), err)
+		}
+		recs = append(recs, rec)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return recs
+}
+
+func TestAuditLogFlushedOnGracefulStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := newAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, client := serveBufconn(t, serviceA{},
+		grpc.ChainUnaryInterceptor(audit.unaryInterceptor()),
+		grpc.ChainStreamInterceptor(audit.streamInterceptor()),
+	)
+	t.Cleanup(func() { setServingStatus("", true); setServingStatus(echo.ServiceName, true) })
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), echo.RequestIDMetadataKey, "req-audit")
+	if _, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Echo(ctx, &echo.EchoRequest{}); err == nil {
+		t.Fatal("Echo of an empty msg succeeded")
+	}
+	stream, err := client.RepeatEcho(ctx, &echo.RepeatEchoRequest{Msg: "again", Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Well within auditFlushInterval, the records are still buffered.
+	if recs := readAudit(t, path); len(recs) != 0 {
+		t.Fatalf("audit log has %d records before stopping, want them buffered", len(recs))
+	}
+
+	gracefulStop(s, 5*time.Second)
+	if err := audit.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	recs := readAudit(t, path)
+	want := []auditRecord{
+		{Method: "/echo.EchoService/Echo", RequestID: "req-audit", Status: "OK"},
+		{Method: "/echo.EchoService/Echo", RequestID: "req-audit", Status: "InvalidArgument"},
+		{Method: "/echo.EchoService/RepeatEcho", RequestID: "req-audit", Status: "OK"},
+	}
+	if len(recs) != len(want) {
+		t.Fatalf("audit log has %d records after graceful stop, want %d: %+v", len(recs), len(want), recs)
+	}
+	for i, rec := range recs {
+		if rec.Time.IsZero() {
+			t.Errorf("record %d has no time", i)
+		}
+		rec.Time = time.Time{}
+		if rec != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, rec, want[i])
+		}
+	}
+}
+
+func TestAuditWriteFailureDoesNotFailRPC(t *testing.T) {
+	var out syncBuffer
+	log.SetOutput(&out)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	audit, err := newAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leave no room to buffer a record, so writing one hits the closed file.
+	audit.mu.Lock()
+	audit.f.Close()
+	audit.w = bufio.NewWriterSize(audit.f, 16)
+	audit.enc = json.NewEncoder(audit.w)
+	audit.mu.Unlock()
+
+	client := startServiceA(t, grpc.ChainUnaryInterceptor(audit.unaryInterceptor()))
+	if _, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "hi"}); err != nil {
+		t.Fatalf("Echo failed along with its audit record: %v", err)
+	}
+	if !strings.Contains(out.String(), "audit=write_failed method=/echo.EchoService/Echo") {
+		t.Errorf("log = %q, want the failed audit write logged", out.String())
+	}
+	_ = audit.Close()
+}
+
+func TestNewAuditLogDisabled(t *testing.T) {
+	if a, err := newAuditLog(""); a != nil || err != nil {
+		t.Errorf("newAuditLog(\"\") = %v, %v; want nil, nil", a, err)
+	}
+}
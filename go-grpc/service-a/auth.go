@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// API key authentication
+// --------------------
+
+// parseAPIKeys turns the comma-separated -api-keys value into a set.
+func parseAPIKeys(list string) map[string]bool {
+	keys := make(map[string]bool)
+	for _, k := range strings.Split(list, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys[k] = true
+		}
+	}
+	return keys
+}
+
+// apiKeyFromIncoming reads the caller's key from "x-api-key" or from an
+// "authorization" value (optionally prefixed with "Bearer ").
+func apiKeyFromIncoming(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if v := md.Get(echo.APIKeyMetadataKey); len(v) > 0 && v[0] != "" {
+		return v[0]
+	}
+	if v := md.Get("authorization"); len(v) > 0 {
+		return strings.TrimSpace(strings.TrimPrefix(v[0], "Bearer "))
+	}
+	return ""
+}
+
+// authExempt lists methods that stay open without a key, so standard health
+// probes keep working.
+func authExempt(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/")
+}
+
+func checkAPIKey(ctx context.Context, validKeys map[string]bool) error {
+	key := apiKeyFromIncoming(ctx)
+	if key == "" {
+		return status.Error(codes.Unauthenticated, "missing API key")
+	}
+	if !validKeys[key] {
+		return status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	return nil
+}
+
+// authUnaryInterceptor rejects calls without a key from validKeys with
+// codes.Unauthenticated.
+func authUnaryInterceptor(validKeys map[string]bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !authExempt(info.FullMethod) {
+			if err := checkAPIKey(ctx, validKeys); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// authStreamInterceptor is the streaming counterpart of authUnaryInterceptor.
+func authStreamInterceptor(validKeys map[string]bool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !authExempt(info.FullMethod) {
+			if err := checkAPIKey(ss.Context(), validKeys); err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+func TestAuthUnaryInterceptor(t *testing.T) {
+	client := startServiceA(t, grpc.UnaryInterceptor(authUnaryInterceptor(parseAPIKeys("key-1, key-2"))))
+
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want codes.Code
+	}{
+		{"missing", nil, codes.Unauthenticated},
+		{"invalid", metadata.Pairs(echo.APIKeyMetadataKey, "wrong"), codes.Unauthenticated},
+		{"valid x-api-key", metadata.Pairs(echo.APIKeyMetadataKey, "key-2"), codes.OK},
+		{"valid bearer", metadata.Pairs("authorization", "Bearer key-1"), codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewOutgoingContext(ctx, tt.md)
+			}
+			_, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("Echo code = %s, want %s (err %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestAuthExemptsHealth(t *testing.T) {
+	if !authExempt("/grpc.health.v1.Health/Check") {
+		t.Error("health checks require an API key")
+	}
+	if authExempt("/" + echo.ServiceName + "/Echo") {
+		t.Error("Echo is exempt from API key checks")
+	}
+}
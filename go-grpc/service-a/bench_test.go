@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"grpc-echo-json/echo"
+)
+
+// benchmarkEcho measures Echo over bufconn with the codec named subtype.
+// The server is started and closed for every run of the benchmark
+// function, and the run fails if that leaves goroutines behind, so runs
+// stay independent and comparable on one machine.
+func benchmarkEcho(b *testing.B, subtype string) {
+	baseline := runtime.NumGoroutine()
+	b.Cleanup(func() {
+		// Close tears the server and connection down asynchronously;
+		// give their goroutines a moment to exit.
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if runtime.NumGoroutine() <= baseline {
+				return
+			}
+		}
+		b.Errorf("%d goroutines left running after the run, started with %d", runtime.NumGoroutine(), baseline)
+	})
+	client := startServiceA(b)
+
+	ctx := context.Background()
+	req := &echo.EchoRequest{Msg: "hello, benchmark"}
+	opt := grpc.CallContentSubtype(subtype)
+	if _, err := client.Echo(ctx, req, opt); err != nil {
+		b.Fatalf("warm-up Echo: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Echo(ctx, req, opt); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEchoJSON(b *testing.B)  { benchmarkEcho(b, echo.JSONCodecName) }
+func BenchmarkEchoProto(b *testing.B) { benchmarkEcho(b, echo.ProtoCodecName) }
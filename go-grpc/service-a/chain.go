@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// --------------------
+// Interceptor chaining
+// --------------------
+//
+// Interceptors run in the order given: the first one is the outermost and
+// sees the call first and the result last. Service A composes them as
+//
+//	logging -> audit -> timing -> recovery -> localize -> concurrency -> deadline -> auth -> codec -> schema -> metadata -> faults -> handler
+//
+// so logging observes every call, including ones rejected further in, and
+// sees a recovered panic as the codes.Internal the client receives; the
+// audit log (when set) records every call, rejected ones included. Timing
+// (unary only) wraps everything but logging and audit so its
+// server-latency-ms trailer covers rejected calls too. Localization sits
+// just inside recovery so every InvalidArgument the client can receive is
+// translated. The concurrency cap (when set) comes next, so it sheds load
+// before the deadline, auth, codec and schema checks or the handler run;
+// calls it rejects have still been logged, audited and timed. Callers are
+// authenticated before their codec and schema version are checked, and
+// injected faults (when set) stand in for the handler misbehaving, with
+// the injected latency counting against the call's deadline.
+
+// chainUnaryInterceptors composes interceptors into one, first outermost.
+func chainUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			ic, h := interceptors[i], next
+			next = func(ctx context.Context, req any) (any, error) {
+				return ic(ctx, req, info, h)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// chainStreamInterceptors is the streaming counterpart of
+// chainUnaryInterceptors, with the same ordering.
+func chainStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			ic, h := interceptors[i], next
+			next = func(srv any, ss grpc.ServerStream) error {
+				return ic(srv, ss, info, h)
+			}
+		}
+		return next(srv, ss)
+	}
+}
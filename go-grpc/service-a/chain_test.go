@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestChainUnaryInterceptorsOrder(t *testing.T) {
+	var order []string
+	record := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			order = append(order, name+" in")
+			resp, err := handler(ctx, req)
+			order = append(order, name+" out")
+			return resp, err
+		}
+	}
+
+	chained := chainUnaryInterceptors(record("logging"), record("recovery"), record("auth"))
+	_, err := chained(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Method"},
+		func(ctx context.Context, req any) (any, error) {
+			order = append(order, "handler")
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"logging in", "recovery in", "auth in", "handler", "auth out", "recovery out", "logging out"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("call order = %v, want %v", order, want)
+	}
+}
+
+func TestChainStreamInterceptorsOrder(t *testing.T) {
+	var order []string
+	record := func(name string) grpc.StreamServerInterceptor {
+		return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			order = append(order, name)
+			return handler(srv, ss)
+		}
+	}
+
+	chained := chainStreamInterceptors(record("logging"), record("recovery"), record("auth"))
+	err := chained(nil, nil, &grpc.StreamServerInfo{FullMethod: "/test/Stream"}, func(srv any, ss grpc.ServerStream) error {
+		order = append(order, "handler")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"logging", "recovery", "auth", "handler"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("call order = %v, want %v", order, want)
+	}
+}
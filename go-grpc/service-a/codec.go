@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Wire codec (-codec)
+// --------------------
+
+// callCodec returns the codec a call was encoded with: the content-subtype
+// of its content-type header, lower-cased as grpc matches it, or "proto",
+// grpc's default, when the client declared none.
+func callCodec(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ct := md.Get("content-type")
+	if len(ct) == 0 {
+		return echo.ProtoCodecName
+	}
+	sub, ok := strings.CutPrefix(strings.ToLower(ct[0]), "application/grpc+")
+	if !ok {
+		return echo.ProtoCodecName
+	}
+	sub, _, _ = strings.Cut(sub, ";")
+	return sub
+}
+
+// wrongCodec returns an InvalidArgument error if an EchoService call was
+// encoded with a codec other than want, or nil. Other services, such as
+// health and reflection, speak protobuf whatever -codec says.
+func wrongCodec(ctx context.Context, fullMethod, want string) error {
+	if !strings.HasPrefix(fullMethod, "/"+echo.ServiceName+"/") {
+		return nil
+	}
+	if got := callCodec(ctx); got != want {
+		return status.Errorf(codes.InvalidArgument, "call is encoded with the %s codec; service A expects %s", got, want)
+	}
+	return nil
+}
+
+// codecUnaryInterceptor rejects EchoService calls not encoded with codec.
+func codecUnaryInterceptor(codec string) grpc.UnaryServerInterceptor {
+	codec = strings.ToLower(codec)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := wrongCodec(ctx, info.FullMethod, codec); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// codecStreamInterceptor is the streaming counterpart of
+// codecUnaryInterceptor.
+func codecStreamInterceptor(codec string) grpc.StreamServerInterceptor {
+	codec = strings.ToLower(codec)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := wrongCodec(ss.Context(), info.FullMethod, codec); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+func TestCallCodec(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"", echo.ProtoCodecName},
+		{"application/grpc", echo.ProtoCodecName},
+		{"application/grpc+json", echo.JSONCodecName},
+		{"application/grpc+JSON", echo.JSONCodecName},
+		{"application/grpc+proto", echo.ProtoCodecName},
+		{"application/grpc+json; charset=utf-8", echo.JSONCodecName},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.contentType != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("content-type", tt.contentType))
+		}
+		if got := callCodec(ctx); got != tt.want {
+			t.Errorf("callCodec(%q) = %q, want %q", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestCodecInterceptors(t *testing.T) {
+	client := startServiceA(t,
+		grpc.UnaryInterceptor(codecUnaryInterceptor("JSON")),
+		grpc.StreamInterceptor(codecStreamInterceptor("JSON")),
+	)
+	ctx := context.Background()
+
+	// The test client asks for JSON unless a call overrides it.
+	if _, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"}); err != nil {
+		t.Fatalf("JSON Echo: %v", err)
+	}
+	_, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"}, grpc.CallContentSubtype(echo.ProtoCodecName))
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("proto Echo = %v, want InvalidArgument", err)
+	}
+
+	stream, err := client.EchoStream(ctx, grpc.CallContentSubtype(echo.ProtoCodecName))
+	if err != nil {
+		t.Fatalf("EchoStream: %v", err)
+	}
+	_ = stream.CloseSend()
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("proto EchoStream Recv = %v, want InvalidArgument", err)
+	}
+
+	stream, err = client.EchoStream(ctx)
+	if err != nil {
+		t.Fatalf("EchoStream: %v", err)
+	}
+	_ = stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("JSON EchoStream Recv = %v, want io.EOF", err)
+	}
+}
+
+func TestCodecInterceptorExemptsOtherServices(t *testing.T) {
+	interceptor := codecUnaryInterceptor(echo.JSONCodecName)
+	if err := callThrough(context.Background(), interceptor, "/grpc.health.v1.Health/Check"); err != nil {
+		t.Errorf("proto health check = %v, want nil", err)
+	}
+	if err := callThrough(context.Background(), interceptor, "/"+echo.ServiceName+"/Echo"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("proto Echo = %v, want InvalidArgument", err)
+	}
+}
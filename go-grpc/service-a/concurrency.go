@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// --------------------
+// In-flight request cap
+// --------------------
+
+// concurrencyLimiter is a semaphore bounding how many RPCs A runs at once.
+// Calls beyond the limit fail fast with ResourceExhausted instead of
+// queueing, so overload shows up as errors rather than unbounded latency.
+// Health checks bypass it, so probes (and long-lived Watch streams) neither
+// consume slots nor fail just because A is busy.
+//
+// -max-streams is the queueing counterpart: grpc advertises it to each client
+// as HTTP/2 SETTINGS_MAX_CONCURRENT_STREAMS, so a client at the limit holds
+// new calls back until one of its streams finishes (or the call's deadline
+// expires) rather than sending them. It applies per connection, and B keeps a
+// single connection to each replica, so it bounds each B's in-flight calls to
+// a replica; -max-concurrent bounds the total across all clients. A call must
+// get past both, waiting for a stream first and then failing fast on a slot.
+type concurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// newConcurrencyLimiter returns a limiter allowing max in-flight calls, or
+// nil (no limit) when max <= 0.
+func newConcurrencyLimiter(max int) *concurrencyLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &concurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire takes a slot and returns the func that gives it back, or an error
+// if all slots are in use.
+func (l *concurrencyLimiter) acquire(fullMethod string) (release func(), err error) {
+	if strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/") {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	default:
+		return nil, status.Errorf(codes.ResourceExhausted, "server busy: %d requests in flight", cap(l.slots))
+	}
+}
+
+func (l *concurrencyLimiter) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		release, err := l.acquire(info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+func (l *concurrencyLimiter) streamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		release, err := l.acquire(info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return handler(srv, ss)
+	}
+}
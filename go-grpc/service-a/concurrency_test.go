@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/testutil"
+)
+
+// parkedEcho holds every Echo until release is closed, reporting each
+// arrival on started.
+type parkedEcho struct {
+	serviceA
+	started chan<- struct{}
+	release <-chan struct{}
+}
+
+func (p parkedEcho) Echo(ctx context.Context, req *echo.EchoRequest) (*echo.EchoResponse, error) {
+	p.started <- struct{}{}
+	select {
+	case <-p.release:
+		return p.serviceA.Echo(ctx, req)
+	case <-ctx.Done():
+		return nil, contextError(ctx)
+	}
+}
+
+func TestConcurrencyLimiterRejectsWhenSaturated(t *testing.T) {
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	limiter := newConcurrencyLimiter(2)
+	srv, err := testutil.StartEchoServer(parkedEcho{started: started, release: release},
+		grpc.UnaryInterceptor(limiter.unaryInterceptor()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "parked"})
+			done <- err
+		}()
+		<-started
+	}
+
+	_, err = client.Echo(context.Background(), &echo.EchoRequest{Msg: "one too many"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("third concurrent Echo: err = %v, want ResourceExhausted", err)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("parked Echo: %v", err)
+		}
+	}
+	if _, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "after"}); err != nil {
+		t.Errorf("Echo after the parked calls finished: %v", err)
+	}
+}
+
+func TestConcurrencyLimiterReleasesOnError(t *testing.T) {
+	client := startServiceA(t, grpc.UnaryInterceptor(newConcurrencyLimiter(1).unaryInterceptor()))
+	for i := 0; i < 3; i++ {
+		if _, err := client.Echo(context.Background(), &echo.EchoRequest{}); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("invalid Echo %d: err = %v, want InvalidArgument", i, err)
+		}
+	}
+	if _, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "hi"}); err != nil {
+		t.Errorf("Echo after failed calls: %v; their slots were not released", err)
+	}
+}
+
+func TestConcurrencyLimiterExemptsHealth(t *testing.T) {
+	l := newConcurrencyLimiter(1)
+	release, err := l.acquire("/" + echo.ServiceName + "/Echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if _, err := l.acquire("/grpc.health.v1.Health/Check"); err != nil {
+		t.Errorf("health check on a full limiter: %v", err)
+	}
+	if newConcurrencyLimiter(0) != nil {
+		t.Error("newConcurrencyLimiter(0) should disable the limit")
+	}
+}
+
+func TestMaxConcurrentStreamsQueuesExtraCalls(t *testing.T) {
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	srv, err := testutil.StartEchoServer(parkedEcho{started: started, release: release}, grpc.MaxConcurrentStreams(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+
+	// Make sure the client has the server's SETTINGS before counting streams.
+	if _, err := client.Health(context.Background(), &echo.HealthRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "queued"})
+			done <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		<-started
+	}
+
+	// The third call waits on the client for a free stream instead of
+	// reaching A or failing.
+	select {
+	case <-started:
+		t.Fatal("a third call reached A with -max-streams 2")
+	case err := <-done:
+		t.Fatalf("a call finished while A was parked: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	<-started
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("Echo: %v", err)
+		}
+	}
+}
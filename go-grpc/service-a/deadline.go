@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// --------------------
+// Server-side deadlines
+// --------------------
+
+// deadlineUnaryInterceptor gives calls that arrive without a deadline a
+// default one of timeout, so a slow handler can't run forever, and rejects
+// calls whose deadline has already passed without running the handler.
+// timeout <= 0 only does the latter.
+func deadlineUnaryInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if dl, ok := ctx.Deadline(); ok && !time.Now().Before(dl) {
+			return nil, status.Error(codes.DeadlineExceeded, "deadline already exceeded")
+		}
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return handler(ctx, req)
+	}
+}
+
+// subCallBudget is the time A reserves for its own work (encoding the
+// response, logging, sending it back to B) when handing the rest of an
+// incoming deadline to a sub-call. Without it a sub-call could use the whole
+// remaining budget and leave A answering after the caller gave up.
+const subCallBudget = 20 * time.Millisecond
+
+// subCallContext derives the context for a sub-call made while handling an
+// RPC: its deadline is the caller's, less subCallBudget. If that leaves no
+// time at all the sub-call is pointless, so it returns DeadlineExceeded
+// instead. Calls without a deadline get an unchanged (cancelable) context.
+func subCallContext(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	dl, ok := ctx.Deadline()
+	if !ok {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, nil
+	}
+	child := dl.Add(-subCallBudget)
+	if !time.Now().Before(child) {
+		return nil, nil, status.Errorf(codes.DeadlineExceeded, "less than %s left of the deadline, not enough for a sub-call", subCallBudget)
+	}
+	ctx, cancel := context.WithDeadline(ctx, child)
+	return ctx, cancel, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSubCallContextSufficientTime(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	parent, _ := ctx.Deadline()
+
+	sub, subCancel, err := subCallContext(ctx)
+	if err != nil {
+		t.Fatalf("subCallContext: %v", err)
+	}
+	defer subCancel()
+	child, ok := sub.Deadline()
+	if !ok {
+		t.Fatal("sub-call context has no deadline")
+	}
+	if got := parent.Sub(child); got != subCallBudget {
+		t.Errorf("child deadline is %s before the parent's, want %s", got, subCallBudget)
+	}
+}
+
+func TestSubCallContextInsufficientTime(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), subCallBudget/2)
+	defer cancel()
+	if _, _, err := subCallContext(ctx); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestSubCallContextWithoutDeadline(t *testing.T) {
+	sub, cancel, err := subCallContext(context.Background())
+	if err != nil {
+		t.Fatalf("subCallContext: %v", err)
+	}
+	defer cancel()
+	if _, ok := sub.Deadline(); ok {
+		t.Error("sub-call context has a deadline the caller didn't set")
+	}
+}
+
+// withTransformPool sets transformPool for the duration of the test.
+func withTransformPool(t *testing.T, p *pool) {
+	t.Helper()
+	old := transformPool
+	transformPool = p
+	t.Cleanup(func() { transformPool = old })
+}
+
+func TestRunTransformSkipsWorkNearDeadline(t *testing.T) {
+	withTransformPool(t, newPool(1))
+	ctx, cancel := context.WithTimeout(context.Background(), subCallBudget/2)
+	defer cancel()
+	ran := false
+	if err := runTransform(ctx, func() { ran = true }); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	if ran {
+		t.Error("transform ran with too little time left")
+	}
+}
+
+func TestRunTransformWithoutPoolIgnoresBudget(t *testing.T) {
+	withTransformPool(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), subCallBudget/2)
+	defer cancel()
+	ran := false
+	if err := runTransform(ctx, func() { ran = true }); err != nil {
+		t.Fatalf("runTransform: %v", err)
+	}
+	if !ran {
+		t.Error("transform didn't run inline with time still left")
+	}
+}
+
+func TestDeadlineInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/echo.EchoService/Echo"}
+	var got time.Time
+	handler := func(ctx context.Context, _ any) (any, error) {
+		got, _ = ctx.Deadline()
+		return nil, nil
+	}
+
+	before := time.Now()
+	if _, err := deadlineUnaryInterceptor(time.Second)(context.Background(), nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if got.Before(before.Add(time.Second)) || got.After(time.Now().Add(time.Second)) {
+		t.Errorf("default deadline %s is not 1s from the call", got)
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Millisecond))
+	defer cancel()
+	called := false
+	_, err := deadlineUnaryInterceptor(time.Second)(expired, nil, info, func(context.Context, any) (any, error) {
+		called = true
+		return nil, nil
+	})
+	if status.Code(err) != codes.DeadlineExceeded || called {
+		t.Errorf("expired call: err = %v, handler called = %t; want DeadlineExceeded without calling it", err, called)
+	}
+}
+
+func TestDeadlineInterceptorKeepsCallerDeadline(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/echo.EchoService/Echo"}
+	want := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), want)
+	defer cancel()
+
+	var got time.Time
+	_, err := deadlineUnaryInterceptor(time.Second)(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+		got, _ = ctx.Deadline()
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("handler deadline = %s, want the caller's %s", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Fault injection (resilience testing)
+// --------------------
+
+// faultInjector delays EchoService unary calls by latency and fails a
+// fraction errorRate of them with Unavailable, so service B's timeout,
+// retry and breaker paths can be exercised without stopping A. Health
+// checks are never affected.
+type faultInjector struct {
+	latency   time.Duration
+	errorRate float64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// newFaultInjector returns an injector drawing from rng, or nil when
+// neither latency nor errors are configured.
+func newFaultInjector(latency time.Duration, errorRate float64, rng *rand.Rand) *faultInjector {
+	if latency <= 0 && errorRate <= 0 {
+		return nil
+	}
+	return &faultInjector{latency: latency, errorRate: errorRate, rng: rng}
+}
+
+// shouldFail reports whether the next call gets an injected error.
+func (f *faultInjector) shouldFail() bool {
+	if f.errorRate <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rng.Float64() < f.errorRate
+}
+
+func (f *faultInjector) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, "/"+echo.ServiceName+"/") {
+			return handler(ctx, req)
+		}
+		if f.latency > 0 {
+			t := time.NewTimer(f.latency)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return nil, status.FromContextError(ctx.Err()).Err()
+			case <-t.C:
+			}
+		}
+		if f.shouldFail() {
+			return nil, status.Error(codes.Unavailable, "injected fault")
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// callThrough runs one unary call for fullMethod through interceptor.
+func callThrough(ctx context.Context, interceptor grpc.UnaryServerInterceptor, fullMethod string) error {
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: fullMethod},
+		func(context.Context, any) (any, error) { return "ok", nil })
+	return err
+}
+
+func TestFaultInjectorErrorRate(t *testing.T) {
+	f := newFaultInjector(0, 0.3, rand.New(rand.NewPCG(1, 2)))
+	interceptor := f.unaryInterceptor()
+
+	const calls = 10000
+	failed := 0
+	for i := 0; i < calls; i++ {
+		switch err := callThrough(context.Background(), interceptor, "/echo.EchoService/Echo"); status.Code(err) {
+		case codes.OK:
+		case codes.Unavailable:
+			failed++
+		default:
+			t.Fatalf("call %d: err = %v, want nil or Unavailable", i, err)
+		}
+	}
+	if rate := float64(failed) / calls; rate < 0.28 || rate > 0.32 {
+		t.Errorf("injected error rate = %.3f over %d calls, want about 0.3", rate, calls)
+	}
+}
+
+func TestFaultInjectorSparesHealth(t *testing.T) {
+	interceptor := newFaultInjector(time.Hour, 1, rand.New(rand.NewPCG(1, 2))).unaryInterceptor()
+	if err := callThrough(context.Background(), interceptor, "/grpc.health.v1.Health/Check"); err != nil {
+		t.Errorf("health check: %v", err)
+	}
+}
+
+func TestFaultInjectorLatencyRespectsDeadline(t *testing.T) {
+	interceptor := newFaultInjector(time.Hour, 0, rand.New(rand.NewPCG(1, 2))).unaryInterceptor()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := callThrough(ctx, interceptor, "/echo.EchoService/Echo"); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("injected latency ignored the deadline: call took %s", elapsed)
+	}
+}
+
+func TestNewFaultInjectorDisabled(t *testing.T) {
+	if newFaultInjector(0, 0, nil) != nil {
+		t.Error("newFaultInjector with no latency or errors should return nil")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Hash RPC
+// --------------------
+
+// hashes maps each HashRequest.Algorithm value to its hash constructor. An
+// empty Algorithm means sha256.
+var hashes = map[string]func() hash.Hash{
+	"":       sha256.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Hash returns the hex digest of msg. Hashing is CPU-bound, so it runs on
+// transformPool through runTransform, like Echo's transforms.
+func (serviceA) Hash(ctx context.Context, req *echo.HashRequest) (*echo.HashResponse, error) {
+	if req == nil {
+		return nil, errNilRequest
+	}
+	if err := validateEcho(&echo.EchoRequest{Msg: req.Msg}); err != nil {
+		return nil, err
+	}
+	newHash, ok := hashes[req.Algorithm]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown algorithm %q (want sha256 or sha512)", req.Algorithm)
+	}
+	algorithm := req.Algorithm
+	if algorithm == "" {
+		algorithm = "sha256"
+	}
+
+	var digest string
+	if err := runTransform(ctx, func() {
+		h := newHash()
+		h.Write([]byte(req.Msg))
+		digest = hex.EncodeToString(h.Sum(nil))
+	}); err != nil {
+		return nil, err
+	}
+	return &echo.HashResponse{Digest: digest, Algorithm: algorithm}, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+func TestHashKnownDigests(t *testing.T) {
+	client := startServiceA(t)
+	tests := []struct {
+		msg, algorithm string
+		wantAlgorithm  string
+		digest         string
+	}{
+		{"abc", "", "sha256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"abc", "sha256", "sha256", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"abc", "sha512", "sha512", "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+		{"hello", "sha256", "sha256", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+	}
+	for _, tt := range tests {
+		resp, err := client.Hash(context.Background(), &echo.HashRequest{Msg: tt.msg, Algorithm: tt.algorithm})
+		if err != nil {
+			t.Fatalf("Hash(%q, %q): %v", tt.msg, tt.algorithm, err)
+		}
+		if resp.Digest != tt.digest || resp.Algorithm != tt.wantAlgorithm {
+			t.Errorf("Hash(%q, %q) = %s %s, want %s %s", tt.msg, tt.algorithm, resp.Algorithm, resp.Digest, tt.wantAlgorithm, tt.digest)
+		}
+	}
+}
+
+func TestHashRejectsUnknownAlgorithm(t *testing.T) {
+	client := startServiceA(t)
+	for _, req := range []*echo.HashRequest{
+		{Msg: "abc", Algorithm: "md5"},
+		{Msg: "", Algorithm: "sha256"},
+	} {
+		if _, err := client.Hash(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Hash(%+v): err = %v, want InvalidArgument", req, err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Standard gRPC health checking (grpc.health.v1.Health)
+// --------------------
+
+// healthServer backs grpc.health.v1.Health alongside the custom
+// echo.EchoService/Health method, so grpc-health-probe and other standard
+// clients can check service A.
+var healthServer = health.NewServer()
+
+func registerHealthServer(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, healthServer)
+	setServingStatus("", true)
+	setServingStatus(echo.ServiceName, true)
+}
+
+// setServingStatus flips the reported status of service ("" is the overall
+// server status).
+func setServingStatus(service string, serving bool) {
+	st := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		st = healthpb.HealthCheckResponse_SERVING
+	}
+	healthServer.SetServingStatus(service, st)
+}
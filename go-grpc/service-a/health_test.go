@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"grpc-echo-json/echo"
+)
+
+// checkHealth returns healthServer's status for service.
+func checkHealth(t *testing.T, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q): %v", service, err)
+	}
+	return resp.Status
+}
+
+func TestSetServingStatus(t *testing.T) {
+	registerHealthServer(grpc.NewServer())
+	t.Cleanup(func() { setServingStatus(echo.ServiceName, true) })
+
+	setServingStatus(echo.ServiceName, false)
+	if got := checkHealth(t, echo.ServiceName); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status after marking NOT_SERVING = %s", got)
+	}
+	if got := checkHealth(t, ""); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("overall status = %s, want it left SERVING", got)
+	}
+
+	setServingStatus(echo.ServiceName, true)
+	if got := checkHealth(t, echo.ServiceName); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status after marking SERVING again = %s", got)
+	}
+}
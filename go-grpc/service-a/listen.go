@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// --------------------
+// gRPC listener (TCP or Unix domain socket)
+// --------------------
+
+// unixSocketPath returns the socket path of a -listen value in grpc's Unix
+// target forms, "unix:///abs/path.sock" or "unix:rel/path.sock", and false
+// for anything else (a TCP address).
+func unixSocketPath(listen string) (string, bool) {
+	if p, ok := strings.CutPrefix(listen, "unix://"); ok {
+		return p, true
+	}
+	return strings.CutPrefix(listen, "unix:")
+}
+
+// listenGRPC opens service A's gRPC listener: a Unix domain socket for a
+// unix: value, so co-located clients such as B (dialing the same target)
+// skip TCP, and a TCP address otherwise. The socket file is removed when
+// the listener closes.
+func listenGRPC(listen string) (net.Listener, error) {
+	path, ok := unixSocketPath(listen)
+	if !ok {
+		return net.Listen("tcp", listen)
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+// removeStaleSocket deletes a socket file left behind by a process that
+// didn't shut down cleanly. It refuses to touch anything that isn't a
+// socket, or a socket some other server is still accepting on.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		c.Close()
+		return fmt.Errorf("%s is in use by another server", path)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"grpc-echo-json/echo"
+)
+
+func TestUnixSocketPath(t *testing.T) {
+	tests := []struct {
+		listen string
+		path   string
+		unix   bool
+	}{
+		{"unix:///run/a.sock", "/run/a.sock", true},
+		{"unix:rel/a.sock", "rel/a.sock", true},
+		{":50051", "", false},
+		{"127.0.0.1:50051", "", false},
+	}
+	for _, tt := range tests {
+		if path, ok := unixSocketPath(tt.listen); ok != tt.unix || (ok && path != tt.path) {
+			t.Errorf("unixSocketPath(%q) = %q, %t; want %q, %t", tt.listen, path, ok, tt.path, tt.unix)
+		}
+	}
+}
+
+func TestEchoOverUnixSocket(t *testing.T) {
+	echo.RegisterCodecs()
+	target := "unix://" + filepath.Join(t.TempDir(), "a.sock")
+	lis, err := listenGRPC(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	echo.RegisterEchoServiceServer(s, serviceA{})
+	go func() { _ = s.Serve(lis) }()
+	defer s.Stop()
+
+	// B dials the same target.
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	resp, err := echo.NewEchoServiceClient(conn).Echo(context.Background(), &echo.EchoRequest{Msg: "over a socket"})
+	if err != nil || resp.Echo != "over a socket" {
+		t.Fatalf("Echo = %v, %v", resp, err)
+	}
+}
+
+func TestListenGRPCStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	// A socket file left behind by a crashed server is replaced.
+	stale := filepath.Join(dir, "stale.sock")
+	old, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.(*net.UnixListener).SetUnlinkOnClose(false)
+	old.Close()
+	lis, err := listenGRPC("unix://" + stale)
+	if err != nil {
+		t.Fatalf("listen over a stale socket: %v", err)
+	}
+	defer lis.Close()
+
+	// A socket another server is accepting on is left alone.
+	if l, err := listenGRPC("unix://" + stale); err == nil {
+		l.Close()
+		t.Error("listened on a socket that is in use")
+	}
+
+	// So is a file that isn't a socket.
+	regular := filepath.Join(dir, "not-a.sock")
+	if err := os.WriteFile(regular, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := listenGRPC("unix://" + regular); err == nil {
+		l.Close()
+		t.Error("replaced a regular file")
+	}
+	if _, err := os.Stat(regular); err != nil {
+		t.Errorf("regular file removed: %v", err)
+	}
+}
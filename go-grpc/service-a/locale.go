@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+
+	"golang.org/x/text/language"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// --------------------
+// Error localization (accept-language)
+// --------------------
+
+// acceptLanguageKey is the metadata key B copies the HTTP Accept-Language
+// header into.
+const acceptLanguageKey = "accept-language"
+
+// invalidArgumentText is the localized lead-in for InvalidArgument messages.
+// English, the fallback, leaves messages as they are.
+var invalidArgumentText = map[language.Tag]string{
+	language.English:    "",
+	language.Spanish:    "argumento no válido",
+	language.French:     "argument non valide",
+	language.German:     "ungültiges Argument",
+	language.Vietnamese: "đối số không hợp lệ",
+}
+
+// supportedLanguages are the keys of invalidArgumentText, English first so
+// the matcher falls back to it.
+var supportedLanguages = []language.Tag{
+	language.English, language.Spanish, language.French, language.German, language.Vietnamese,
+}
+
+var localeMatcher = language.NewMatcher(supportedLanguages)
+
+// callerLanguage picks the best supported language for the caller's
+// accept-language metadata, defaulting to English when it is absent or
+// nothing in it is supported.
+func callerLanguage(ctx context.Context) language.Tag {
+	md, _ := metadata.FromIncomingContext(ctx)
+	v := md.Get(acceptLanguageKey)
+	if len(v) == 0 {
+		return language.English
+	}
+	tags, _, err := language.ParseAcceptLanguage(v[0])
+	if err != nil || len(tags) == 0 {
+		return language.English
+	}
+	_, idx, conf := localeMatcher.Match(tags...)
+	if conf == language.No {
+		return language.English
+	}
+	return supportedLanguages[idx]
+}
+
+// localizeError prefixes an InvalidArgument status message with its
+// translation for the caller's language. Other errors pass through.
+func localizeError(ctx context.Context, err error) error {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.InvalidArgument {
+		return err
+	}
+	lead := invalidArgumentText[callerLanguage(ctx)]
+	if lead == "" {
+		return err
+	}
+	return status.Error(codes.InvalidArgument, lead+": "+st.Message())
+}
+
+// localizeUnaryInterceptor localizes InvalidArgument errors returned further
+// in the chain.
+func localizeUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, localizeError(ctx, err)
+		}
+		return resp, nil
+	}
+}
+
+// localizeStreamInterceptor is the streaming counterpart of
+// localizeUnaryInterceptor.
+func localizeStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, ss); err != nil {
+			return localizeError(ss.Context(), err)
+		}
+		return nil
+	}
+}
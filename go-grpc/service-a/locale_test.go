@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+func TestLocalizeInvalidArgument(t *testing.T) {
+	client := startServiceA(t, grpc.UnaryInterceptor(localizeUnaryInterceptor()))
+
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "msg must not be empty"},
+		{"es", "argumento no válido: msg must not be empty"},
+		{"fr-CA, en;q=0.5", "argument non valide: msg must not be empty"},
+		{"de-DE", "ungültiges Argument: msg must not be empty"},
+		{"vi", "đối số không hợp lệ: msg must not be empty"},
+		{"ja", "msg must not be empty"},
+		{"not a language!", "msg must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptLanguage, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.acceptLanguage != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, acceptLanguageKey, tt.acceptLanguage)
+			}
+			_, err := client.Echo(ctx, &echo.EchoRequest{})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("Echo err = %v, want InvalidArgument", err)
+			}
+			if got := status.Convert(err).Message(); got != tt.want {
+				t.Errorf("message = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalizeLeavesOtherCodes(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(acceptLanguageKey, "es"))
+	err := status.Error(codes.NotFound, "no such thing")
+	if got := localizeError(ctx, err); got != err {
+		t.Errorf("localizeError(NotFound) = %v, want it unchanged", got)
+	}
+}
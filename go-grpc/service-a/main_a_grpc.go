@@ -0,0 +1,740 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	// Registers the gzip compressor so A transparently decompresses
+	// requests from clients that send grpc-encoding: gzip (B's -compress)
+	// and compresses its responses to them the same way.
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/config"
+	"grpc-echo-json/echo"
+	"grpc-echo-json/logging"
+	"grpc-echo-json/tracing"
+	"grpc-echo-json/version"
+)
+
+// --------------------
+// Service A implementation
+// --------------------
+
+type serviceA struct{}
+
+func (serviceA) Health(ctx context.Context, _ *echo.HealthRequest) (*echo.HealthResponse, error) {
+	return &echo.HealthResponse{Status: "ok"}, nil
+}
+
+// GetVersion reports the build of service A, as set by -ldflags at build time.
+func (serviceA) GetVersion(ctx context.Context, _ *echo.VersionRequest) (*echo.VersionResponse, error) {
+	return &echo.VersionResponse{Version: version.Version, Commit: version.Commit, BuildDate: version.BuildDate}, nil
+}
+
+// Ping records when the request arrived and when the reply left, for
+// service B's RTT and clock-skew estimate.
+func (serviceA) Ping(ctx context.Context, _ *echo.PingRequest) (*echo.PongResponse, error) {
+	received := time.Now().UnixNano()
+	return &echo.PongResponse{ReceivedAtUnixNano: received, SentAtUnixNano: time.Now().UnixNano()}, nil
+}
+
+func (s serviceA) Echo(ctx context.Context, req *echo.EchoRequest) (*echo.EchoResponse, error) {
+	out, err := s.echoBody(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &echo.EchoResponse{Echo: decorate(out)}, nil
+}
+
+// echoBody validates req and returns its msg, transformed if asked to, but
+// not yet decorated; modes build their Echo on it.
+func (serviceA) echoBody(ctx context.Context, req *echo.EchoRequest) (string, error) {
+	if err := validateEcho(req); err != nil {
+		return "", err
+	}
+	// Don't answer a caller whose deadline has passed or who hung up.
+	if err := contextError(ctx); err != nil {
+		return "", err
+	}
+	// Keep original behavior: echo back msg, transformed if asked to. The
+	// transform runs on transformPool when -workers is set.
+	var (
+		out string
+		err error
+	)
+	if perr := runTransform(ctx, func() { out, err = applyTransform(req.Transform, req.Msg) }); perr != nil {
+		return "", perr
+	}
+	if err == nil {
+		stats.recordEcho(len(req.Msg))
+	}
+	return out, err
+}
+
+// transformPool bounds how many transforms run at once; set by -workers
+// (nil runs them on the RPC's own goroutine).
+var transformPool *pool
+
+// runTransform runs fn on transformPool. Handing work to the pool is a
+// sub-call of the RPC, so it gets the caller's deadline less subCallBudget
+// (see subCallContext): a call too close to its deadline fails fast, and a
+// backed-up pool gives up while A still has time to answer. Without a pool
+// fn runs inline and there is no hand-off to reserve time for.
+func runTransform(ctx context.Context, fn func()) error {
+	if transformPool == nil {
+		return runRecovered(fn)
+	}
+	ctx, cancel, err := subCallContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	return transformPool.do(ctx, fn)
+}
+
+// contextError returns ctx's error as a gRPC status (Canceled when the
+// caller went away, DeadlineExceeded when its deadline passed), or nil while
+// ctx is live. Handlers check it before doing work nobody will receive.
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
+
+// echoPrefix and echoSuffix wrap every Echo reply, e.g. "[A] " turns "hi"
+// into "[A] hi"; set by -echo-prefix and -echo-suffix. Both default to empty.
+var echoPrefix, echoSuffix string
+
+// decorate adds echoPrefix and echoSuffix. It runs last, after any
+// transform or mode, so the decoration itself is never altered.
+func decorate(s string) string {
+	if echoPrefix == "" && echoSuffix == "" {
+		return s
+	}
+	return echoPrefix + s + echoSuffix
+}
+
+// ReverseEcho returns msg reversed rune by rune, so multibyte characters
+// stay intact.
+func (serviceA) ReverseEcho(ctx context.Context, req *echo.EchoRequest) (*echo.EchoResponse, error) {
+	if err := validateEcho(req); err != nil {
+		return nil, err
+	}
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+	return &echo.EchoResponse{Echo: reverseRunes(req.Msg)}, nil
+}
+
+func reverseRunes(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// EchoChunk returns a rune-based slice of msg, so multibyte characters are
+// never split. Negative offsets or limits are InvalidArgument; a range past
+// the end is clamped (an offset beyond the end yields an empty chunk).
+func (serviceA) EchoChunk(ctx context.Context, req *echo.EchoChunkRequest) (*echo.EchoChunkResponse, error) {
+	if req == nil {
+		return nil, errNilRequest
+	}
+	if err := validateEcho(&echo.EchoRequest{Msg: req.Msg}); err != nil {
+		return nil, err
+	}
+	if req.Offset < 0 || req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset and limit must not be negative")
+	}
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	runes := []rune(req.Msg)
+	start := min(int(req.Offset), len(runes))
+	end := len(runes)
+	if req.Limit > 0 {
+		end = min(start+int(req.Limit), len(runes))
+	}
+	return &echo.EchoChunkResponse{Chunk: string(runes[start:end]), Total: int32(len(runes))}, nil
+}
+
+// maxBatchSize is the most messages BatchEcho accepts; set by -max-batch.
+var maxBatchSize = 100
+
+// BatchEcho echoes every message in the batch, preserving order. Each
+// message is validated like a single Echo, and the batch is abandoned as
+// soon as the caller goes away.
+func (serviceA) BatchEcho(ctx context.Context, req *echo.BatchEchoRequest) (*echo.BatchEchoResponse, error) {
+	if req == nil {
+		return nil, errNilRequest
+	}
+	if len(req.Msgs) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch has %d messages, limit is %d", len(req.Msgs), maxBatchSize)
+	}
+	echoes := make([]string, 0, len(req.Msgs))
+	for i, msg := range req.Msgs {
+		if err := validateEcho(&echo.EchoRequest{Msg: msg}); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "msgs[%d]: %s", i, status.Convert(err).Message())
+		}
+		if err := contextError(ctx); err != nil {
+			return nil, err
+		}
+		echoes = append(echoes, msg)
+	}
+	return &echo.BatchEchoResponse{Echoes: echoes}, nil
+}
+
+// repeatInterval is the pause between RepeatEcho sends; set by
+// -repeat-interval.
+var repeatInterval = 100 * time.Millisecond
+
+// maxRepeatCount bounds how many messages a single RepeatEcho may stream.
+const maxRepeatCount = 1000
+
+// RepeatEcho streams msg back Count times, pausing repeatInterval between
+// sends. It stops as soon as the client goes away.
+func (serviceA) RepeatEcho(req *echo.RepeatEchoRequest, stream echo.EchoService_RepeatEchoServer) error {
+	if req == nil {
+		return errNilRequest
+	}
+	if err := validateEcho(&echo.EchoRequest{Msg: req.Msg}); err != nil {
+		return err
+	}
+	if req.Count < 1 || req.Count > maxRepeatCount {
+		return status.Errorf(codes.InvalidArgument, "count must be between 1 and %d", maxRepeatCount)
+	}
+
+	ctx := stream.Context()
+	t := time.NewTicker(repeatInterval)
+	defer t.Stop()
+	for i := int32(0); i < req.Count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return status.FromContextError(ctx.Err()).Err()
+			case <-t.C:
+			}
+		}
+		if err := stream.Send(&echo.EchoResponse{Echo: req.Msg}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxMsgLen is the longest Msg (in bytes) Echo accepts; set by -max-msg-len.
+var maxMsgLen = 1024
+
+// errNilRequest is returned by handlers called with a nil request. grpc's
+// own handlers always decode into a fresh message, so this only guards
+// in-process callers, such as an interceptor that swaps the request.
+var errNilRequest = status.Error(codes.InvalidArgument, "request must not be nil")
+
+// validateEcho rejects nil requests, empty messages and messages longer
+// than maxMsgLen with codes.InvalidArgument.
+func validateEcho(req *echo.EchoRequest) error {
+	if req == nil {
+		return errNilRequest
+	}
+	if req.Msg == "" {
+		return status.Error(codes.InvalidArgument, "msg must not be empty")
+	}
+	if len(req.Msg) > maxMsgLen {
+		return status.Errorf(codes.InvalidArgument, "msg is %d bytes, limit is %d", len(req.Msg), maxMsgLen)
+	}
+	return nil
+}
+
+// EchoStream echoes every message received on the stream until the client
+// half-closes.
+func (serviceA) EchoStream(stream echo.EchoService_EchoStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&echo.EchoResponse{Echo: req.Msg}); err != nil {
+			return err
+		}
+	}
+}
+
+// ClientStreamEcho reads messages until the client half-closes, then replies
+// once with all of them concatenated in order. Each message is validated
+// like Echo's, and a stream may carry at most maxBatchSize of them.
+func (serviceA) ClientStreamEcho(stream echo.EchoService_ClientStreamEchoServer) error {
+	var b strings.Builder
+	var count int32
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&echo.ClientStreamEchoResponse{Echo: b.String(), Count: count})
+		}
+		if err != nil {
+			return err
+		}
+		if err := validateEcho(req); err != nil {
+			return err
+		}
+		if count++; int(count) > maxBatchSize {
+			return status.Errorf(codes.InvalidArgument, "stream has more than %d messages", maxBatchSize)
+		}
+		b.WriteString(req.Msg)
+	}
+}
+
+// requestIDFromIncoming returns the caller's request id from incoming
+// metadata, or "-" when none was sent.
+func requestIDFromIncoming(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(echo.RequestIDMetadataKey); len(v) > 0 && v[0] != "" {
+			return v[0]
+		}
+	}
+	return "-"
+}
+
+// peerFromContext returns the caller's network address, or "-" when grpc
+// doesn't know it.
+func peerFromContext(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return "-"
+}
+
+// userAgentFromIncoming returns the caller's user-agent metadata (grpc-go
+// sets one such as "grpc-go/1.66.0"), or "-" when none was sent.
+func userAgentFromIncoming(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("user-agent"); len(v) > 0 && v[0] != "" {
+			return v[0]
+		}
+	}
+	return "-"
+}
+
+// slowThreshold is the latency past which a successful call is logged at
+// warn with slow=true instead of at debug; set by -slow-threshold (0
+// disables).
+var slowThreshold time.Duration
+
+// requestLogLevel is logging.CodeLevel, raised to warn for slow successes.
+func requestLogLevel(code codes.Code, elapsed time.Duration) (level slog.Level, slow bool) {
+	level = logging.CodeLevel(code)
+	slow = slowThreshold > 0 && elapsed > slowThreshold
+	if slow && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+	return level, slow
+}
+
+// withClientCanceled marks a log record client_canceled=true when the
+// caller cancelled the call (hung up, or B gave up on it), telling it apart
+// from a Canceled status the handler returned on its own and from a
+// deadline running out.
+func withClientCanceled(ctx context.Context, kv []any) []any {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return append(kv, "client_canceled", true)
+	}
+	return kv
+}
+
+// Basic logging per request: service name, endpoint, status, caller, payload sizes, latency
+func loggingUnaryInterceptor(logger *logging.Logger, serviceName string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		code := status.Code(err)
+		elapsed := time.Since(start)
+		observeRPC(info.FullMethod, code, elapsed)
+		stats.record(code)
+		var respBytes int
+		if err == nil {
+			respBytes = echo.EncodedSize(resp)
+		}
+		level, slow := requestLogLevel(code, elapsed)
+		kv := []any{"service", serviceName, "endpoint", info.FullMethod, "status", code.String(),
+			"request_id", requestIDFromIncoming(ctx), "peer", peerFromContext(ctx), "user_agent", userAgentFromIncoming(ctx),
+			"req_bytes", echo.EncodedSize(req), "resp_bytes", respBytes,
+			"slow", slow, "latency_ms", elapsed.Milliseconds()}
+		logger.Request(level, withClientCanceled(ctx, kv)...)
+		return resp, err
+	}
+}
+
+// countingServerStream counts messages (and their encoded bytes) flowing
+// through a stream so the stream interceptor can report them.
+type countingServerStream struct {
+	grpc.ServerStream
+	recv      int
+	sent      int
+	recvBytes int
+	sentBytes int
+}
+
+func (s *countingServerStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.recv++
+		s.recvBytes += echo.EncodedSize(m)
+	}
+	return err
+}
+
+func (s *countingServerStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		s.sentBytes += echo.EncodedSize(m)
+	}
+	return err
+}
+
+// Basic logging per stream: service name, endpoint, status, caller, message counts and sizes, latency
+func loggingStreamInterceptor(logger *logging.Logger, serviceName string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		cs := &countingServerStream{ServerStream: ss}
+		err := handler(srv, cs)
+		code := status.Code(err)
+		elapsed := time.Since(start)
+		observeRPC(info.FullMethod, code, elapsed)
+		stats.record(code)
+		// Streams are long-lived by design, so they are never flagged slow.
+		kv := []any{"service", serviceName, "endpoint", info.FullMethod, "status", code.String(),
+			"request_id", requestIDFromIncoming(ss.Context()), "peer", peerFromContext(ss.Context()),
+			"user_agent", userAgentFromIncoming(ss.Context()), "msgs_recv", cs.recv, "msgs_sent", cs.sent,
+			"recv_bytes", cs.recvBytes, "sent_bytes", cs.sentBytes, "latency_ms", elapsed.Milliseconds()}
+		logger.Request(logging.CodeLevel(code), withClientCanceled(ss.Context(), kv)...)
+		return err
+	}
+}
+
+// gracefulStop reports NOT_SERVING, so health-checking clients such as B
+// stop picking this replica, then drains in-flight RPCs, falling back to a
+// hard Stop if that takes longer than timeout.
+func gracefulStop(s *grpc.Server, timeout time.Duration) {
+	setServingStatus("", false)
+	setServingStatus(echo.ServiceName, false)
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("service=A graceful stop exceeded %s, forcing stop", timeout)
+		s.Stop()
+	}
+}
+
+func main() {
+	var (
+		listen          string
+		metricsListen   string
+		shutdownTimeout time.Duration
+		tlsCert         string
+		tlsKey          string
+		clientCA        string
+		apiKeys         string
+		serverTimeout   time.Duration
+		reflect         bool
+		logFormat       string
+		logLevel        string
+		logSampleRate   float64
+		otlpEndpoint    string
+		maxRecvMsgSize  int
+		maxSendMsgSize  int
+		maxConcurrent   int
+		maxStreams      int
+		workers         int
+		configPath      string
+		injectLatency   time.Duration
+		injectErrorRate float64
+		adminListen     string
+		mode            string
+		requireMD       string
+		auditPath       string
+		codec           string
+	)
+	// String and duration flags take their defaults from SERVICE_A_<FLAG>
+	// environment variables; see config/env.go for the precedence rules.
+	flag.StringVar(&listen, "listen", config.EnvOr("SERVICE_A_LISTEN", ":50051"), "gRPC listen address for service A, or unix:///path/to.sock for a Unix domain socket")
+	flag.StringVar(&metricsListen, "metrics-listen", config.EnvOr("SERVICE_A_METRICS_LISTEN", ""), "HTTP listen address for Prometheus /metrics, e.g. :9091 (empty disables)")
+	flag.IntVar(&maxMsgLen, "max-msg-len", maxMsgLen, "maximum Echo msg length in bytes")
+	flag.DurationVar(&repeatInterval, "repeat-interval", config.EnvDurationOr("SERVICE_A_REPEAT_INTERVAL", repeatInterval), "pause between messages sent by RepeatEcho")
+	flag.IntVar(&maxBatchSize, "max-batch", maxBatchSize, "maximum number of messages in a BatchEcho call")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", config.EnvDurationOr("SERVICE_A_SHUTDOWN_TIMEOUT", 5*time.Second), "how long to drain in-flight RPCs on shutdown")
+	flag.StringVar(&tlsCert, "tls-cert", config.EnvOr("SERVICE_A_TLS_CERT", ""), "TLS certificate file (enables TLS together with -tls-key)")
+	flag.StringVar(&tlsKey, "tls-key", config.EnvOr("SERVICE_A_TLS_KEY", ""), "TLS private key file")
+	flag.StringVar(&clientCA, "client-ca", config.EnvOr("SERVICE_A_CLIENT_CA", ""), "CA bundle for verifying client certificates (requires mutual TLS)")
+	flag.StringVar(&apiKeys, "api-keys", config.EnvOr("SERVICE_A_API_KEYS", ""), "comma-separated API keys accepted from clients (empty disables auth)")
+	flag.DurationVar(&serverTimeout, "server-timeout", config.EnvDurationOr("SERVICE_A_SERVER_TIMEOUT", 5*time.Second), "deadline applied to unary calls that arrive without one (0 disables)")
+	flag.BoolVar(&reflect, "enable-reflection", false, "register the gRPC reflection service (for grpcurl; keep off in production)")
+	flag.StringVar(&logFormat, "log-format", config.EnvOr("SERVICE_A_LOG_FORMAT", logging.FormatText), "request log format: text or json")
+	flag.StringVar(&logLevel, "log-level", config.EnvOr("SERVICE_A_LOG_LEVEL", "debug"), "minimum request log level: debug (successes), info, warn (client errors) or error")
+	flag.Float64Var(&logSampleRate, "log-sample-rate", 1, "fraction of successful request logs to keep (errors are always logged)")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", config.EnvOr("SERVICE_A_OTLP_ENDPOINT", ""), "OTLP/gRPC collector address for trace export, e.g. localhost:4317 (empty disables tracing)")
+	flag.IntVar(&maxRecvMsgSize, "max-recv-msg-size", 4<<20, "largest encoded gRPC message A accepts, in bytes")
+	flag.IntVar(&maxSendMsgSize, "max-send-msg-size", 4<<20, "largest encoded gRPC message A sends, in bytes")
+	flag.IntVar(&maxConcurrent, "max-concurrent", 0, "maximum RPCs handled at once; extra calls fail with ResourceExhausted (0 disables)")
+	flag.IntVar(&workers, "workers", 0, "size of the worker pool Echo transforms run on; calls wait for a free worker (0 runs them inline)")
+	flag.IntVar(&maxStreams, "max-streams", 0, "maximum concurrent HTTP/2 streams per client connection; extra calls wait on the client (0 means no limit)")
+	flag.DurationVar(&injectLatency, "inject-latency", config.EnvDurationOr("SERVICE_A_INJECT_LATENCY", 0), "artificial delay added to every EchoService call (testing only)")
+	flag.Float64Var(&injectErrorRate, "inject-error-rate", 0, "fraction (0..1) of EchoService calls failed with Unavailable (testing only)")
+	flag.StringVar(&adminListen, "admin-listen", config.EnvOr("SERVICE_A_ADMIN_LISTEN", ""), "HTTP listen address for GET /stats and POST /stats/reset (empty disables)")
+	flag.StringVar(&mode, "mode", config.EnvOr("SERVICE_A_MODE", "echo"), "Echo behavior: echo, reverse or upper")
+	flag.DurationVar(&slowThreshold, "slow-threshold", config.EnvDurationOr("SERVICE_A_SLOW_THRESHOLD", 500*time.Millisecond), "log unary calls slower than this at warn (0 disables)")
+	flag.StringVar(&echoPrefix, "echo-prefix", config.EnvOr("SERVICE_A_ECHO_PREFIX", ""), "text prepended to every Echo reply")
+	flag.StringVar(&echoSuffix, "echo-suffix", config.EnvOr("SERVICE_A_ECHO_SUFFIX", ""), "text appended to every Echo reply")
+	flag.StringVar(&requireMD, "require-metadata", config.EnvOr("SERVICE_A_REQUIRE_METADATA", ""), "comma-separated metadata keys every EchoService call must carry, e.g. tenant-id")
+	flag.StringVar(&auditPath, "audit-log", config.EnvOr("SERVICE_A_AUDIT_LOG", ""), "file to append a JSON audit record to for every RPC (empty disables)")
+	flag.StringVar(&codec, "codec", config.EnvOr("SERVICE_A_CODEC", echo.JSONCodecName), "codec EchoService clients must use (json or proto); other calls fail with InvalidArgument")
+	flag.StringVar(&configPath, "config", config.EnvOr("SERVICE_A_CONFIG", ""), "YAML or JSON file of listen, timeout, TLS, logging and codec settings keyed by flag name; flags given on the command line override it")
+	flag.Parse()
+
+	if configPath != "" {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			log.Fatalf("service=A %v", err)
+		}
+		fs := flag.CommandLine
+		config.Use(fs, "listen", &listen, cfg.Listen)
+		config.Use(fs, "metrics-listen", &metricsListen, cfg.MetricsListen)
+		config.Use(fs, "admin-listen", &adminListen, cfg.AdminListen)
+		config.Use(fs, "server-timeout", &serverTimeout, cfg.ServerTimeout)
+		config.Use(fs, "shutdown-timeout", &shutdownTimeout, cfg.ShutdownTimeout)
+		config.Use(fs, "tls-cert", &tlsCert, cfg.TLSCert)
+		config.Use(fs, "tls-key", &tlsKey, cfg.TLSKey)
+		config.Use(fs, "client-ca", &clientCA, cfg.ClientCA)
+		config.Use(fs, "log-format", &logFormat, cfg.LogFormat)
+		config.Use(fs, "log-level", &logLevel, cfg.LogLevel)
+		config.Use(fs, "otlp-endpoint", &otlpEndpoint, cfg.OTLPEndpoint)
+		config.Use(fs, "codec", &codec, cfg.Codec)
+	}
+
+	level, err := logging.ParseLevel(logLevel)
+	if err != nil {
+		log.Fatalf("service=A invalid -log-level: %v", err)
+	}
+	logger, err := logging.New(os.Stderr, logging.Config{Format: logFormat, Level: level, SampleRate: logSampleRate})
+	if err != nil {
+		log.Fatalf("service=A invalid logging flags: %v", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "service-a", otlpEndpoint)
+	if err != nil {
+		log.Fatalf("service=A failed to set up tracing: %v", err)
+	}
+
+	// The workers live as long as the process.
+	transformPool = newPool(workers)
+
+	impl, err := newServiceA(mode)
+	if err != nil {
+		log.Fatalf("service=A invalid -mode: %v", err)
+	}
+
+	// Service A decodes whichever codec the client declares in its
+	// content-subtype, so both need to be registered; -codec then turns
+	// away EchoService calls in the other one.
+	echo.MustRegisterJSONCodec()
+	echo.RegisterProtoCodec()
+	if err := echo.ValidateCodec(codec); err != nil {
+		log.Fatalf("service=A invalid -codec: %v", err)
+	}
+
+	creds, certs, err := serverCredentials(tlsCert, tlsKey, clientCA)
+	if err != nil {
+		log.Fatalf("service=A failed to load TLS credentials: %v", err)
+	}
+
+	lis, err := listenGRPC(listen)
+	if err != nil {
+		log.Fatalf("service=A failed to listen: %v", err)
+	}
+
+	audit, err := newAuditLog(auditPath)
+	if err != nil {
+		log.Fatalf("service=A invalid -audit-log: %v", err)
+	}
+
+	// Order matters; see chain.go.
+	unary := []grpc.UnaryServerInterceptor{loggingUnaryInterceptor(logger, "A")}
+	stream := []grpc.StreamServerInterceptor{loggingStreamInterceptor(logger, "A")}
+	if audit != nil {
+		unary = append(unary, audit.unaryInterceptor())
+		stream = append(stream, audit.streamInterceptor())
+	}
+	unary = append(unary,
+		timingUnaryInterceptor(),
+		recoveryUnaryInterceptor(),
+		localizeUnaryInterceptor(),
+	)
+	stream = append(stream,
+		recoveryStreamInterceptor(),
+		localizeStreamInterceptor(),
+	)
+	if limiter := newConcurrencyLimiter(maxConcurrent); limiter != nil {
+		unary = append(unary, limiter.unaryInterceptor())
+		stream = append(stream, limiter.streamInterceptor())
+	}
+	unary = append(unary, deadlineUnaryInterceptor(serverTimeout))
+	if keys := parseAPIKeys(apiKeys); len(keys) > 0 {
+		unary = append(unary, authUnaryInterceptor(keys))
+		stream = append(stream, authStreamInterceptor(keys))
+	}
+	unary = append(unary, codecUnaryInterceptor(codec))
+	stream = append(stream, codecStreamInterceptor(codec))
+	unary = append(unary, schemaVersionUnaryInterceptor())
+	stream = append(stream, schemaVersionStreamInterceptor())
+	if keys := parseMetadataKeys(requireMD); len(keys) > 0 {
+		unary = append(unary, requireMetadataInterceptor(keys...))
+		stream = append(stream, requireMetadataStreamInterceptor(keys...))
+	}
+	if injectErrorRate < 0 || injectErrorRate > 1 {
+		log.Fatalf("service=A invalid -inject-error-rate %v: must be between 0 and 1", injectErrorRate)
+	}
+	if faults := newFaultInjector(injectLatency, injectErrorRate, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))); faults != nil {
+		log.Printf("service=A fault injection enabled latency=%s error_rate=%v", injectLatency, injectErrorRate)
+		unary = append(unary, faults.unaryInterceptor())
+	}
+
+	if maxStreams < 0 {
+		log.Fatalf("service=A invalid -max-streams %d: must not be negative", maxStreams)
+	}
+
+	s := grpc.NewServer(
+		grpc.Creds(creds),
+		// 0 is grpc's "no limit"; see concurrency.go for how this differs
+		// from -max-concurrent.
+		grpc.MaxConcurrentStreams(uint32(maxStreams)),
+		// Accept client keepalive pings (service B's -keepalive) as often as
+		// every 10s, even with no active RPCs, instead of closing the
+		// connection with "too_many_pings".
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+		// Messages over either limit fail with ResourceExhausted before
+		// reaching a handler (or after it, for responses).
+		grpc.MaxRecvMsgSize(maxRecvMsgSize),
+		grpc.MaxSendMsgSize(maxSendMsgSize),
+		grpc.UnaryInterceptor(chainUnaryInterceptors(unary...)),
+		grpc.StreamInterceptor(chainStreamInterceptors(stream...)),
+		// Continues the trace started by service B and records a server span
+		// per RPC with its method and status code.
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.StatsHandler(&stats.transport),
+	)
+
+	echo.RegisterEchoServiceServer(s, impl)
+	registerHealthServer(s)
+	if reflect {
+		// EchoService is hand-written rather than generated from echo.proto,
+		// so no file descriptor is registered for it: reflection clients can
+		// list echo.EchoService (e.g. `grpcurl -plaintext :50051 list`) but
+		// can't describe its methods or build requests from the schema.
+		reflection.Register(s)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// kill -HUP rotates the certificate without dropping connections.
+	if certs != nil {
+		go certs.ReloadOnSIGHUP(ctx, "A")
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("service=A gRPC listening on %s (codec=%s, security=%s)", listen, codec, creds.Info().SecurityProtocol)
+		serveErr <- s.Serve(lis)
+	}()
+
+	var metricsSrv *http.Server
+	if metricsListen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		metricsSrv = &http.Server{
+			Addr:              metricsListen,
+			Handler:           mux,
+			ReadHeaderTimeout: 2 * time.Second,
+		}
+		go func() {
+			log.Printf("service=A metrics listening on %s", metricsListen)
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("service=A metrics server failed: %v", err)
+			}
+		}()
+	}
+
+	var adminSrv *http.Server
+	if adminListen != "" {
+		adminSrv = &http.Server{
+			Addr:              adminListen,
+			Handler:           stats.adminMux(),
+			ReadHeaderTimeout: 2 * time.Second,
+		}
+		go func() {
+			log.Printf("service=A admin listening on %s", adminListen)
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("service=A admin server failed: %v", err)
+			}
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		log.Fatalf("service=A serve failed: %v", err)
+	case <-ctx.Done():
+	}
+
+	log.Printf("service=A shutting down")
+	gracefulStop(s, shutdownTimeout)
+	if audit != nil {
+		if err := audit.Close(); err != nil {
+			log.Printf("service=A failed to flush audit log: %v", err)
+		}
+	}
+	if metricsSrv != nil {
+		_ = metricsSrv.Close()
+	}
+	if adminSrv != nil {
+		_ = adminSrv.Close()
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Printf("service=A failed to flush traces: %v", err)
+	}
+	log.Printf("service=A stopped")
+}
@@ -0,0 +1,630 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/logging"
+	"grpc-echo-json/testutil"
+)
+
+// startServiceA serves serviceA over bufconn with serverOpts and returns a
+// client for it.
+func startServiceA(t testing.TB, serverOpts ...grpc.ServerOption) echo.EchoServiceClient {
+	t.Helper()
+	srv, err := testutil.StartEchoServer(serviceA{}, serverOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return srv.Client()
+}
+
+// syncBuffer is a bytes.Buffer safe to write from the server's goroutines
+// while a test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestEchoRoundTrip(t *testing.T) {
+	client := startServiceA(t)
+
+	resp, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "héllo"})
+	if err != nil {
+		t.Fatalf("Echo: %v", err)
+	}
+	if resp.Echo != "héllo" {
+		t.Errorf("Echo = %q, want %q", resp.Echo, "héllo")
+	}
+}
+
+func TestEchoStreamEchoesInOrder(t *testing.T) {
+	client := startServiceA(t)
+	stream, err := client.EchoStream(context.Background())
+	if err != nil {
+		t.Fatalf("EchoStream: %v", err)
+	}
+	msgs := []string{"one", "two", "three"}
+	for _, m := range msgs {
+		if err := stream.Send(&echo.EchoRequest{Msg: m}); err != nil {
+			t.Fatalf("Send(%q): %v", m, err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range msgs {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if resp.Echo != want {
+			t.Errorf("echo = %q, want %q", resp.Echo, want)
+		}
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("after three echoes Recv = %v, want io.EOF", err)
+	}
+}
+
+func TestValidateEcho(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *echo.EchoRequest
+		ok   bool
+	}{
+		{"valid", &echo.EchoRequest{Msg: "hi"}, true},
+		{"at limit", &echo.EchoRequest{Msg: strings.Repeat("a", maxMsgLen)}, true},
+		{"nil", nil, false},
+		{"empty", &echo.EchoRequest{}, false},
+		{"over limit", &echo.EchoRequest{Msg: strings.Repeat("a", maxMsgLen+1)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEcho(tt.req)
+			if tt.ok && err != nil {
+				t.Errorf("validateEcho = %v, want nil", err)
+			}
+			if !tt.ok && status.Code(err) != codes.InvalidArgument {
+				t.Errorf("validateEcho = %v, want InvalidArgument", err)
+			}
+		})
+	}
+}
+
+func TestEchoRejectsEmptyMsg(t *testing.T) {
+	client := startServiceA(t)
+	_, err := client.Echo(context.Background(), &echo.EchoRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("err = %v, want InvalidArgument", err)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	client := startServiceA(t)
+	resp, err := client.Health(context.Background(), &echo.HealthRequest{})
+	if err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if resp.Status != "ok" {
+		t.Errorf("Health status = %q, want ok", resp.Status)
+	}
+
+	registerHealthServer(grpc.NewServer())
+	for _, service := range []string{"", echo.ServiceName} {
+		got, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("Check(%q): %v", service, err)
+		}
+		if got.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Check(%q) = %s, want SERVING", service, got.Status)
+		}
+	}
+}
+
+func TestLoggingInterceptorRecordsStatus(t *testing.T) {
+	var out syncBuffer
+	logger, err := logging.New(&out, logging.Config{Level: slog.LevelDebug, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := startServiceA(t, grpc.UnaryInterceptor(loggingUnaryInterceptor(logger, "A")))
+
+	if _, err := client.Echo(context.Background(), &echo.EchoRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+	if _, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "hi"}); err != nil {
+		t.Fatalf("Echo: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2:\n%s", len(lines), out.String())
+	}
+	for i, want := range []string{"status=InvalidArgument", "status=OK"} {
+		if !strings.Contains(lines[i], "endpoint=/echo.EchoService/Echo") || !strings.Contains(lines[i], want) {
+			t.Errorf("log line %d = %q, want endpoint and %s", i+1, lines[i], want)
+		}
+	}
+}
+
+func TestLoggingInterceptorLogsRequestID(t *testing.T) {
+	var out syncBuffer
+	logger, err := logging.New(&out, logging.Config{Level: slog.LevelDebug, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := startServiceA(t, grpc.UnaryInterceptor(loggingUnaryInterceptor(logger, "A")))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), echo.RequestIDMetadataKey, "req-123")
+	if _, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "request_id=req-123") || !strings.Contains(lines[1], "request_id=-") {
+		t.Errorf("log lines = %q, want request_id=req-123 then request_id=-", lines)
+	}
+}
+
+func TestReverseEcho(t *testing.T) {
+	client := startServiceA(t)
+	tests := []struct{ in, want string }{
+		{"abc", "cba"},
+		{"héllo", "olléh"},
+		{"日本語", "語本日"},
+		{"hi 👋🌍", "🌍👋 ih"},
+		{"x", "x"},
+	}
+	for _, tt := range tests {
+		resp, err := client.ReverseEcho(context.Background(), &echo.EchoRequest{Msg: tt.in})
+		if err != nil {
+			t.Fatalf("ReverseEcho(%q): %v", tt.in, err)
+		}
+		if resp.Echo != tt.want {
+			t.Errorf("ReverseEcho(%q) = %q, want %q", tt.in, resp.Echo, tt.want)
+		}
+	}
+	if _, err := client.ReverseEcho(context.Background(), &echo.EchoRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ReverseEcho of an empty msg: err = %v, want InvalidArgument", err)
+	}
+}
+
+func TestBatchEcho(t *testing.T) {
+	defer func(n int) { maxBatchSize = n }(maxBatchSize)
+	maxBatchSize = 3
+	client := startServiceA(t)
+
+	resp, err := client.BatchEcho(context.Background(), &echo.BatchEchoRequest{Msgs: []string{"c", "a", "b"}})
+	if err != nil {
+		t.Fatalf("BatchEcho: %v", err)
+	}
+	if got := strings.Join(resp.Echoes, ","); got != "c,a,b" {
+		t.Errorf("Echoes = %q, want the input order c,a,b", got)
+	}
+
+	resp, err = client.BatchEcho(context.Background(), &echo.BatchEchoRequest{})
+	if err != nil || len(resp.Echoes) != 0 {
+		t.Errorf("empty batch = %v, %v; want no echoes", resp, err)
+	}
+
+	_, err = client.BatchEcho(context.Background(), &echo.BatchEchoRequest{Msgs: []string{"a", "b", "c", "d"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("over-limit batch: err = %v, want InvalidArgument", err)
+	}
+	_, err = client.BatchEcho(context.Background(), &echo.BatchEchoRequest{Msgs: []string{"a", ""}})
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "msgs[1]") {
+		t.Errorf("batch with an empty msg: err = %v, want InvalidArgument naming msgs[1]", err)
+	}
+}
+
+// sendCountingStream counts the messages a server stream sends.
+type sendCountingStream struct {
+	grpc.ServerStream
+	sent *atomic.Int32
+}
+
+func (s sendCountingStream) SendMsg(m any) error {
+	s.sent.Add(1)
+	return s.ServerStream.SendMsg(m)
+}
+
+func TestRepeatEchoStopsWhenClientCancels(t *testing.T) {
+	defer func(d time.Duration) { repeatInterval = d }(repeatInterval)
+	repeatInterval = 5 * time.Millisecond
+
+	var sent atomic.Int32
+	done := make(chan error, 1)
+	client := startServiceA(t, grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, sendCountingStream{ss, &sent})
+		done <- err
+		return err
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.RepeatEcho(ctx, &echo.RepeatEchoRequest{Msg: "again", Count: maxRepeatCount})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if resp, err := stream.Recv(); err != nil || resp.Echo != "again" {
+			t.Fatalf("Recv %d = %v, %v", i, resp, err)
+		}
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.Canceled {
+			t.Errorf("RepeatEcho returned %v, want Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("RepeatEcho kept running after the client canceled")
+	}
+	after := sent.Load()
+	time.Sleep(5 * repeatInterval)
+	if n := sent.Load(); n != after || n >= maxRepeatCount {
+		t.Errorf("server sent %d messages, then %d more after canceling", after, n-after)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("client Recv after cancel: err = %v, want Canceled", err)
+	}
+}
+
+func TestGetVersionDefaults(t *testing.T) {
+	resp, err := startServiceA(t).GetVersion(context.Background(), &echo.VersionRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Version != "dev" || resp.Commit != "unknown" || resp.BuildDate != "unknown" {
+		t.Errorf("GetVersion without -ldflags = %+v, want dev/unknown/unknown", resp)
+	}
+}
+
+func TestEchoChunkBoundaries(t *testing.T) {
+	client := startServiceA(t)
+	const msg = "héllo, 世界!" // 10 runes, 15 bytes
+	tests := []struct {
+		name          string
+		offset, limit int32
+		want          string
+	}{
+		{"whole message", 0, 0, msg},
+		{"prefix", 0, 5, "héllo"},
+		{"multibyte slice", 7, 2, "世界"},
+		{"limit past the end", 7, 100, "世界!"},
+		{"offset at the end", 10, 1, ""},
+		{"offset past the end", 50, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.EchoChunk(context.Background(), &echo.EchoChunkRequest{Msg: msg, Offset: tt.offset, Limit: tt.limit})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Chunk != tt.want || resp.Total != 10 {
+				t.Errorf("chunk, total = %q, %d; want %q, 10", resp.Chunk, resp.Total, tt.want)
+			}
+		})
+	}
+
+	for _, req := range []*echo.EchoChunkRequest{{Msg: msg, Offset: -1}, {Msg: msg, Limit: -1}} {
+		if _, err := client.EchoChunk(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("EchoChunk(offset %d, limit %d): err = %v, want InvalidArgument", req.Offset, req.Limit, err)
+		}
+	}
+}
+
+func TestRequestLogLevelSlowThreshold(t *testing.T) {
+	defer func(d time.Duration) { slowThreshold = d }(slowThreshold)
+	slowThreshold = 100 * time.Millisecond
+
+	tests := []struct {
+		code      codes.Code
+		elapsed   time.Duration
+		wantLevel slog.Level
+		wantSlow  bool
+	}{
+		{codes.OK, 50 * time.Millisecond, slog.LevelDebug, false},
+		{codes.OK, 100 * time.Millisecond, slog.LevelDebug, false},
+		{codes.OK, 150 * time.Millisecond, slog.LevelWarn, true},
+		{codes.Internal, 150 * time.Millisecond, slog.LevelError, true},
+	}
+	for _, tt := range tests {
+		level, slow := requestLogLevel(tt.code, tt.elapsed)
+		if level != tt.wantLevel || slow != tt.wantSlow {
+			t.Errorf("requestLogLevel(%s, %s) = %s, %t; want %s, %t", tt.code, tt.elapsed, level, slow, tt.wantLevel, tt.wantSlow)
+		}
+	}
+
+	slowThreshold = 0
+	if _, slow := requestLogLevel(codes.OK, time.Hour); slow {
+		t.Error("a zero -slow-threshold still flagged a slow call")
+	}
+}
+
+func TestLoggingInterceptorWarnsOnSlowCalls(t *testing.T) {
+	defer func(d time.Duration) { slowThreshold = d }(slowThreshold)
+	slowThreshold = 30 * time.Millisecond
+
+	var out syncBuffer
+	logger, err := logging.New(&out, logging.Config{Level: slog.LevelDebug, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delay := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if req.(*echo.EchoRequest).Msg == "slow" {
+			time.Sleep(60 * time.Millisecond)
+		}
+		return handler(ctx, req)
+	}
+	client := startServiceA(t, grpc.UnaryInterceptor(chainUnaryInterceptors(loggingUnaryInterceptor(logger, "A"), delay)))
+
+	for _, msg := range []string{"fast", "slow"} {
+		if _, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2:\n%s", len(lines), out.String())
+	}
+	if !strings.Contains(lines[0], "level=DEBUG") || !strings.Contains(lines[0], "slow=false") {
+		t.Errorf("fast call logged %q, want level=DEBUG slow=false", lines[0])
+	}
+	if !strings.Contains(lines[1], "level=WARN") || !strings.Contains(lines[1], "slow=true") {
+		t.Errorf("slow call logged %q, want level=WARN slow=true", lines[1])
+	}
+	for _, line := range lines {
+		if strings.Contains(line, "req_bytes=0") || strings.Contains(line, "resp_bytes=0") {
+			t.Errorf("log line %q lacks payload sizes", line)
+		}
+	}
+}
+
+func TestEchoDecoration(t *testing.T) {
+	defer func(p, s string) { echoPrefix, echoSuffix = p, s }(echoPrefix, echoSuffix)
+
+	tests := []struct {
+		name, prefix, suffix string
+		impl                 echo.EchoServiceServer
+		req                  *echo.EchoRequest
+		want                 string
+	}{
+		{"prefix only", "[A] ", "", serviceA{}, &echo.EchoRequest{Msg: "hi"}, "[A] hi"},
+		{"suffix only", "", " ✓", serviceA{}, &echo.EchoRequest{Msg: "hi"}, "hi ✓"},
+		{"both", "«", "»", serviceA{}, &echo.EchoRequest{Msg: "日本"}, "«日本»"},
+		{"with transform", "[a] ", "", serviceA{}, &echo.EchoRequest{Msg: "hi", Transform: "upper"}, "[a] HI"},
+		{"with reverse mode", "[A] ", "!", reverseServiceA{}, &echo.EchoRequest{Msg: "héllo"}, "[A] olléh!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			echoPrefix, echoSuffix = tt.prefix, tt.suffix
+			resp, err := tt.impl.Echo(context.Background(), tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Echo != tt.want {
+				t.Errorf("Echo = %q, want %q", resp.Echo, tt.want)
+			}
+		})
+	}
+}
+
+func TestPingTimestampsMonotonic(t *testing.T) {
+	client := startServiceA(t)
+	sent := time.Now().UnixNano()
+	resp, err := client.Ping(context.Background(), &echo.PingRequest{SentAtUnixNano: sent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := time.Now().UnixNano()
+	if !(sent <= resp.ReceivedAtUnixNano && resp.ReceivedAtUnixNano <= resp.SentAtUnixNano && resp.SentAtUnixNano <= back) {
+		t.Errorf("timestamps out of order: client sent %d, A received %d, A sent %d, client received %d",
+			sent, resp.ReceivedAtUnixNano, resp.SentAtUnixNano, back)
+	}
+}
+
+func TestEchoReturnsEarlyWhenContextDone(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"canceled", canceled, codes.Canceled},
+		{"deadline passed", expired, codes.DeadlineExceeded},
+	} {
+		if _, err := (serviceA{}).Echo(tt.ctx, &echo.EchoRequest{Msg: "hi"}); status.Code(err) != tt.want {
+			t.Errorf("%s: Echo err = %v, want %s", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestLoggingInterceptorMarksClientCanceled(t *testing.T) {
+	var out syncBuffer
+	logger, err := logging.New(&out, logging.Config{Level: slog.LevelDebug, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interceptor := loggingUnaryInterceptor(logger, "A")
+	info := &grpc.UnaryServerInfo{FullMethod: "/" + echo.ServiceName + "/Echo"}
+	echoHandler := func(ctx context.Context, req any) (any, error) {
+		return serviceA{}.Echo(ctx, req.(*echo.EchoRequest))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := interceptor(ctx, &echo.EchoRequest{Msg: "hi"}, info, echoHandler); status.Code(err) != codes.Canceled {
+		t.Fatalf("Echo for a caller that hung up: err = %v, want Canceled", err)
+	}
+	// A Canceled status the handler chose itself isn't the client's doing.
+	_, _ = interceptor(context.Background(), &echo.EchoRequest{Msg: "hi"}, info, func(context.Context, any) (any, error) {
+		return nil, status.Error(codes.Canceled, "handler gave up")
+	})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2:\n%s", len(lines), out.String())
+	}
+	if !strings.Contains(lines[0], "status=Canceled") || !strings.Contains(lines[0], "client_canceled=true") {
+		t.Errorf("client cancellation logged %q, want status=Canceled client_canceled=true", lines[0])
+	}
+	if !strings.Contains(lines[1], "status=Canceled") || strings.Contains(lines[1], "client_canceled") {
+		t.Errorf("handler's own Canceled logged %q, want no client_canceled", lines[1])
+	}
+}
+
+func TestClientStreamEcho(t *testing.T) {
+	client := startServiceA(t)
+	send := func(msgs ...string) (*echo.ClientStreamEchoResponse, error) {
+		stream, err := client.ClientStreamEcho(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			if err := stream.Send(&echo.EchoRequest{Msg: msg}); err != nil {
+				break // the server already failed the stream; CloseAndRecv reports why
+			}
+		}
+		return stream.CloseAndRecv()
+	}
+
+	resp, err := send("one", "two", "three")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Echo != "onetwothree" || resp.Count != 3 {
+		t.Errorf("response = %+v, want echo onetwothree, count 3", resp)
+	}
+
+	resp, err = send()
+	if err != nil || resp.Echo != "" || resp.Count != 0 {
+		t.Errorf("empty stream = %+v, %v; want an empty response", resp, err)
+	}
+
+	if _, err := send("one", ""); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("stream with an empty msg: err = %v, want InvalidArgument", err)
+	}
+
+	defer func(n int) { maxBatchSize = n }(maxBatchSize)
+	maxBatchSize = 2
+	if _, err := send("a", "b", "c"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("stream over the limit: err = %v, want InvalidArgument", err)
+	}
+}
+
+func TestLoggingInterceptorRecordsPeerAndUserAgent(t *testing.T) {
+	var out syncBuffer
+	logger, err := logging.New(&out, logging.Config{Level: slog.LevelDebug, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := startServiceA(t, grpc.UnaryInterceptor(loggingUnaryInterceptor(logger, "A")))
+	if _, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	line := out.String()
+	// bufconn connections report their address as "bufconn".
+	if !strings.Contains(line, "peer=bufconn") {
+		t.Errorf("log line %q lacks peer=bufconn", line)
+	}
+	if !strings.Contains(line, "user_agent=grpc-go/") {
+		t.Errorf("log line %q lacks the grpc-go user agent", line)
+	}
+}
+
+func TestPeerAndUserAgentDefaults(t *testing.T) {
+	if got := peerFromContext(context.Background()); got != "-" {
+		t.Errorf("peerFromContext without a peer = %q, want -", got)
+	}
+	if got := userAgentFromIncoming(context.Background()); got != "-" {
+		t.Errorf("userAgentFromIncoming without metadata = %q, want -", got)
+	}
+}
+
+// nilRequestStream is a ClientStreamEcho stream whose Recv yields a nil
+// message, as a misbehaving decoder might.
+type nilRequestStream struct {
+	grpc.ServerStream
+}
+
+func (nilRequestStream) Recv() (*echo.EchoRequest, error)                  { return nil, nil }
+func (nilRequestStream) SendAndClose(*echo.ClientStreamEchoResponse) error { return nil }
+
+func TestNilRequestsAreInvalidArgument(t *testing.T) {
+	validating := map[string]bool{"Echo": true, "ReverseEcho": true, "BatchEcho": true, "EchoChunk": true, "Hash": true}
+	// An interceptor that swaps the decoded request for a nil one of the
+	// same type, which is the only way a nil reaches a handler.
+	toNil := func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(ctx, reflect.Zero(reflect.TypeOf(req)).Interface())
+	}
+	dec := func(any) error { return nil }
+
+	for _, mode := range []string{"echo", "reverse", "upper"} {
+		srv, err := newServiceA(mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range echo.EchoService_ServiceDesc.Methods {
+			func() {
+				defer func() {
+					if p := recover(); p != nil {
+						t.Errorf("%s %s with a nil request panicked: %v", mode, m.MethodName, p)
+					}
+				}()
+				_, err := m.Handler(srv, context.Background(), dec, toNil)
+				want := codes.OK
+				if validating[m.MethodName] {
+					want = codes.InvalidArgument
+				}
+				if status.Code(err) != want {
+					t.Errorf("%s %s with a nil request: err = %v, want %s", mode, m.MethodName, err, want)
+				}
+			}()
+		}
+	}
+
+	if err := (serviceA{}).RepeatEcho(nil, nil); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("RepeatEcho(nil): err = %v, want InvalidArgument", err)
+	}
+	if err := (serviceA{}).ClientStreamEcho(nilRequestStream{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ClientStreamEcho receiving nil: err = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/codes"
+)
+
+// --------------------
+// Prometheus metrics (served on -metrics-listen)
+// --------------------
+
+var (
+	rpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "echo_grpc_requests_total",
+		Help: "RPCs handled by service A.",
+	}, []string{"method", "code"})
+
+	rpcErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "echo_grpc_errors_total",
+		Help: "RPCs handled by service A that returned a non-OK status.",
+	}, []string{"method", "code"})
+
+	rpcLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "echo_grpc_latency_seconds",
+		Help:    "Latency of RPCs handled by service A.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "code"})
+)
+
+func observeRPC(method string, code codes.Code, elapsed time.Duration) {
+	c := code.String()
+	rpcRequests.WithLabelValues(method, c).Inc()
+	if code != codes.OK {
+		rpcErrors.WithLabelValues(method, c).Inc()
+	}
+	rpcLatency.WithLabelValues(method, c).Observe(elapsed.Seconds())
+}
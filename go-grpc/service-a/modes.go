@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Service A modes (-mode)
+// --------------------
+
+// modes lists the EchoServiceServer implementations -mode can select. Each
+// embeds serviceA and only changes what Echo returns; every other method,
+// Echo's validation and the -echo-prefix/-echo-suffix decoration are shared.
+var modes = map[string]func() echo.EchoServiceServer{
+	"echo":    func() echo.EchoServiceServer { return serviceA{} },
+	"reverse": func() echo.EchoServiceServer { return reverseServiceA{} },
+	"upper":   func() echo.EchoServiceServer { return upperServiceA{} },
+}
+
+// newServiceA returns the implementation for mode, or an error naming the
+// valid modes.
+func newServiceA(mode string) (echo.EchoServiceServer, error) {
+	newFn, ok := modes[mode]
+	if !ok {
+		names := make([]string, 0, len(modes))
+		for name := range modes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown mode %q (want one of %s)", mode, strings.Join(names, ", "))
+	}
+	return newFn(), nil
+}
+
+// reverseServiceA echoes msg reversed, like ReverseEcho.
+type reverseServiceA struct{ serviceA }
+
+func (s reverseServiceA) Echo(ctx context.Context, req *echo.EchoRequest) (*echo.EchoResponse, error) {
+	body, err := s.echoBody(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &echo.EchoResponse{Echo: decorate(reverseRunes(body))}, nil
+}
+
+// upperServiceA echoes msg upper-cased, whatever Transform asks for.
+type upperServiceA struct{ serviceA }
+
+func (s upperServiceA) Echo(ctx context.Context, req *echo.EchoRequest) (*echo.EchoResponse, error) {
+	body, err := s.echoBody(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &echo.EchoResponse{Echo: decorate(strings.ToUpper(body))}, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/testutil"
+)
+
+func TestModes(t *testing.T) {
+	tests := []struct {
+		mode, in, want string
+	}{
+		{"echo", "héllo", "héllo"},
+		{"reverse", "héllo", "olléh"},
+		{"upper", "héllo", "HÉLLO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			impl, err := newServiceA(tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv, err := testutil.StartEchoServer(impl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Close()
+
+			resp, err := srv.Client().Echo(context.Background(), &echo.EchoRequest{Msg: tt.in})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Echo != tt.want {
+				t.Errorf("-mode %s: Echo(%q) = %q, want %q", tt.mode, tt.in, resp.Echo, tt.want)
+			}
+			// Modes only change Echo; validation is shared.
+			if _, err := srv.Client().Echo(context.Background(), &echo.EchoRequest{}); err == nil {
+				t.Errorf("-mode %s accepted an empty msg", tt.mode)
+			}
+		})
+	}
+}
+
+func TestNewServiceAUnknownMode(t *testing.T) {
+	_, err := newServiceA("shout")
+	if err == nil {
+		t.Fatal("newServiceA accepted an unknown mode")
+	}
+	if !strings.Contains(err.Error(), "echo, reverse, upper") {
+		t.Errorf("error %q doesn't list the valid modes", err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import "context"
+
+// --------------------
+// Worker pool for CPU-bound work (-workers)
+// --------------------
+
+// pool runs functions on a fixed set of worker goroutines, so CPU-heavy
+// work (such as an expensive transform) is bounded by the pool size rather
+// than by how many RPCs are in flight. A nil *pool runs work inline on the
+// caller's goroutine.
+type pool struct {
+	tasks chan func()
+}
+
+// newPool starts size workers, or returns nil (no pool) when size <= 0.
+func newPool(size int) *pool {
+	if size <= 0 {
+		return nil
+	}
+	// Unbuffered, so a submit blocks until a worker is actually free.
+	p := &pool{tasks: make(chan func())}
+	for range size {
+		go func() {
+			for fn := range p.tasks {
+				fn()
+			}
+		}()
+	}
+	return p
+}
+
+// do runs fn on a worker and waits for it to finish. While every worker is
+// busy it waits for one to free up, giving up with ctx's error if ctx is
+// done first; once fn has started it runs to completion. A panic in fn is
+// logged and returned as codes.Internal: the recovery interceptor can't see
+// panics on a worker goroutine, and one would otherwise crash the server.
+func (p *pool) do(ctx context.Context, fn func()) error {
+	if p == nil {
+		return runRecovered(fn)
+	}
+	done := make(chan error, 1)
+	select {
+	case p.tasks <- func() { done <- runRecovered(fn) }:
+	case <-ctx.Done():
+		return contextError(ctx)
+	}
+	return <-done
+}
+
+// runRecovered calls fn, turning a panic into errInternal.
+func runRecovered(fn func()) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logPanic("pool", p)
+			err = errInternal
+		}
+	}()
+	fn()
+	return nil
+}
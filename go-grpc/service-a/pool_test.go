@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPoolBoundsConcurrency(t *testing.T) {
+	const size = 3
+	p := newPool(size)
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.do(context.Background(), func() {
+				n := running.Add(1)
+				for {
+					old := peak.Load()
+					if n <= old || peak.CompareAndSwap(old, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				running.Add(-1)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := peak.Load(); got > size {
+		t.Errorf("%d tasks ran at once, pool size is %d", got, size)
+	}
+}
+
+func TestPoolGivesUpWhenSaturated(t *testing.T) {
+	p := newPool(1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go p.do(context.Background(), func() { close(started); <-release })
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ran := false
+	if err := p.do(ctx, func() { ran = true }); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	}
+	if ran {
+		t.Error("task ran although no worker was free")
+	}
+}
+
+func TestPoolRecoversPanics(t *testing.T) {
+	for _, p := range []*pool{newPool(1), nil} {
+		if err := p.do(context.Background(), func() { panic("boom") }); status.Code(err) != codes.Internal {
+			t.Fatalf("pool %v: err = %v, want Internal", p, err)
+		}
+		ran := false
+		if err := p.do(context.Background(), func() { ran = true }); err != nil || !ran {
+			t.Errorf("pool %v after a panic: err = %v, ran = %t", p, err, ran)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// --------------------
+// Panic recovery
+// --------------------
+
+var errInternal = status.Error(codes.Internal, "internal error")
+
+func logPanic(fullMethod string, p any) {
+	log.Printf("service=A endpoint=%s status=panic panic=%q\n%s", fullMethod, p, debug.Stack())
+}
+
+// recoveryUnaryInterceptor turns a panicking handler into codes.Internal for
+// that call instead of crashing the whole server.
+func recoveryUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				logPanic(info.FullMethod, p)
+				resp, err = nil, errInternal
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// recoveryStreamInterceptor is the streaming counterpart of
+// recoveryUnaryInterceptor.
+func recoveryStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				logPanic(info.FullMethod, p)
+				err = errInternal
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
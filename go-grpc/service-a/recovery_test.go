@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/testutil"
+)
+
+// panickyEcho panics on the message "panic" and echoes anything else.
+type panickyEcho struct{ serviceA }
+
+func (p panickyEcho) Echo(ctx context.Context, req *echo.EchoRequest) (*echo.EchoResponse, error) {
+	if req.Msg == "panic" {
+		panic("boom")
+	}
+	return p.serviceA.Echo(ctx, req)
+}
+
+func TestRecoveryUnaryInterceptor(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	srv, err := testutil.StartEchoServer(panickyEcho{}, grpc.UnaryInterceptor(recoveryUnaryInterceptor()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+
+	_, err = client.Echo(context.Background(), &echo.EchoRequest{Msg: "panic"})
+	if status.Code(err) != codes.Internal || status.Convert(err).Message() != "internal error" {
+		t.Fatalf("panicking Echo = %v, want Internal \"internal error\"", err)
+	}
+
+	// The server survives the panic and keeps serving on the same connection.
+	resp, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "still here"})
+	if err != nil || resp.Echo != "still here" {
+		t.Errorf("Echo after panic = %v, %v", resp, err)
+	}
+}
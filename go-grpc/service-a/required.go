@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// --------------------
+// Required metadata (-require-metadata)
+// --------------------
+
+// parseMetadataKeys splits a comma-separated list of metadata keys,
+// lower-casing them as gRPC metadata keys always are.
+func parseMetadataKeys(list string) []string {
+	var keys []string
+	for _, k := range strings.Split(list, ",") {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// missingMetadata returns the keys with no non-empty value in ctx's
+// incoming metadata, as an InvalidArgument error, or nil if none are
+// missing. Health checks are exempt, like they are from auth.
+func missingMetadata(ctx context.Context, fullMethod string, keys []string) error {
+	if authExempt(fullMethod) {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var missing []string
+	for _, k := range keys {
+		if v := md.Get(k); len(v) == 0 || v[0] == "" {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		return status.Errorf(codes.InvalidArgument, "missing required metadata: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// requireMetadataInterceptor rejects calls that don't carry every one of
+// keys in their metadata.
+func requireMetadataInterceptor(keys ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := missingMetadata(ctx, info.FullMethod, keys); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// requireMetadataStreamInterceptor is the streaming counterpart of
+// requireMetadataInterceptor.
+func requireMetadataStreamInterceptor(keys ...string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := missingMetadata(ss.Context(), info.FullMethod, keys); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+func TestRequireMetadataInterceptor(t *testing.T) {
+	client := startServiceA(t, grpc.UnaryInterceptor(requireMetadataInterceptor(parseMetadataKeys("x-tenant, X-Caller")...)))
+
+	tests := []struct {
+		name    string
+		md      metadata.MD
+		want    codes.Code
+		missing string
+	}{
+		{"none", nil, codes.InvalidArgument, "x-tenant, x-caller"},
+		{"one", metadata.Pairs("x-tenant", "acme"), codes.InvalidArgument, "x-caller"},
+		{"empty value", metadata.Pairs("x-tenant", "acme", "x-caller", ""), codes.InvalidArgument, "x-caller"},
+		{"all", metadata.Pairs("x-tenant", "acme", "x-caller", "b"), codes.OK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewOutgoingContext(ctx, tt.md)
+			}
+			_, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"})
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("Echo code = %s, want %s (err %v)", got, tt.want, err)
+			}
+			if tt.missing != "" && !strings.HasSuffix(status.Convert(err).Message(), ": "+tt.missing) {
+				t.Errorf("message = %q, want it to list %q", status.Convert(err).Message(), tt.missing)
+			}
+		})
+	}
+}
+
+func TestRequireMetadataExemptsHealth(t *testing.T) {
+	interceptor := requireMetadataInterceptor("x-tenant")
+	if err := callThrough(context.Background(), interceptor, "/grpc.health.v1.Health/Check"); err != nil {
+		t.Errorf("health check without metadata = %v, want nil", err)
+	}
+	if err := callThrough(context.Background(), interceptor, "/"+echo.ServiceName+"/Echo"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Echo without metadata = %v, want InvalidArgument", err)
+	}
+}
+
+func TestParseMetadataKeys(t *testing.T) {
+	got := parseMetadataKeys(" X-Tenant,,x-caller , ")
+	if want := []string{"x-tenant", "x-caller"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMetadataKeys = %q, want %q", got, want)
+	}
+}
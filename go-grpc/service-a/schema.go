@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Schema version check (schema-version metadata)
+// --------------------
+
+// The range of caller schema versions service A understands. Raise
+// minSchemaVersion when A stops accepting messages from older callers.
+const (
+	minSchemaVersion = 1
+	maxSchemaVersion = echo.SchemaVersion
+)
+
+// checkSchemaVersion rejects calls whose schema-version metadata is outside
+// [minSchemaVersion, maxSchemaVersion] with codes.FailedPrecondition, and
+// malformed versions with codes.InvalidArgument. Calls without the key
+// (grpcurl, older tooling) and health checks are let through.
+func checkSchemaVersion(ctx context.Context, fullMethod string) error {
+	if authExempt(fullMethod) {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	v := md.Get(echo.SchemaVersionMetadataKey)
+	if len(v) == 0 {
+		return nil
+	}
+	n, err := strconv.Atoi(v[0])
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid %s %q: must be an integer", echo.SchemaVersionMetadataKey, v[0])
+	}
+	if n < minSchemaVersion || n > maxSchemaVersion {
+		return status.Errorf(codes.FailedPrecondition, "schema version %d is not supported: service A accepts %d to %d",
+			n, minSchemaVersion, maxSchemaVersion)
+	}
+	return nil
+}
+
+// schemaVersionUnaryInterceptor rejects calls from incompatible callers
+// before they reach the handler.
+func schemaVersionUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkSchemaVersion(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// schemaVersionStreamInterceptor is the streaming counterpart of
+// schemaVersionUnaryInterceptor.
+func schemaVersionStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkSchemaVersion(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+func TestSchemaVersionInterceptor(t *testing.T) {
+	client := startServiceA(t, grpc.UnaryInterceptor(schemaVersionUnaryInterceptor()))
+
+	tests := []struct {
+		name    string
+		version string
+		want    codes.Code
+	}{
+		{"absent", "", codes.OK},
+		{"oldest supported", strconv.Itoa(minSchemaVersion), codes.OK},
+		{"newest supported", strconv.Itoa(maxSchemaVersion), codes.OK},
+		{"too old", strconv.Itoa(minSchemaVersion - 1), codes.FailedPrecondition},
+		{"too new", strconv.Itoa(maxSchemaVersion + 1), codes.FailedPrecondition},
+		{"malformed", "v2", codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.version != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, echo.SchemaVersionMetadataKey, tt.version)
+			}
+			_, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("Echo code = %s, want %s (err %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSchemaVersionExemptsHealth(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(echo.SchemaVersionMetadataKey, strconv.Itoa(maxSchemaVersion+1)))
+	if err := checkSchemaVersion(ctx, "/grpc.health.v1.Health/Check"); err != nil {
+		t.Errorf("health check from a newer caller = %v, want nil", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"grpc-echo-json/echo"
+)
+
+// slowEcho answers Echo once release is closed, signalling started first;
+// it gives up if the call is cancelled.
+type slowEcho struct {
+	serviceA
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s slowEcho) Echo(ctx context.Context, req *echo.EchoRequest) (*echo.EchoResponse, error) {
+	close(s.started)
+	select {
+	case <-s.release:
+		return s.serviceA.Echo(ctx, req)
+	case <-ctx.Done():
+		return nil, contextError(ctx)
+	}
+}
+
+// serveBufconn serves impl on a bufconn listener with serverOpts and
+// returns the server and a client for it; the caller stops the server.
+func serveBufconn(t *testing.T, impl echo.EchoServiceServer, serverOpts ...grpc.ServerOption) (*grpc.Server, echo.EchoServiceClient) {
+	t.Helper()
+	echo.RegisterCodecs()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(serverOpts...)
+	echo.RegisterEchoServiceServer(s, impl)
+	go func() { _ = s.Serve(lis) }()
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, echo.NewEchoServiceClient(conn)
+}
+
+func TestGracefulStopFinishesInFlightCalls(t *testing.T) {
+	impl := slowEcho{started: make(chan struct{}), release: make(chan struct{})}
+	s, client := serveBufconn(t, impl)
+	t.Cleanup(func() { setServingStatus("", true); setServingStatus(echo.ServiceName, true) })
+
+	callErr := make(chan error, 1)
+	go func() {
+		_, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "hi"})
+		callErr <- err
+	}()
+	<-impl.started
+
+	stopped := make(chan struct{})
+	go func() { gracefulStop(s, 5*time.Second); close(stopped) }()
+	select {
+	case <-stopped:
+		t.Fatal("gracefulStop returned with a call in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(impl.release)
+	if err := <-callErr; err != nil {
+		t.Errorf("in-flight call failed: %v", err)
+	}
+	<-stopped
+	for _, service := range []string{"", echo.ServiceName} {
+		if got := checkHealth(t, service); got != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("health of %q after gracefulStop = %s, want NOT_SERVING", service, got)
+		}
+	}
+	if _, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "late"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("call after stop: err = %v, want Unavailable", err)
+	}
+}
+
+func TestGracefulStopForcesAfterTimeout(t *testing.T) {
+	impl := slowEcho{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(impl.release)
+	s, client := serveBufconn(t, impl)
+	t.Cleanup(func() { setServingStatus("", true); setServingStatus(echo.ServiceName, true) })
+
+	callErr := make(chan error, 1)
+	go func() {
+		_, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "hi"})
+		callErr <- err
+	}()
+	<-impl.started
+
+	start := time.Now()
+	gracefulStop(s, 20*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gracefulStop took %s with a 20ms timeout", elapsed)
+	}
+	if err := <-callErr; status.Code(err) != codes.Unavailable {
+		t.Errorf("call cut off by the forced stop: err = %v, want Unavailable", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Runtime stats (served on -admin-listen)
+// --------------------
+
+// serverStats counts RPCs for GET /stats. Unlike the Prometheus counters it
+// can be zeroed with POST /stats/reset, which is handy between demo runs.
+type serverStats struct {
+	started   time.Time
+	requests  atomic.Int64
+	errors    atomic.Int64
+	echoCalls atomic.Int64
+	echoBytes atomic.Int64
+	transport transportStats
+}
+
+var stats = &serverStats{started: time.Now()}
+
+// record counts one finished RPC; the logging interceptors call it for every
+// call, alongside observeRPC.
+func (s *serverStats) record(code codes.Code) {
+	s.requests.Add(1)
+	if code != codes.OK {
+		s.errors.Add(1)
+	}
+}
+
+// recordEcho counts one successful Echo of an n-byte msg; echoBody calls it,
+// so every -mode is counted.
+func (s *serverStats) recordEcho(n int) {
+	s.echoCalls.Add(1)
+	s.echoBytes.Add(int64(n))
+}
+
+// Stats reports the Echo counters over gRPC, the same numbers GET /stats
+// shows on the admin listener.
+func (serviceA) Stats(ctx context.Context, req *echo.StatsRequest) (*echo.StatsResponse, error) {
+	return &echo.StatsResponse{EchoCalls: stats.echoCalls.Load(), EchoBytes: stats.echoBytes.Load()}, nil
+}
+
+func (s *serverStats) reset() {
+	s.requests.Store(0)
+	s.errors.Store(0)
+	s.echoCalls.Store(0)
+	s.echoBytes.Store(0)
+	s.transport.reset()
+}
+
+func writeStatsJSON(w http.ResponseWriter, httpStatus int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// adminMux serves GET /stats and POST /stats/reset.
+func (s *serverStats) adminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeStatsJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			return
+		}
+		writeStatsJSON(w, http.StatusOK, map[string]any{
+			"uptime_s":       int64(time.Since(s.started).Seconds()),
+			"goroutines":     runtime.NumGoroutine(),
+			"requests_total": s.requests.Load(),
+			"errors_total":   s.errors.Load(),
+			"echo_calls":     s.echoCalls.Load(),
+			"echo_bytes":     s.echoBytes.Load(),
+			"transport":      s.transport.snapshot(),
+		})
+	})
+	mux.HandleFunc("/stats/reset", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeStatsJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+			return
+		}
+		s.reset()
+		writeStatsJSON(w, http.StatusOK, map[string]any{"reset": true})
+	})
+	return mux
+}
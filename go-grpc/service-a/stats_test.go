@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/logging"
+)
+
+// getStats fetches GET /stats from mux.
+func getStats(t *testing.T, mux http.Handler) map[string]any {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /stats: status = %d", rec.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestStatsCountAndReset(t *testing.T) {
+	logger, err := logging.New(io.Discard, logging.Config{SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := startServiceA(t, grpc.UnaryInterceptor(loggingUnaryInterceptor(logger, "A")))
+	stats.reset()
+	mux := stats.adminMux()
+
+	for _, msg := range []string{"one", "two", ""} {
+		_, _ = client.Echo(context.Background(), &echo.EchoRequest{Msg: msg})
+	}
+	body := getStats(t, mux)
+	want := map[string]float64{"requests_total": 3, "errors_total": 1, "echo_calls": 2, "echo_bytes": 6}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("%s = %v, want %v", k, body[k], v)
+		}
+	}
+	if g, _ := body["goroutines"].(float64); g < 1 {
+		t.Errorf("goroutines = %v, want at least 1", body["goroutines"])
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/stats/reset", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /stats/reset: status = %d", rec.Code)
+	}
+	body = getStats(t, mux)
+	for k := range want {
+		if body[k] != float64(0) {
+			t.Errorf("after reset: %s = %v, want 0", k, body[k])
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/reset", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /stats/reset: status = %d, want 405", rec.Code)
+	}
+}
+
+func TestStatsRPCCountsEchoes(t *testing.T) {
+	client := startServiceA(t)
+	stats.reset()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, msg := range []string{"a", "bb", "ccc", ""} {
+				_, _ = client.Echo(context.Background(), &echo.EchoRequest{Msg: msg})
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp, err := client.Stats(context.Background(), &echo.StatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The empty msg is rejected and not counted.
+	if resp.EchoCalls != 30 || resp.EchoBytes != 60 {
+		t.Errorf("Stats = %d calls, %d bytes; want 30, 60", resp.EchoCalls, resp.EchoBytes)
+	}
+}
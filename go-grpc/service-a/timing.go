@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// --------------------
+// Server timing trailers
+// --------------------
+
+// Trailer keys service A reports its own handling time under, so callers
+// can tell A's latency apart from the network's.
+const (
+	serverLatencyTrailer = "server-latency-ms"
+	serverHandlerTrailer = "server-handler"
+)
+
+// timingUnaryInterceptor attaches the server-latency-ms and server-handler
+// trailers to every unary response, successful or not.
+func timingUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(
+			serverLatencyTrailer, strconv.FormatInt(time.Since(start).Milliseconds(), 10),
+			serverHandlerTrailer, info.FullMethod,
+		))
+		return resp, err
+	}
+}
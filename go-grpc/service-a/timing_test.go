@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"grpc-echo-json/echo"
+)
+
+func TestTimingTrailers(t *testing.T) {
+	client := startServiceA(t, grpc.UnaryInterceptor(timingUnaryInterceptor()))
+
+	for _, req := range []*echo.EchoRequest{{Msg: "hi"}, {}} {
+		var trailer metadata.MD
+		_, err := client.Echo(context.Background(), req, grpc.Trailer(&trailer))
+
+		v := trailer.Get(serverLatencyTrailer)
+		if len(v) != 1 || v[0] == "" {
+			t.Fatalf("Echo(%q) err %v: %s trailer = %q, want one value", req.Msg, err, serverLatencyTrailer, v)
+		}
+		if ms, perr := strconv.ParseInt(v[0], 10, 64); perr != nil || ms < 0 {
+			t.Errorf("%s = %q, want a non-negative integer", serverLatencyTrailer, v[0])
+		}
+		if h := trailer.Get(serverHandlerTrailer); len(h) != 1 || h[0] != "/echo.EchoService/Echo" {
+			t.Errorf("%s = %q, want /echo.EchoService/Echo", serverHandlerTrailer, h)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"grpc-echo-json/tlsreload"
+)
+
+// serverCredentials returns TLS credentials for -tls-cert/-tls-key, or
+// plaintext credentials when neither is set. With -client-ca, clients must
+// also present a certificate signed by that CA (mutual TLS). Over TLS it
+// also returns the reloader serving the certificate, for SIGHUP rotation;
+// it is nil for plaintext.
+func serverCredentials(certFile, keyFile, clientCAFile string) (credentials.TransportCredentials, *tlsreload.Reloader, error) {
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, nil, errors.New("-client-ca requires -tls-cert and -tls-key")
+		}
+		return insecure.NewCredentials(), nil, nil
+	}
+	cfg, certs, err := buildServerTLS(certFile, keyFile, clientCAFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return credentials.NewTLS(cfg), certs, nil
+}
+
+// buildServerTLS loads service A's certificate and, if clientCAFile is set,
+// requires and verifies client certificates against it. The certificate is
+// served through the returned reloader, so a reload applies to new
+// connections.
+func buildServerTLS(certFile, keyFile, clientCAFile string) (*tls.Config, *tlsreload.Reloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, nil, errors.New("-tls-cert and -tls-key must be set together")
+	}
+	certs, err := tlsreload.New(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := &tls.Config{
+		GetCertificate: certs.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, certs, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/testutil"
+)
+
+// newTestCA returns a fresh CA for the test.
+func newTestCA(t *testing.T, name string) *testutil.CA {
+	t.Helper()
+	ca, err := testutil.NewCA(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+// writeFile writes data to name in dir and returns its path.
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// issueFiles issues a certificate for name from ca and writes it and its
+// key to dir, returning their paths.
+func issueFiles(t *testing.T, ca *testutil.CA, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	certPEM, keyPEM, err := ca.Issue(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeFile(t, dir, name+".crt", certPEM), writeFile(t, dir, name+".key", keyPEM)
+}
+
+// serveTLS serves serviceA over TLS built by buildServerTLS on a local TCP
+// port and returns its address.
+func serveTLS(t *testing.T, certFile, keyFile, clientCAFile string) string {
+	t.Helper()
+	echo.RegisterCodecs()
+	cfg, _, err := buildServerTLS(certFile, keyFile, clientCAFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(cfg)))
+	echo.RegisterEchoServiceServer(s, serviceA{})
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+// echoOverTLS makes one Echo call to addr with cfg as the client's TLS
+// config.
+func echoOverTLS(t *testing.T, addr string, cfg *tls.Config) error {
+	t.Helper()
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(credentials.NewTLS(cfg)),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := echo.NewEchoServiceClient(conn).Echo(ctx, &echo.EchoRequest{Msg: "over tls"})
+	if err == nil && resp.Echo != "over tls" {
+		t.Errorf("Echo = %q over TLS", resp.Echo)
+	}
+	return err
+}
+
+func rootsOf(ca *testutil.CA) *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.PEM)
+	return pool
+}
+
+func TestEchoOverTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "test CA")
+	certFile, keyFile := issueFiles(t, ca, dir, "service-a")
+	addr := serveTLS(t, certFile, keyFile, "")
+
+	if err := echoOverTLS(t, addr, &tls.Config{RootCAs: rootsOf(ca)}); err != nil {
+		t.Fatalf("Echo over TLS: %v", err)
+	}
+	if err := echoOverTLS(t, addr, &tls.Config{RootCAs: rootsOf(newTestCA(t, "other CA"))}); err == nil {
+		t.Error("client trusting another CA accepted service A's certificate")
+	}
+}
+
+func TestBuildServerTLSRequiresCertAndKey(t *testing.T) {
+	if _, _, err := buildServerTLS("a.crt", "", ""); err == nil {
+		t.Error("buildServerTLS accepted a certificate without a key")
+	}
+	if _, _, err := serverCredentials("", "", "ca.pem"); err == nil {
+		t.Error("serverCredentials accepted -client-ca without a certificate")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCA := newTestCA(t, "server CA")
+	clientCA := newTestCA(t, "client CA")
+	certFile, keyFile := issueFiles(t, serverCA, dir, "service-a")
+	addr := serveTLS(t, certFile, keyFile, writeFile(t, dir, "client-ca.pem", clientCA.PEM))
+
+	clientCert := func(ca *testutil.CA) []tls.Certificate {
+		certPEM, keyPEM, err := ca.Issue("service-b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []tls.Certificate{cert}
+	}
+
+	trusted := &tls.Config{RootCAs: rootsOf(serverCA), Certificates: clientCert(clientCA)}
+	if err := echoOverTLS(t, addr, trusted); err != nil {
+		t.Fatalf("Echo with a certificate from the client CA: %v", err)
+	}
+	wrongCA := &tls.Config{RootCAs: rootsOf(serverCA), Certificates: clientCert(newTestCA(t, "rogue CA"))}
+	if err := echoOverTLS(t, addr, wrongCA); err == nil {
+		t.Error("service A accepted a client certificate from another CA")
+	}
+	if err := echoOverTLS(t, addr, &tls.Config{RootCAs: rootsOf(serverCA)}); err == nil {
+		t.Error("service A accepted a client without a certificate")
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// --------------------
+// Echo transforms
+// --------------------
+
+// transforms maps each EchoRequest.Transform value to the function Echo
+// applies to msg. An empty Transform means "none" so older clients get the
+// message back unchanged.
+var transforms = map[string]func(string) string{
+	"":      func(s string) string { return s },
+	"none":  func(s string) string { return s },
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// A cases.Caser is stateful and must not be shared between goroutines.
+	"title": func(s string) string { return cases.Title(language.Und).String(s) },
+}
+
+// applyTransform returns msg transformed by name, or InvalidArgument for an
+// unknown transform.
+func applyTransform(name, msg string) (string, error) {
+	fn, ok := transforms[name]
+	if !ok {
+		return "", status.Errorf(codes.InvalidArgument, "unknown transform %q (want none, upper, lower or title)", name)
+	}
+	return fn(msg), nil
+}
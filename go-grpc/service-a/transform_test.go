@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+func TestApplyTransform(t *testing.T) {
+	tests := []struct {
+		transform, in, want string
+	}{
+		{"", "Hello Wörld", "Hello Wörld"},
+		{"none", "Hello Wörld", "Hello Wörld"},
+		{"upper", "ça va", "ÇA VA"},
+		{"upper", "héllo ñandú", "HÉLLO ÑANDÚ"},
+		{"lower", "ΑΘΗΝΑ", "αθηνα"},
+		{"lower", "ÉCOLE", "école"},
+		{"title", "hello wörld", "Hello Wörld"},
+		{"title", "élan vital", "Élan Vital"},
+		{"upper", "日本語 👋", "日本語 👋"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transform+"/"+tt.in, func(t *testing.T) {
+			got, err := applyTransform(tt.transform, tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("applyTransform(%q, %q) = %q, want %q", tt.transform, tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoTransform(t *testing.T) {
+	client := startServiceA(t)
+
+	resp, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "héllo", Transform: "upper"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Echo != "HÉLLO" {
+		t.Errorf("Echo upper = %q, want HÉLLO", resp.Echo)
+	}
+
+	_, err = client.Echo(context.Background(), &echo.EchoRequest{Msg: "hi", Transform: "shout"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unknown transform: err = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	grpcstats "google.golang.org/grpc/stats"
+)
+
+// --------------------
+// Transport-level stats (grpc stats.Handler)
+// --------------------
+
+// transportStats is a grpc stats.Handler counting what the interceptors
+// can't see: connections, and RPCs and payload bytes as they cross the
+// transport, including calls rejected before any interceptor runs (e.g.
+// oversized messages). Its counts appear under "transport" in GET /stats.
+// (The grpc package is imported as grpcstats because stats is A's
+// serverStats.)
+type transportStats struct {
+	connsAccepted atomic.Int64
+	connsOpen     atomic.Int64
+	rpcsBegun     atomic.Int64
+	rpcsEnded     atomic.Int64
+	bytesRecv     atomic.Int64
+	bytesSent     atomic.Int64
+}
+
+func (t *transportStats) TagRPC(ctx context.Context, _ *grpcstats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (t *transportStats) HandleRPC(_ context.Context, s grpcstats.RPCStats) {
+	switch s := s.(type) {
+	case *grpcstats.Begin:
+		t.rpcsBegun.Add(1)
+	case *grpcstats.End:
+		t.rpcsEnded.Add(1)
+	case *grpcstats.InPayload:
+		t.bytesRecv.Add(int64(s.WireLength))
+	case *grpcstats.OutPayload:
+		t.bytesSent.Add(int64(s.WireLength))
+	}
+}
+
+func (t *transportStats) TagConn(ctx context.Context, _ *grpcstats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (t *transportStats) HandleConn(_ context.Context, s grpcstats.ConnStats) {
+	switch s.(type) {
+	case *grpcstats.ConnBegin:
+		t.connsAccepted.Add(1)
+		t.connsOpen.Add(1)
+	case *grpcstats.ConnEnd:
+		t.connsOpen.Add(-1)
+	}
+}
+
+// snapshot returns the counts for GET /stats.
+func (t *transportStats) snapshot() map[string]int64 {
+	return map[string]int64{
+		"connections_accepted": t.connsAccepted.Load(),
+		"connections_open":     t.connsOpen.Load(),
+		"rpcs_begun":           t.rpcsBegun.Load(),
+		"rpcs_completed":       t.rpcsEnded.Load(),
+		"bytes_received":       t.bytesRecv.Load(),
+		"bytes_sent":           t.bytesSent.Load(),
+	}
+}
+
+// reset zeroes the counters. connections_open is a gauge of live
+// connections, so it is left alone.
+func (t *transportStats) reset() {
+	t.connsAccepted.Store(0)
+	t.rpcsBegun.Store(0)
+	t.rpcsEnded.Store(0)
+	t.bytesRecv.Store(0)
+	t.bytesSent.Store(0)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding/gzip"
+	grpcstats "google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/testutil"
+)
+
+// payloadRecorder is a grpc stats.Handler remembering the compression and
+// sizes of the last request A received.
+type payloadRecorder struct {
+	mu          sync.Mutex
+	compression string
+	length      int
+	wireLength  int
+}
+
+func (p *payloadRecorder) TagRPC(ctx context.Context, _ *grpcstats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (p *payloadRecorder) HandleRPC(_ context.Context, s grpcstats.RPCStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch s := s.(type) {
+	case *grpcstats.InHeader:
+		p.compression = s.Compression
+	case *grpcstats.InPayload:
+		p.length, p.wireLength = s.Length, s.WireLength
+	}
+}
+
+func (p *payloadRecorder) TagConn(ctx context.Context, _ *grpcstats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (p *payloadRecorder) HandleConn(context.Context, grpcstats.ConnStats) {}
+
+func TestEchoWithGzip(t *testing.T) {
+	defer func(n int) { maxMsgLen = n }(maxMsgLen)
+	maxMsgLen = 1 << 20
+
+	rec := &payloadRecorder{}
+	client := startServiceA(t, grpc.StatsHandler(rec))
+	msg := strings.Repeat("compress me ", 20000)
+
+	resp, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: msg}, grpc.UseCompressor(gzip.Name))
+	if err != nil {
+		t.Fatalf("Echo with gzip: %v", err)
+	}
+	if resp.Echo != msg {
+		t.Errorf("Echo returned %d bytes, want the %d sent", len(resp.Echo), len(msg))
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.compression != gzip.Name {
+		t.Errorf("A received grpc-encoding %q, want %q", rec.compression, gzip.Name)
+	}
+	if rec.wireLength >= rec.length {
+		t.Errorf("request was %d bytes on the wire for %d decoded, want it compressed", rec.wireLength, rec.length)
+	}
+}
+
+func TestOversizedMessageRejected(t *testing.T) {
+	client := startServiceA(t, grpc.MaxRecvMsgSize(256), grpc.MaxSendMsgSize(256))
+
+	if _, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: "fits"}); err != nil {
+		t.Fatalf("small Echo: %v", err)
+	}
+	_, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: strings.Repeat("x", 512)})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Echo over -max-recv-msg-size: err = %v, want ResourceExhausted", err)
+	}
+
+	// B's matching call option rejects the request before it is sent.
+	_, err = client.Echo(context.Background(), &echo.EchoRequest{Msg: strings.Repeat("x", 200)}, grpc.MaxCallSendMsgSize(64))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Echo over the client's send limit: err = %v, want ResourceExhausted", err)
+	}
+}
+
+func TestTransportStatsCountConnectionsAndRPCs(t *testing.T) {
+	ts := &transportStats{}
+	srv, err := testutil.StartEchoServer(serviceA{}, grpc.StatsHandler(ts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := srv.Client()
+
+	for _, msg := range []string{"one", "two"} {
+		if _, err := client.Echo(context.Background(), &echo.EchoRequest{Msg: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A's End event can land just after the client has its response.
+	waitForCount(t, ts, "rpcs_completed", 2)
+	got := ts.snapshot()
+	for k, want := range map[string]int64{"connections_accepted": 1, "connections_open": 1, "rpcs_begun": 2} {
+		if got[k] != want {
+			t.Errorf("%s = %d, want %d", k, got[k], want)
+		}
+	}
+	for _, k := range []string{"bytes_received", "bytes_sent"} {
+		if got[k] <= 0 {
+			t.Errorf("%s = %d, want some bytes", k, got[k])
+		}
+	}
+
+	ts.reset()
+	if got := ts.snapshot(); got["rpcs_begun"] != 0 || got["connections_open"] != 1 {
+		t.Errorf("after reset: %v, want counters zeroed but the open connection kept", got)
+	}
+
+	srv.Close()
+	waitForCount(t, ts, "connections_open", 0)
+}
+
+// waitForCount waits up to a few seconds for ts's counter to reach want.
+func waitForCount(t *testing.T, ts *transportStats, counter string, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for ts.snapshot()[counter] != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s = %d, want %d", counter, ts.snapshot()[counter], want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
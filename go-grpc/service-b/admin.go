@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------
+// Admin endpoints (service B)
+// --------------------
+
+// adminShutdown serves POST /admin/shutdown: a request carrying the admin
+// token (X-Admin-Token, or Authorization: Bearer) closes done, which main
+// treats like SIGTERM and drains in-flight requests before exiting.
+type adminShutdown struct {
+	token     string
+	once      sync.Once
+	done      chan struct{}
+	drainOnce sync.Once
+}
+
+func newAdminShutdown(token string) *adminShutdown {
+	return &adminShutdown{token: token, done: make(chan struct{})}
+}
+
+func adminTokenFrom(r *http.Request) string {
+	if t := r.Header.Get("X-Admin-Token"); t != "" {
+		return t
+	}
+	if t, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return t
+	}
+	return ""
+}
+
+// authorize rejects anything but a POST carrying the admin token, reporting
+// whether the request may proceed.
+func (a *adminShutdown) authorize(w http.ResponseWriter, r *http.Request, endpoint string) bool {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, "POST")
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(adminTokenFrom(r)), []byte(a.token)) != 1 {
+		log.Printf("service=B endpoint=%s status=forbidden client=%s", endpoint, clientIP(r, nil))
+		writeError(w, r, http.StatusForbidden, errCodeForbidden, errors.New("missing or invalid admin token"))
+		return false
+	}
+	return true
+}
+
+func (a *adminShutdown) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.authorize(w, r, "/admin/shutdown") {
+		return
+	}
+
+	log.Printf("service=B endpoint=/admin/shutdown status=accepted client=%s", clientIP(r, nil))
+	writeJSON(w, r, http.StatusAccepted, map[string]any{
+		"service_b": "shutting down",
+		"status":    http.StatusAccepted,
+	})
+	a.once.Do(func() { close(a.done) })
+}
+
+// drainHandler serves POST /admin/drain, a softer shutdown for rolling
+// deploys: /readyz starts failing at once so load balancers stop sending
+// traffic, but B keeps serving every request it still gets for grace before
+// shutting down as /admin/shutdown would. Repeated calls don't restart the
+// grace period.
+func (a *adminShutdown) drainHandler(ready *upstreamReadiness, grace time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.authorize(w, r, "/admin/drain") {
+			return
+		}
+		log.Printf("service=B endpoint=/admin/drain status=accepted client=%s grace=%s", clientIP(r, nil), grace)
+		writeJSON(w, r, http.StatusAccepted, map[string]any{
+			"service_b": "draining",
+			"grace_ms":  grace.Milliseconds(),
+			"status":    http.StatusAccepted,
+		})
+		a.drainOnce.Do(func() {
+			ready.drain()
+			time.AfterFunc(grace, func() { a.once.Do(func() { close(a.done) }) })
+		})
+	})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// adminRequest runs one request to h carrying token, if any.
+func adminRequest(h http.Handler, method, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/admin/shutdown", nil)
+	if token != "" {
+		r.Header.Set("X-Admin-Token", token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+// shutDown reports whether admin has asked B to shut down.
+func shutDown(admin *adminShutdown) bool {
+	select {
+	case <-admin.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestAdminShutdownRejectsBadTokens(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	admin := newAdminShutdown("s3cret")
+
+	for _, tt := range []struct {
+		name, method, token string
+		want                int
+	}{
+		{"no token", http.MethodPost, "", http.StatusForbidden},
+		{"wrong token", http.MethodPost, "guess", http.StatusForbidden},
+		{"GET", http.MethodGet, "s3cret", http.StatusMethodNotAllowed},
+	} {
+		if rec := adminRequest(admin, tt.method, tt.token); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+	if shutDown(admin) {
+		t.Fatal("an unauthorized request shut B down")
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/shutdown", nil)
+	r.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, r)
+	if rec.Code != http.StatusAccepted || !shutDown(admin) {
+		t.Errorf("bearer token: status = %d, shut down = %t; want 202, true", rec.Code, shutDown(admin))
+	}
+}
+
+func TestAdminShutdownStopsServer(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	admin := newAdminShutdown("s3cret")
+	mux := http.NewServeMux()
+	mux.Handle("/admin/shutdown", admin)
+	mux.HandleFunc("/health", newTestServiceB(nil).health)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: mux}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(lis) }()
+	// As in main: an accepted shutdown drains the server like SIGTERM.
+	go func() {
+		<-admin.done
+		_ = srv.Shutdown(context.Background())
+	}()
+	base := "http://" + lis.Addr().String()
+
+	req, _ := http.NewRequest(http.MethodPost, base+"/admin/shutdown", nil)
+	req.Header.Set("X-Admin-Token", "s3cret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", resp.StatusCode)
+	}
+
+	select {
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server still running after an accepted /admin/shutdown")
+	}
+	if resp, err := http.Get(base + "/health"); err == nil {
+		resp.Body.Close()
+		t.Error("server answered after shutting down")
+	}
+}
+
+func TestAdminDrainFailsReadinessButKeepsServing(t *testing.T) {
+	readyB, r, _ := newTestReadiness(t, time.Minute)
+	r.record(nil)
+	b := newTestServiceB(&fakeEchoClient{echoFn: echoOK})
+	b.ready = readyB.ready
+	admin := newAdminShutdown("s3cret")
+	const grace = 300 * time.Millisecond
+	drain := admin.drainHandler(b.ready, grace)
+
+	if rec := serve(http.HandlerFunc(b.readyz), http.MethodGet, "/readyz"); rec.Code != http.StatusOK {
+		t.Fatalf("/readyz before draining: status = %d", rec.Code)
+	}
+	if rec := adminRequest(drain, http.MethodPost, "wrong"); rec.Code != http.StatusForbidden {
+		t.Fatalf("drain with a bad token: status = %d, want 403", rec.Code)
+	}
+	drained := time.Now()
+	if rec := adminRequest(drain, http.MethodPost, "s3cret"); rec.Code != http.StatusAccepted {
+		t.Fatalf("drain: status = %d, want 202", rec.Code)
+	}
+
+	rec := serve(http.HandlerFunc(b.readyz), http.MethodGet, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("/readyz while draining: status = %d, want 503", rec.Code)
+	}
+	if body := decodeBody(t, rec); body["reason"] != "draining" {
+		t.Errorf("/readyz while draining: reason = %v, want draining", body["reason"])
+	}
+	if rec := serve(http.HandlerFunc(b.callEcho), http.MethodGet, "/call-echo?msg=hi"); rec.Code != http.StatusOK {
+		t.Errorf("/call-echo while draining: status = %d, body %s", rec.Code, rec.Body)
+	}
+	if shutDown(admin) {
+		t.Fatal("B shut down before the grace period ended")
+	}
+
+	// A second drain doesn't restart the grace period.
+	time.Sleep(grace * 2 / 3)
+	adminRequest(drain, http.MethodPost, "s3cret")
+	select {
+	case <-admin.done:
+		if elapsed := time.Since(drained); elapsed >= grace*5/3 {
+			t.Errorf("B shut down %s after the first drain, want about %s", elapsed, grace)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("B still running long after the grace period")
+	}
+}
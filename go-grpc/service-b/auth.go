@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+
+	"grpc-echo-json/echo"
+)
+
+// apiKeyCredentials attaches B's -api-key to the outgoing metadata of every
+// call to A.
+type apiKeyCredentials struct {
+	key string
+}
+
+func (c apiKeyCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{echo.APIKeyMetadataKey: c.key}, nil
+}
+
+// RequireTransportSecurity is false so the key also works over the default
+// plaintext connection; use -tls-ca to keep it off the wire in clear text.
+func (apiKeyCredentials) RequireTransportSecurity() bool { return false }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+// --------------------
+// HTTP request body limit (-max-http-body)
+// --------------------
+
+// maxBytesMiddleware caps request bodies at limit bytes. A body declared
+// larger in Content-Length is rejected up front; one that turns out larger
+// while being read fails the handler's read with *http.MaxBytesError, which
+// writeBadRequest reports as 413 too. A limit <= 0 disables the cap.
+func maxBytesMiddleware(limit int64, next http.Handler) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			writeBodyTooLarge(w, r, limit)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bodyTooLarge reports whether err came from reading past the body limit.
+func bodyTooLarge(err error) (int64, bool) {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return mbe.Limit, true
+	}
+	return 0, false
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, r *http.Request, limit int64) {
+	log.Printf("service=B endpoint=%s status=error error=\"request body too large\" limit_bytes=%d", r.URL.Path, limit)
+	body := errorBody(http.StatusRequestEntityTooLarge, errCodeBodyTooLarge, "request body too large", nil)
+	body["limit_bytes"] = limit
+	writeJSON(w, r, http.StatusRequestEntityTooLarge, body)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMaxBytesMiddleware(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	const atLimit = `{"msg":"0123456789"}`
+	b := newTestServiceB(&fakeEchoClient{echoFn: echoOK})
+	h := maxBytesMiddleware(int64(len(atLimit)), http.HandlerFunc(b.callEcho))
+
+	tests := []struct {
+		name    string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{"at limit", atLimit, false, http.StatusOK},
+		{"over limit", `{"msg":"0123456789a"}`, false, http.StatusRequestEntityTooLarge},
+		// No Content-Length, so the limit is only hit while reading.
+		{"over limit chunked", `{"msg":"0123456789a"}`, true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/call-echo", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			if tt.chunked {
+				r.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if tt.want == http.StatusOK {
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+				}
+				return
+			}
+			body := assertEnvelope(t, rec, tt.want, errCodeBodyTooLarge, "")
+			if body["limit_bytes"] != float64(len(atLimit)) {
+				t.Errorf("limit_bytes = %v, want %d", body["limit_bytes"], len(atLimit))
+			}
+		})
+	}
+}
+
+func TestMaxBytesMiddlewareDisabled(t *testing.T) {
+	b := newTestServiceB(&fakeEchoClient{echoFn: echoOK})
+	h := maxBytesMiddleware(0, http.HandlerFunc(b.callEcho))
+	r := httptest.NewRequest(http.MethodPost, "/call-echo", strings.NewReader(`{"msg":"`+strings.Repeat("x", 1<<16)+`"}`))
+	r.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Errorf("limit 0: status = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestBreaker returns a breaker on a fake clock the test advances.
+func newTestBreaker(threshold int, cooldown time.Duration) (*breaker, *time.Time) {
+	clock := time.Unix(1000, 0)
+	b := newBreaker(threshold, cooldown)
+	b.now = func() time.Time { return clock }
+	return b, &clock
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	b, clock := newTestBreaker(3, 10*time.Second)
+	fail := func() {
+		t.Helper()
+		if !b.allow() {
+			t.Fatalf("call rejected in state %s", b.state)
+		}
+		b.record(false)
+	}
+
+	fail()
+	fail()
+	if b.state != breakerClosed {
+		t.Fatalf("state after 2 of 3 failures = %s, want closed", b.state)
+	}
+	fail()
+	if b.state != breakerOpen {
+		t.Fatalf("state after 3 failures = %s, want open", b.state)
+	}
+	if b.allow() {
+		t.Fatal("open breaker let a call through")
+	}
+	if got := b.retryIn(); got != 10*time.Second {
+		t.Errorf("retryIn = %s, want 10s", got)
+	}
+
+	*clock = clock.Add(10 * time.Second)
+	if !b.allow() {
+		t.Fatal("breaker didn't let a probe through after the cooldown")
+	}
+	if b.state != breakerHalfOpen {
+		t.Fatalf("state while probing = %s, want half-open", b.state)
+	}
+	if b.allow() {
+		t.Error("half-open breaker let a second call through alongside the probe")
+	}
+
+	b.record(true)
+	if b.state != breakerClosed {
+		t.Fatalf("state after a successful probe = %s, want closed", b.state)
+	}
+	if !b.allow() {
+		t.Error("closed breaker rejected a call")
+	}
+	b.record(true)
+}
+
+func TestBreakerFailedProbeReopens(t *testing.T) {
+	b, clock := newTestBreaker(1, time.Second)
+	b.allow()
+	b.record(false)
+
+	*clock = clock.Add(time.Second)
+	if !b.allow() {
+		t.Fatal("no probe after the cooldown")
+	}
+	b.record(false)
+	if b.state != breakerOpen {
+		t.Fatalf("state after a failed probe = %s, want open", b.state)
+	}
+	if b.allow() {
+		t.Error("breaker let a call through right after the failed probe")
+	}
+}
+
+func TestBreakerSuccessResetsFailureCount(t *testing.T) {
+	b, _ := newTestBreaker(2, time.Second)
+	for _, ok := range []bool{false, true, false} {
+		b.allow()
+		b.record(ok)
+	}
+	if b.state != breakerClosed {
+		t.Errorf("state = %s, want closed: the failures weren't consecutive", b.state)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b, _ := newTestBreaker(0, time.Second)
+	for range 10 {
+		if !b.allow() {
+			t.Fatal("disabled breaker rejected a call")
+		}
+		b.record(false)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Echo response cache (service B)
+// --------------------
+
+// cache is a size-bounded LRU with a per-entry TTL. B uses it for
+// successful echo responses (Echo is deterministic, so a hit saves a round
+// trip to service A) and for idempotent replays. A nil *cache is valid and
+// never hits.
+type cache[V any] struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu    sync.Mutex
+	order *list.List // front = most recently used; elements hold *cacheEntry[V]
+	items map[string]*list.Element
+}
+
+type cacheEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// newCache returns a cache holding up to size entries for ttl each, or nil
+// (caching disabled) when either is <= 0.
+func newCache[V any](size int, ttl time.Duration) *cache[V] {
+	if size <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &cache[V]{
+		size:  size,
+		ttl:   ttl,
+		now:   time.Now,
+		order: list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+// Get returns the cached value for key if present and not expired.
+func (c *cache[V]) Get(key string) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	e := el.Value.(*cacheEntry[V])
+	if !c.now().Before(e.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Set stores value under key, evicting the least recently used entry if
+// the cache is full.
+func (c *cache[V]) Set(key string, value V) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry[V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry[V]{key: key, value: value, expires: expires})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry[V]).key)
+	}
+}
+
+// echoCacheKey keys a cached echo by message and transform.
+func echoCacheKey(req *echo.EchoRequest) string {
+	return req.Transform + "\x00" + req.Msg
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+// newTestCache returns a cache under a fake clock, and a pointer to that
+// clock.
+func newTestCache(size int, ttl time.Duration) (*cache[string], *time.Time) {
+	c := newCache[string](size, ttl)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCacheHitAndMiss(t *testing.T) {
+	c, _ := newTestCache(2, time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("empty cache hit")
+	}
+	c.Set("a", "1")
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Errorf("Get(a) = %q, %t; want 1, true", v, ok)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c, now := newTestCache(2, time.Minute)
+	c.Set("a", "1")
+	*now = now.Add(59 * time.Second)
+	if _, ok := c.Get("a"); !ok {
+		t.Error("entry expired before its TTL")
+	}
+	*now = now.Add(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("entry still served at its TTL")
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c, _ := newTestCache(2, time.Minute)
+	c.Set("a", "1")
+	c.Set("b", "2")
+	c.Get("a") // b is now the least recently used
+	c.Set("c", "3")
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b survived; want it evicted as least recently used")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("%s was evicted", k)
+		}
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	c := newCache[string](0, time.Minute)
+	c.Set("a", "1")
+	if _, ok := c.Get("a"); ok {
+		t.Error("disabled cache hit")
+	}
+}
+
+// failingEcho fails every call with code.
+func failingEcho(code codes.Code) func(context.Context, *echo.EchoRequest) (*echo.EchoResponse, error) {
+	return func(context.Context, *echo.EchoRequest) (*echo.EchoResponse, error) {
+		return nil, status.Error(code, "upstream said no")
+	}
+}
+
+func TestCallEchoCache(t *testing.T) {
+	fail := false
+	client := &fakeEchoClient{echoFn: func(ctx context.Context, in *echo.EchoRequest) (*echo.EchoResponse, error) {
+		if fail {
+			return failingEcho(codes.InvalidArgument)(ctx, in)
+		}
+		return echoOK(ctx, in)
+	}}
+	b := newTestServiceB(client)
+	b.echoCache = newCache[string](10, time.Minute)
+	h := http.HandlerFunc(b.callEcho)
+
+	for i, want := range []string{"MISS", "HIT"} {
+		rec := serve(h, http.MethodGet, "/call-echo?msg=hi")
+		if got := rec.Header().Get("X-Cache"); rec.Code != http.StatusOK || got != want {
+			t.Errorf("request %d: status %d, X-Cache %q; want 200, %s", i, rec.Code, got, want)
+		}
+	}
+	if rec := serve(h, http.MethodGet, "/call-echo?msg=hi&transform=upper"); rec.Header().Get("X-Cache") != "MISS" {
+		t.Error("a different transform hit the cache")
+	}
+	if n := client.calls.Load(); n != 2 {
+		t.Errorf("A got %d calls, want 2", n)
+	}
+
+	fail = true
+	serve(h, http.MethodGet, "/call-echo?msg=bad")
+	serve(h, http.MethodGet, "/call-echo?msg=bad")
+	if n := client.calls.Load(); n != 4 {
+		t.Errorf("A got %d calls, want 4: errors must not be cached", n)
+	}
+}
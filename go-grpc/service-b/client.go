@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/logging"
+)
+
+// --------------------
+// gRPC client interceptor (B -> A)
+// --------------------
+
+// clientLoggingInterceptor logs and measures every unary call B makes to A,
+// mirroring service A's server-side logging interceptor. Comparing its
+// latency_ms with the HTTP request's shows how much of a request's time was
+// spent waiting on A. Each retry attempt is logged separately.
+func clientLoggingInterceptor(logger *logging.Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		code := status.Code(err)
+		elapsed := time.Since(start)
+		observeUpstream(method, code, elapsed)
+
+		requestID := requestIDFrom(ctx)
+		if requestID == "" {
+			requestID = "-"
+		}
+		logger.Request(logging.CodeLevel(code), "service", "B", "upstream", "A", "method", method,
+			"status", code.String(), "request_id", requestID, "latency_ms", elapsed.Milliseconds())
+		return err
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/logging"
+)
+
+func TestClientLoggingInterceptorRecordsCode(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, logging.Config{Level: slog.LevelDebug, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interceptor := clientLoggingInterceptor(logger)
+
+	for _, code := range []codes.Code{codes.OK, codes.NotFound, codes.Unavailable} {
+		out.Reset()
+		invoked := false
+		// The invoker stands in for the connection to A.
+		invoker := func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			invoked = true
+			return status.Error(code, "from A")
+		}
+		err := interceptor(context.Background(), "/echo.EchoService/Echo", nil, nil, nil, invoker)
+		if !invoked || status.Code(err) != code {
+			t.Fatalf("%s: invoked = %t, err = %v; want the invoker's error passed through", code, invoked, err)
+		}
+
+		line := out.String()
+		for _, want := range []string{"upstream=A", "method=/echo.EchoService/Echo", "status=" + code.String(), "latency_ms="} {
+			if !strings.Contains(line, want) {
+				t.Errorf("%s: log line %q lacks %q", code, line, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	grpcbackoff "google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/keepalive"
+	// Registers the client-side health checker healthCheckConfig relies on.
+	_ "google.golang.org/grpc/health"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Connection tuning for B -> A
+// --------------------
+
+// serviceConfigRetries is how many times grpc's own retryPolicy repeats an
+// EchoService call that fails with UNAVAILABLE, e.g. while the channel
+// reconnects to a replica. It is taken out of -max-retries; see
+// splitRetries.
+const serviceConfigRetries = 1
+
+// splitRetries divides -max-retries between grpc's retryPolicy and
+// callWithRetry, so raising or lowering the flag moves both layers and
+// -max-retries 0 turns retries off entirely.
+func splitRetries(maxRetries int) (grpcRetries, callRetries int) {
+	grpcRetries = max(min(maxRetries, serviceConfigRetries), 0)
+	return grpcRetries, max(maxRetries-grpcRetries, 0)
+}
+
+// serviceConfig spreads calls across every resolved service A address with
+// round_robin, skipping any replica whose grpc.health.v1 status (the overall
+// "" service) is not SERVING. When every replica is unhealthy calls fail
+// with UNAVAILABLE, which B reports as a 503. With grpcRetries > 0 it also
+// sets a retryPolicy that repeats EchoService calls failing with UNAVAILABLE
+// up to grpcRetries times, below callWithRetry.
+func serviceConfig(grpcRetries int) string {
+	if grpcRetries <= 0 {
+		return `{
+  "loadBalancingConfig": [{"round_robin": {}}],
+  "healthCheckConfig": {"serviceName": ""}
+}`
+	}
+	return fmt.Sprintf(`{
+  "loadBalancingConfig": [{"round_robin": {}}],
+  "healthCheckConfig": {"serviceName": ""},
+  "methodConfig": [{
+    "name": [{"service": %q}],
+    "retryPolicy": {
+      "maxAttempts": %d,
+      "initialBackoff": "0.05s",
+      "maxBackoff": "0.5s",
+      "backoffMultiplier": 2.0,
+      "retryableStatusCodes": ["UNAVAILABLE"]
+    }
+  }]
+}`, echo.ServiceName, grpcRetries+1)
+}
+
+// keepaliveDialOption pings A every interval while the connection is idle,
+// so a silently dropped TCP connection is noticed and redialed instead of
+// hanging the next request. Service A's enforcement policy allows pings
+// down to 10s; interval <= 0 disables keepalive.
+func keepaliveDialOption(interval time.Duration) grpc.DialOption {
+	if interval <= 0 {
+		return grpc.EmptyDialOption{}
+	}
+	timeout := interval / 3
+	if timeout < time.Second {
+		timeout = time.Second
+	}
+	return grpc.WithKeepaliveParams(keepalive.ClientParameters{
+		Time:                interval,
+		Timeout:             timeout,
+		PermitWithoutStream: true,
+	})
+}
+
+// connectParamsDialOption sets how B (re)connects to A. Each attempt gets at
+// least minConnect to complete; failed attempts are retried after base,
+// growing by multiplier per failure up to max, with grpc's usual 20% jitter.
+// The flag defaults match grpc's own (20s, 1s, 1.6, 120s), which suit a
+// peer that is down for a while; on a flaky network a lower max (e.g. 5s)
+// gets B back within seconds of A returning, at the cost of more dials
+// while A stays down.
+func connectParamsDialOption(minConnect, base, max time.Duration, multiplier float64) grpc.DialOption {
+	return grpc.WithConnectParams(grpc.ConnectParams{
+		Backoff: grpcbackoff.Config{
+			BaseDelay:  base,
+			Multiplier: multiplier,
+			Jitter:     grpcbackoff.DefaultConfig.Jitter,
+			MaxDelay:   max,
+		},
+		MinConnectTimeout: minConnect,
+	})
+}
+
+// upstreamAddrs splits a comma-separated -service-a value into its
+// addresses. A single address or resolver target is returned as the only
+// element.
+func upstreamAddrs(serviceA string) []string {
+	if !strings.Contains(serviceA, ",") {
+		return []string{serviceA}
+	}
+	var addrs []string
+	for _, a := range strings.Split(serviceA, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// upstreamTarget turns the -service-a value into a dial target. A single
+// address or resolver target (e.g. "dns:///service-a:50051") is used as is;
+// a comma-separated list of addresses is served by a "static" resolver so
+// one channel balances across all of them.
+func upstreamTarget(serviceA string) (string, []grpc.DialOption) {
+	if !strings.Contains(serviceA, ",") {
+		return serviceA, nil
+	}
+
+	var addrs []resolver.Address
+	for _, a := range upstreamAddrs(serviceA) {
+		addrs = append(addrs, resolver.Address{Addr: a})
+	}
+	r := manual.NewBuilderWithScheme("static")
+	r.InitialState(resolver.State{Addresses: addrs})
+	return r.Scheme() + ":///service-a", []grpc.DialOption{grpc.WithResolvers(r)}
+}
+
+// waitForReady asks the channel to connect and blocks until it is READY or
+// timeout passes, reporting whether it got there. grpc.NewClient itself
+// never blocks, so without this the first calls after B starts can race the
+// connection and fail with Unavailable.
+func waitForReady(conn *grpc.ClientConn, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return true
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return false
+		}
+	}
+}
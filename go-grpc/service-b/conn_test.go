@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+// serveServiceA serves fakeServiceA on addr until the test ends or the
+// returned server is stopped.
+func serveServiceA(t *testing.T, addr string) (*grpc.Server, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	echo.RegisterEchoServiceServer(s, fakeServiceA{})
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	return s, lis.Addr().String()
+}
+
+func TestClientRecoversAfterServiceARestarts(t *testing.T) {
+	echo.RegisterCodecs()
+	srv, addr := serveServiceA(t, "127.0.0.1:0")
+
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+		grpc.WithDefaultServiceConfig(serviceConfig(serviceConfigRetries)),
+		keepaliveDialOption(10*time.Second),
+		connectParamsDialOption(time.Second, 50*time.Millisecond, 200*time.Millisecond, 1.6),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := echo.NewEchoServiceClient(conn)
+
+	echoOnce := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"})
+		return err
+	}
+	if err := echoOnce(); err != nil {
+		t.Fatalf("Echo before restart: %v", err)
+	}
+
+	srv.Stop()
+	if err := echoOnce(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("Echo with A down: err = %v, want Unavailable", err)
+	}
+
+	serveServiceA(t, addr)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := echoOnce()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Echo still failing after A restarted: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestKeepaliveDialOptionDisabled(t *testing.T) {
+	if _, ok := keepaliveDialOption(0).(grpc.EmptyDialOption); !ok {
+		t.Error("keepaliveDialOption(0) should be a no-op")
+	}
+}
+
+// unavailableServiceA fails every Echo with Unavailable, counting calls.
+type unavailableServiceA struct {
+	echo.EchoServiceServer
+	calls atomic.Int32
+}
+
+func (s *unavailableServiceA) Echo(context.Context, *echo.EchoRequest) (*echo.EchoResponse, error) {
+	s.calls.Add(1)
+	return nil, status.Error(codes.Unavailable, "injected")
+}
+
+// serviceConfigAttempts returns how many attempts one Echo through
+// serviceConfig(grpcRetries) makes against an A that is always Unavailable.
+func serviceConfigAttempts(t *testing.T, grpcRetries int) int32 {
+	t.Helper()
+	echo.RegisterCodecs()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := &unavailableServiceA{}
+	s := grpc.NewServer()
+	echo.RegisterEchoServiceServer(s, impl)
+	go func() { _ = s.Serve(lis) }()
+	defer s.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+		grpc.WithDefaultServiceConfig(serviceConfig(grpcRetries)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := echo.NewEchoServiceClient(conn).Echo(ctx, &echo.EchoRequest{Msg: "hi"}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+	return impl.calls.Load()
+}
+
+func TestServiceConfigRetryIsBounded(t *testing.T) {
+	for _, grpcRetries := range []int{0, 1} {
+		if n := serviceConfigAttempts(t, grpcRetries); n != int32(grpcRetries+1) {
+			t.Errorf("serviceConfig(%d): A saw %d attempts, want %d", grpcRetries, n, grpcRetries+1)
+		}
+	}
+}
+
+func TestSplitRetries(t *testing.T) {
+	tests := []struct {
+		maxRetries, grpcRetries, callRetries int
+	}{
+		{0, 0, 0},
+		{1, 1, 0},
+		{2, 1, 1},
+		{5, 1, 4},
+		{-1, 0, 0},
+	}
+	for _, tt := range tests {
+		g, c := splitRetries(tt.maxRetries)
+		if g != tt.grpcRetries || c != tt.callRetries {
+			t.Errorf("splitRetries(%d) = %d, %d; want %d, %d", tt.maxRetries, g, c, tt.grpcRetries, tt.callRetries)
+		}
+	}
+}
+
+func TestClientAgainstDownAddress(t *testing.T) {
+	echo.RegisterCodecs()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	// grpc.NewClient doesn't dial, so B starts even with A down.
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+		grpc.WithDefaultServiceConfig(serviceConfig(serviceConfigRetries)),
+	)
+	if err != nil {
+		t.Fatalf("NewClient against a down address: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := echo.NewEchoServiceClient(conn).Echo(ctx, &echo.EchoRequest{Msg: "hi"}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("first Echo with A down: err = %v, want Unavailable", err)
+	}
+}
+
+// namedServiceA answers every Echo with its own name, so a test can tell
+// which replica served a call.
+type namedServiceA struct {
+	echo.EchoServiceServer
+	name string
+}
+
+func (s namedServiceA) Echo(context.Context, *echo.EchoRequest) (*echo.EchoResponse, error) {
+	return &echo.EchoResponse{Echo: s.name}, nil
+}
+
+func TestClientBalancesAcrossReplicas(t *testing.T) {
+	echo.RegisterCodecs()
+	var addrs []string
+	for _, name := range []string{"a1", "a2"} {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := grpc.NewServer()
+		echo.RegisterEchoServiceServer(s, namedServiceA{name: name})
+		go func() { _ = s.Serve(lis) }()
+		t.Cleanup(s.Stop)
+		addrs = append(addrs, lis.Addr().String())
+	}
+
+	target, opts := upstreamTarget(strings.Join(addrs, ","))
+	conn, err := grpc.NewClient(target, append(opts,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+		grpc.WithDefaultServiceConfig(serviceConfig(serviceConfigRetries)),
+	)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := echo.NewEchoServiceClient(conn)
+
+	seen := make(map[string]int)
+	for i := 0; i < 50; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		resp, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"}, grpc.WaitForReady(true))
+		cancel()
+		if err != nil {
+			t.Fatalf("Echo %d: %v", i, err)
+		}
+		seen[resp.Echo]++
+	}
+	if seen["a1"] == 0 || seen["a2"] == 0 {
+		t.Errorf("calls per replica = %v, want both replicas used", seen)
+	}
+}
+
+func TestUpstreamAddrs(t *testing.T) {
+	if got := upstreamAddrs("dns:///service-a:50051"); len(got) != 1 || got[0] != "dns:///service-a:50051" {
+		t.Errorf("single target = %q", got)
+	}
+	if got := upstreamAddrs(" a:1 , ,b:2"); len(got) != 2 || got[0] != "a:1" || got[1] != "b:2" {
+		t.Errorf("list = %q, want [a:1 b:2]", got)
+	}
+}
+
+func TestWaitForReady(t *testing.T) {
+	echo.RegisterCodecs()
+	_, addr := serveServiceA(t, "127.0.0.1:0")
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if !waitForReady(conn, 5*time.Second) {
+		t.Fatalf("channel to a running A not READY; state %s", conn.GetState())
+	}
+	// No WaitForReady call option: the first call must not race the dial.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := echo.NewEchoServiceClient(conn).Echo(ctx, &echo.EchoRequest{Msg: "first"}); err != nil {
+		t.Errorf("first Echo after -dial-wait: %v", err)
+	}
+}
+
+func TestWaitForReadyGivesUp(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	if waitForReady(conn, 100*time.Millisecond) {
+		t.Error("waitForReady reported a down A as READY")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("waitForReady took %s, want it bounded by its timeout", elapsed)
+	}
+}
+
+// serveHealthCheckedReplica serves namedServiceA along with a grpc.health.v1
+// server, returning the health server and the replica's address.
+func serveHealthCheckedReplica(t *testing.T, name string) (*health.Server, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := health.NewServer()
+	s := grpc.NewServer()
+	echo.RegisterEchoServiceServer(s, namedServiceA{name: name})
+	healthpb.RegisterHealthServer(s, hs)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	return hs, lis.Addr().String()
+}
+
+func TestClientAvoidsUnhealthyReplica(t *testing.T) {
+	echo.RegisterCodecs()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	h1, addr1 := serveHealthCheckedReplica(t, "a1")
+	h2, addr2 := serveHealthCheckedReplica(t, "a2")
+	h2.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	target, opts := upstreamTarget(addr1 + "," + addr2)
+	conn, err := grpc.NewClient(target, append(opts,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+		grpc.WithDefaultServiceConfig(serviceConfig(serviceConfigRetries)),
+	)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := echo.NewEchoServiceClient(conn)
+
+	for i := 0; i < 30; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		resp, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"}, grpc.WaitForReady(true))
+		cancel()
+		if err != nil {
+			t.Fatalf("Echo %d: %v", i, err)
+		}
+		if resp.Echo != "a1" {
+			t.Fatalf("Echo %d served by %s, which reports NOT_SERVING", i, resp.Echo)
+		}
+	}
+
+	// With every replica unhealthy, /call-echo is a 503.
+	h1.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	b := newTestServiceB(client)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := serve(http.HandlerFunc(b.callEcho), http.MethodGet, "/call-echo?msg=hi")
+		if rec.Code == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("/call-echo with both replicas NOT_SERVING: status = %d, body %s", rec.Code, rec.Body)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// downtimeRecovery runs A, takes it down for downtime, brings it back on the
+// same address and returns how long B's channel, dialled with the given
+// backoff, takes to serve calls again, giving up after limit.
+func downtimeRecovery(t *testing.T, base, max, downtime, limit time.Duration) (time.Duration, bool) {
+	t.Helper()
+	echo.RegisterCodecs()
+	srv, addr := serveServiceA(t, "127.0.0.1:0")
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+		grpc.WithDefaultServiceConfig(serviceConfig(serviceConfigRetries)),
+		connectParamsDialOption(time.Second, base, max, 1.6),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := echo.NewEchoServiceClient(conn)
+	echoOnce := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"})
+		return err
+	}
+	if err := echoOnce(); err != nil {
+		t.Fatalf("Echo before restart: %v", err)
+	}
+
+	srv.Stop()
+	time.Sleep(downtime)
+	serveServiceA(t, addr)
+	restarted := time.Now()
+	for time.Since(restarted) < limit {
+		if echoOnce() == nil {
+			return time.Since(restarted), true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return limit, false
+}
+
+func TestReconnectWithinConfiguredBackoff(t *testing.T) {
+	// After 600ms down the backoff has reached its 200ms cap, so B must be
+	// back within one capped delay (plus jitter) of A returning.
+	if took, ok := downtimeRecovery(t, 50*time.Millisecond, 200*time.Millisecond, 600*time.Millisecond, 2*time.Second); !ok || took > 500*time.Millisecond {
+		t.Errorf("recovered = %t after %s with a 200ms max backoff, want within 500ms", ok, took)
+	}
+	// With a 3s base delay the next attempt is still seconds away.
+	if took, ok := downtimeRecovery(t, 3*time.Second, 3*time.Second, 600*time.Millisecond, 500*time.Millisecond); ok {
+		t.Errorf("recovered after %s with a 3s base backoff; the connect params aren't applied", took)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// --------------------
+// CORS (service B)
+// --------------------
+
+const (
+	corsAllowMethods  = "GET, POST, OPTIONS"
+	corsAllowHeaders  = "Content-Type, Authorization, X-Request-Id, Idempotency-Key, Accept-Language"
+	corsExposeHeaders = "X-Request-Id, X-Cache, X-Coalesced, Idempotent-Replayed, Retry-After"
+	corsMaxAge        = "600"
+)
+
+// parseCORSOrigins splits the comma-separated -cors-origins value. "*"
+// allows any origin; an empty value disables CORS.
+func parseCORSOrigins(s string) map[string]bool {
+	origins := map[string]bool{}
+	for _, o := range strings.Split(s, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins[strings.TrimSuffix(o, "/")] = true
+		}
+	}
+	return origins
+}
+
+// corsMiddleware lets browser pages on the allowed origins call B. Requests
+// from other origins get no CORS headers, so the browser blocks them; their
+// preflights are refused with 403. With no origins configured it is a no-op.
+func corsMiddleware(allowed map[string]bool, next http.Handler) http.Handler {
+	if len(allowed) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		ok := allowed["*"] || allowed[origin]
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !ok {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if allowed["*"] {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
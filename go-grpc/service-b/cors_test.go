@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// corsRequest runs a request from origin through h; a non-empty
+// preflightMethod makes it an OPTIONS preflight for that method.
+func corsRequest(h http.Handler, origin, preflightMethod string) *httptest.ResponseRecorder {
+	method := http.MethodGet
+	if preflightMethod != "" {
+		method = http.MethodOptions
+	}
+	r := httptest.NewRequest(method, "/call-echo", nil)
+	r.Header.Set("Origin", origin)
+	if preflightMethod != "" {
+		r.Header.Set("Access-Control-Request-Method", preflightMethod)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestCORSPreflight(t *testing.T) {
+	h := corsMiddleware(parseCORSOrigins("https://app.example, https://other.example/"), http.HandlerFunc(okHandler))
+
+	rec := corsRequest(h, "https://other.example", http.MethodPost)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("allowed preflight = %d, want 204", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://other.example" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	allow := rec.Header().Get("Access-Control-Allow-Headers")
+	for _, name := range []string{"Content-Type", "Authorization", "X-Request-Id", "Idempotency-Key", "Accept-Language"} {
+		if !strings.Contains(allow, name) {
+			t.Errorf("Access-Control-Allow-Headers %q lacks %s", allow, name)
+		}
+	}
+
+	if rec := corsRequest(h, "https://evil.example", http.MethodPost); rec.Code != http.StatusForbidden {
+		t.Errorf("preflight from an unlisted origin = %d, want 403", rec.Code)
+	}
+}
+
+func TestCORSExposesResponseHeaders(t *testing.T) {
+	h := corsMiddleware(parseCORSOrigins("*"), http.HandlerFunc(okHandler))
+
+	rec := corsRequest(h, "https://app.example", "")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	expose := rec.Header().Get("Access-Control-Expose-Headers")
+	for _, name := range []string{"X-Request-Id", "X-Cache", "X-Coalesced", "Idempotent-Replayed", "Retry-After"} {
+		if !strings.Contains(expose, name) {
+			t.Errorf("Access-Control-Expose-Headers %q lacks %s", expose, name)
+		}
+	}
+}
+
+func TestCORSUnlistedOriginGetsNoHeaders(t *testing.T) {
+	h := corsMiddleware(parseCORSOrigins("https://app.example"), http.HandlerFunc(okHandler))
+	rec := corsRequest(h, "https://evil.example", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the handler's 200", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q for an unlisted origin", got)
+	}
+}
+
+func TestCORSDisabled(t *testing.T) {
+	h := corsMiddleware(parseCORSOrigins(""), http.HandlerFunc(okHandler))
+	rec := corsRequest(h, "https://app.example", http.MethodPost)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q with CORS off", got)
+	}
+}
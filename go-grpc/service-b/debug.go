@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// --------------------
+// Debug endpoints (service B)
+// --------------------
+
+// connStateWait bounds how long GET /debug/conn?wait=1 blocks for a state
+// change.
+const connStateWait = 5 * time.Second
+
+// connDebug serves GET /debug/conn: the state of B's channel to service A
+// (IDLE, CONNECTING, READY, TRANSIENT_FAILURE or SHUTDOWN) and the address it
+// was configured with. With ?wait=1 it first waits up to connStateWait for
+// the state to change, which makes a flapping A easy to watch.
+type connDebug struct {
+	conn   *grpc.ClientConn
+	target string
+}
+
+func (d connDebug) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	state := d.conn.GetState()
+	body := map[string]any{
+		"service_a": d.target,
+		"state":     state.String(),
+	}
+
+	if r.URL.Query().Get("wait") == "1" {
+		// An idle channel only leaves IDLE when asked to connect.
+		if state == connectivity.Idle {
+			d.conn.Connect()
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), connStateWait)
+		defer cancel()
+		changed := d.conn.WaitForStateChange(ctx, state)
+		body["previous_state"] = state.String()
+		body["state"] = d.conn.GetState().String()
+		body["changed"] = changed
+	}
+
+	writeJSON(w, r, http.StatusOK, body)
+}
+
+// delayFor returns how long r asks B to sleep before calling service A: the
+// ?delay= query parameter, clamped to b.maxDelay, or 0 when it is absent.
+// It exists to test client timeouts against B itself, independently of A's
+// fault injection. A maxDelay of 0 (the default) disables it, and ?delay=
+// is ignored.
+func (b *serviceB) delayFor(r *http.Request) (time.Duration, error) {
+	v := r.URL.Query().Get("delay")
+	if v == "" || b.maxDelay <= 0 {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid delay %q: want a duration such as 200ms", v)
+	}
+	if d > b.maxDelay {
+		d = b.maxDelay
+	}
+	return d, nil
+}
+
+// debugDelay sleeps for r's ?delay=, reporting whether the handler should go
+// on. It writes the response itself when the delay is invalid or the request
+// is canceled while sleeping.
+func (b *serviceB) debugDelay(w http.ResponseWriter, r *http.Request, endpoint string) bool {
+	start := time.Now()
+	d, err := b.delayFor(r)
+	if err != nil {
+		writeBadRequest(w, r, endpoint, start, err)
+		return false
+	}
+	if d == 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Context().Done():
+		log.Printf("service=B endpoint=%s status=error delay_ms=%d error=%q latency_ms=%d",
+			endpoint, d.Milliseconds(), r.Context().Err().Error(), time.Since(start).Milliseconds())
+		writeError(w, r, statusClientClosedRequest, errCodeClientClosedRequest, r.Context().Err())
+		return false
+	}
+}
+
+// requestEntry is one finished request as listed by GET /debug/requests.
+type requestEntry struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	LatencyMS int64     `json:"latency_ms"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// requestRing keeps the last len(entries) finished requests, overwriting the
+// oldest once full. A nil *requestRing records nothing.
+type requestRing struct {
+	mu      sync.Mutex
+	entries []requestEntry
+	next    int  // index the next entry is written to
+	full    bool // entries has wrapped at least once
+}
+
+// newRequestRing returns a ring of size entries, or nil if size <= 0.
+func newRequestRing(size int) *requestRing {
+	if size <= 0 {
+		return nil
+	}
+	return &requestRing{entries: make([]requestEntry, size)}
+}
+
+func (g *requestRing) add(e requestEntry) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.entries[g.next] = e
+	g.next = (g.next + 1) % len(g.entries)
+	if g.next == 0 {
+		g.full = true
+	}
+}
+
+// recent returns a copy of the recorded entries, oldest first.
+func (g *requestRing) recent() []requestEntry {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.full {
+		return append([]requestEntry(nil), g.entries[:g.next]...)
+	}
+	out := make([]requestEntry, 0, len(g.entries))
+	out = append(out, g.entries[g.next:]...)
+	return append(out, g.entries[:g.next]...)
+}
+
+// middleware records every request except those for GET /debug/requests
+// itself. It must run inside httpLoggingMiddleware to see the request id.
+func (g *requestRing) middleware(next http.Handler) http.Handler {
+	if g == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/debug/requests" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		sw := &statusCapturingWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		g.add(requestEntry{
+			Time:      start,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    sw.status,
+			LatencyMS: time.Since(start).Milliseconds(),
+			RequestID: requestIDFrom(r.Context()),
+		})
+	})
+}
+
+// ServeHTTP serves GET /debug/requests: the recorded requests, oldest first.
+func (g *requestRing) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	entries := g.recent()
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"size":     len(g.entries),
+		"count":    len(entries),
+		"requests": entries,
+	})
+}
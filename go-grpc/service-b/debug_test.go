@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"grpc-echo-json/echo"
+)
+
+func TestDebugConnReportsStateTransitions(t *testing.T) {
+	echo.RegisterCodecs()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		connectParamsDialOption(time.Second, 20*time.Millisecond, 100*time.Millisecond, 1.6),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	h := connDebug{conn: conn, target: addr}
+
+	body := decodeBody(t, serve(h, http.MethodGet, "/debug/conn"))
+	if body["state"] != "IDLE" || body["service_a"] != addr {
+		t.Errorf("before any call: body = %v, want state IDLE for %s", body, addr)
+	}
+
+	// ?wait=1 kicks the idle channel into connecting to the down backend.
+	body = decodeBody(t, serve(h, http.MethodGet, "/debug/conn?wait=1"))
+	if body["previous_state"] != "IDLE" || body["changed"] != true || body["state"] == "READY" {
+		t.Errorf("with A down: body = %v, want a change away from IDLE, not to READY", body)
+	}
+
+	serveServiceA(t, addr)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		body = decodeBody(t, serve(h, http.MethodGet, "/debug/conn?wait=1"))
+		if body["state"] == "READY" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("state never became READY after A came up; last body %v", body)
+		}
+	}
+}
+
+// paths lists the entries' paths in order.
+func paths(entries []requestEntry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.Path)
+	}
+	return out
+}
+
+func TestRequestRingWraps(t *testing.T) {
+	g := newRequestRing(3)
+	for i, want := range [][]string{
+		{"/0"},
+		{"/0", "/1"},
+		{"/0", "/1", "/2"},
+		{"/1", "/2", "/3"},
+		{"/2", "/3", "/4"},
+		{"/3", "/4", "/5"},
+		{"/4", "/5", "/6"},
+	} {
+		g.add(requestEntry{Path: "/" + strconv.Itoa(i)})
+		if got := paths(g.recent()); !reflect.DeepEqual(got, want) {
+			t.Errorf("after %d adds: recent = %v, want %v", i+1, got, want)
+		}
+	}
+	if newRequestRing(0) != nil {
+		t.Error("newRequestRing(0) should disable the ring")
+	}
+}
+
+func TestDebugRequestsEndpoint(t *testing.T) {
+	g := newRequestRing(2)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", okHandler)
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.Handle("/debug/requests", g)
+	h := g.middleware(mux)
+
+	for _, target := range []string{"/ok", "/missing", "/ok", "/debug/requests"} {
+		serve(h, http.MethodGet, target)
+	}
+	rec := serve(h, http.MethodGet, "/debug/requests")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var body struct {
+		Size     int            `json:"size"`
+		Count    int            `json:"count"`
+		Requests []requestEntry `json:"requests"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	// The ring holds the last two requests, not counting /debug/requests.
+	if body.Size != 2 || body.Count != 2 {
+		t.Errorf("size, count = %d, %d; want 2, 2", body.Size, body.Count)
+	}
+	if got := paths(body.Requests); !reflect.DeepEqual(got, []string{"/missing", "/ok"}) {
+		t.Fatalf("requests = %v, want [/missing /ok]", got)
+	}
+	if e := body.Requests[0]; e.Method != http.MethodGet || e.Status != http.StatusNotFound || e.Time.IsZero() {
+		t.Errorf("entry = %+v, want a timestamped GET with status 404", e)
+	}
+}
+
+func TestRequestRingConcurrentAdds(t *testing.T) {
+	g := newRequestRing(16)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				g.add(requestEntry{Path: "/x"})
+				_ = g.recent()
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(g.recent()); n != 16 {
+		t.Errorf("recent has %d entries after 800 adds, want 16", n)
+	}
+}
+
+func TestCallEchoDelay(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	const maxDelay = 100 * time.Millisecond
+	tests := []struct {
+		name     string
+		delay    string
+		min, max time.Duration
+	}{
+		{"under the cap", "30ms", 30 * time.Millisecond, maxDelay},
+		{"over the cap", "10s", maxDelay, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		client := &fakeEchoClient{echoFn: echoOK}
+		b := newTestServiceB(client)
+		b.maxDelay = maxDelay
+
+		start := time.Now()
+		rec := serve(http.HandlerFunc(b.callEcho), http.MethodGet, "/call-echo?msg=hi&delay="+tt.delay)
+		elapsed := time.Since(start)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", tt.name, rec.Code, rec.Body)
+		}
+		if elapsed < tt.min || elapsed >= tt.max {
+			t.Errorf("%s: delay=%s answered in %s, want [%s, %s)", tt.name, tt.delay, elapsed, tt.min, tt.max)
+		}
+		if n := client.calls.Load(); n != 1 {
+			t.Errorf("%s: A got %d calls, want 1", tt.name, n)
+		}
+	}
+}
+
+func TestCallEchoDelayDisabledByDefault(t *testing.T) {
+	client := &fakeEchoClient{echoFn: echoOK}
+	b := newTestServiceB(client)
+	b.maxDelay = 0
+
+	for _, delay := range []string{"10s", "bogus"} {
+		start := time.Now()
+		rec := serve(http.HandlerFunc(b.callEcho), http.MethodGet, "/call-echo?msg=hi&delay="+delay)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("delay=%s: status = %d, body %s", delay, rec.Code, rec.Body)
+		}
+		if elapsed := time.Since(start); elapsed >= time.Second {
+			t.Errorf("delay=%s answered in %s with ?delay= disabled, want right away", delay, elapsed)
+		}
+	}
+	if n := client.calls.Load(); n != 2 {
+		t.Errorf("A got %d calls, want 2", n)
+	}
+}
+
+func TestCallEchoDelayRejectsBadValues(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	for _, delay := range []string{"bogus", "-1s", "200"} {
+		client := &fakeEchoClient{echoFn: echoOK}
+		rec := serve(http.HandlerFunc(newTestServiceB(client).callEcho), http.MethodGet, "/call-echo?msg=hi&delay="+delay)
+		assertEnvelope(t, rec, http.StatusBadRequest, errCodeBadRequest, "")
+		if n := client.calls.Load(); n != 0 {
+			t.Errorf("delay=%s: A got %d calls, want 0", delay, n)
+		}
+	}
+}
+
+func TestCallEchoDelayStopsWhenClientCancels(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	client := &fakeEchoClient{echoFn: echoOK}
+	b := newTestServiceB(client)
+	b.maxDelay = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequest(http.MethodGet, "/call-echo?msg=hi&delay=30s", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	start := time.Now()
+	b.callEcho(rec, r)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("handler returned %s after the client gave up, want right away", elapsed)
+	}
+	assertEnvelope(t, rec, statusClientClosedRequest, errCodeClientClosedRequest, "")
+	if n := client.calls.Load(); n != 0 {
+		t.Errorf("A got %d calls after the client canceled, want 0", n)
+	}
+}
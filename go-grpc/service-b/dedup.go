@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// De-duplication of concurrent identical upstream calls
+// --------------------
+
+// upstreamGroup collapses concurrent identical echo calls to A into one;
+// see sharedEcho.
+var upstreamGroup singleflight.Group
+
+// echoResult is what concurrent identical callers share.
+type echoResult struct {
+	echo    string
+	trailer metadata.MD
+}
+
+// dedupKey identifies calls that would get the same answer from A: the same
+// endpoint, request and timeout, carrying the same outgoing metadata (e.g. a
+// forwarded Accept-Language). The request id is left out, since it differs
+// on every request.
+func dedupKey(ctx context.Context, endpoint string, req *echo.EchoRequest, timeout time.Duration) string {
+	var b strings.Builder
+	b.WriteString(endpoint + "\x00" + echoCacheKey(req) + "\x00" + timeout.String())
+	md, _ := metadata.FromOutgoingContext(ctx)
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		if k != echo.RequestIDMetadataKey {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString("\x00" + k + "=" + strings.Join(md[k], ","))
+	}
+	return b.String()
+}
+
+// sharedEcho runs call once for all concurrent callers with the same key and
+// hands each the result, reporting whether this caller was coalesced: it got
+// the result of a call another caller started, rather than its own. The
+// call runs on a context detached from any one caller's cancellation (but
+// bounded by timeout), so one client hanging up doesn't fail the others;
+// each caller still stops waiting when its own ctx is done. Results, errors
+// included, are only shared while the call is in flight.
+func sharedEcho(ctx context.Context, key string, timeout time.Duration, call func(context.Context) (echoResult, error)) (echoResult, bool, error) {
+	// Only the caller whose DoChan starts the call runs this function, and
+	// it finishes before the result is delivered, so reading leader once
+	// the result arrives is race-free.
+	leader := false
+	ch := upstreamGroup.DoChan(key, func() (any, error) {
+		leader = true
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		return call(callCtx)
+	})
+	select {
+	case res := <-ch:
+		coalesced := res.Shared && !leader
+		if res.Err != nil {
+			return echoResult{}, coalesced, res.Err
+		}
+		return res.Val.(echoResult), coalesced, nil
+	case <-ctx.Done():
+		return echoResult{}, false, status.FromContextError(ctx.Err()).Err()
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"grpc-echo-json/echo"
+)
+
+// blockingEcho answers once release is closed, signalling started first.
+func blockingEcho(started chan<- struct{}, release <-chan struct{}) func(context.Context, *echo.EchoRequest) (*echo.EchoResponse, error) {
+	return func(ctx context.Context, in *echo.EchoRequest) (*echo.EchoResponse, error) {
+		started <- struct{}{}
+		<-release
+		return echoOK(ctx, in)
+	}
+}
+
+// identicalEchoes sends n concurrent identical /call-echo requests to b,
+// whose upstream holds each call until every request has had time to
+// arrive, and returns A's client and the responses.
+func identicalEchoes(t *testing.T, coalesce bool, n int) (*fakeEchoClient, []*httptest.ResponseRecorder) {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	started := make(chan struct{}, n)
+	release := make(chan struct{})
+	client := &fakeEchoClient{echoFn: blockingEcho(started, release)}
+	b := newTestServiceB(client)
+	b.coalesce = coalesce
+	h := http.HandlerFunc(b.callEcho)
+
+	recs := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := range recs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = serve(h, http.MethodGet, "/call-echo?msg=same")
+		}()
+	}
+	<-started
+	// Give the other callers time to join (or start) their calls.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, rec := range recs {
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+		}
+	}
+	return client, recs
+}
+
+// coalescedCount returns how many responses carry X-Coalesced: true.
+func coalescedCount(recs []*httptest.ResponseRecorder) int {
+	n := 0
+	for _, rec := range recs {
+		if rec.Header().Get("X-Coalesced") == "true" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCallEchoCoalescesIdenticalRequests(t *testing.T) {
+	const callers = 20
+	client, _ := identicalEchoes(t, true, callers)
+	if n := client.calls.Load(); n != 1 {
+		t.Errorf("A got %d calls for %d identical requests, want 1", n, callers)
+	}
+}
+
+func TestCallEchoCoalescedHeaderAndMetric(t *testing.T) {
+	const (
+		callers = 20
+		series  = `echo_coalesced_requests_total{endpoint="/call-echo"}`
+	)
+	metrics := promhttp.Handler()
+	before := metricValue(t, scrape(t, metrics), series)
+
+	_, recs := identicalEchoes(t, true, callers)
+	// Every request but the one whose call the others joined is marked.
+	if n := coalescedCount(recs); n != callers-1 {
+		t.Errorf("%d responses marked X-Coalesced, want %d", n, callers-1)
+	}
+	if after := metricValue(t, scrape(t, metrics), series); after != before+callers-1 {
+		t.Errorf("%s = %v, want %v", series, after, before+callers-1)
+	}
+}
+
+func TestCallEchoCoalesceDisabled(t *testing.T) {
+	const callers = 5
+	client, recs := identicalEchoes(t, false, callers)
+	if n := client.calls.Load(); n != callers {
+		t.Errorf("A got %d calls for %d requests with -coalesce=false, want %d", n, callers, callers)
+	}
+	if n := coalescedCount(recs); n != 0 {
+		t.Errorf("%d responses marked X-Coalesced with -coalesce=false, want 0", n)
+	}
+}
+
+func TestCallEchoDoesNotShareErrorsAfterTheCall(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	client := &fakeEchoClient{echoFn: failingEcho(codes.InvalidArgument)}
+	b := newTestServiceB(client)
+	b.coalesce = true
+	for i := 0; i < 2; i++ {
+		if rec := serve(http.HandlerFunc(b.callEcho), http.MethodGet, "/call-echo?msg=bad"); rec.Code != http.StatusBadRequest {
+			t.Fatalf("call %d: status = %d, want 400", i, rec.Code)
+		}
+	}
+	if n := client.calls.Load(); n != 2 {
+		t.Errorf("A got %d calls for 2 sequential failing requests, want 2", n)
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	req := &echo.EchoRequest{Msg: "hi"}
+	base := context.Background()
+	key := dedupKey(base, "/call-echo", req, time.Second)
+
+	withID := metadata.AppendToOutgoingContext(base, echo.RequestIDMetadataKey, "req-1")
+	if got := dedupKey(withID, "/call-echo", req, time.Second); got != key {
+		t.Error("request id changed the key")
+	}
+	for name, other := range map[string]string{
+		"msg":      dedupKey(base, "/call-echo", &echo.EchoRequest{Msg: "bye"}, time.Second),
+		"endpoint": dedupKey(base, "/call-reverse", req, time.Second),
+		"timeout":  dedupKey(base, "/call-echo", req, 2*time.Second),
+		"metadata": dedupKey(metadata.AppendToOutgoingContext(base, "accept-language", "es"), "/call-echo", req, time.Second),
+	} {
+		if other == key {
+			t.Errorf("a different %s gave the same key", name)
+		}
+	}
+}
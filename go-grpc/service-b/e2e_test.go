@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/testutil"
+)
+
+// startEndToEnd serves impl as service A over bufconn and B's /call-echo
+// over HTTP in front of it, returning B's base URL.
+func startEndToEnd(t *testing.T, impl echo.EchoServiceServer) string {
+	t.Helper()
+	a, err := testutil.StartEchoServer(impl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(a.Close)
+
+	srv := testutil.StartServiceB(a.Conn, func(client echo.EchoServiceClient) http.Handler {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/call-echo", newTestServiceB(client).callEcho)
+		return mux
+	})
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// getJSON fetches url and decodes its JSON body.
+func getJSON(t *testing.T, url string) (int, map[string]any) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestCallEchoEndToEnd(t *testing.T) {
+	url := startEndToEnd(t, fakeServiceA{})
+
+	code, body := getJSON(t, url+"/call-echo?msg=hello")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, body %v", code, body)
+	}
+	if got := body["service_a"].(map[string]any)["echo"]; got != "hello" {
+		t.Errorf("service_a.echo = %v, want hello", got)
+	}
+}
+
+// rejectingServiceA fails every Echo with InvalidArgument.
+type rejectingServiceA struct {
+	echo.EchoServiceServer
+}
+
+func (rejectingServiceA) Echo(context.Context, *echo.EchoRequest) (*echo.EchoResponse, error) {
+	return nil, status.Error(codes.InvalidArgument, "msg rejected")
+}
+
+func TestCallEchoEndToEndUpstreamRejects(t *testing.T) {
+	url := startEndToEnd(t, rejectingServiceA{})
+
+	code, body := getJSON(t, url+"/call-echo?msg=hello")
+	if code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400; body %v", code, body)
+	}
+	if _, ok := body["error"].(map[string]any); !ok {
+		t.Errorf("body %v has no error envelope", body)
+	}
+}
+
+func TestCallEchoReportsUpstreamLatency(t *testing.T) {
+	a, err := testutil.StartEchoServer(fakeServiceA{}, grpc.UnaryInterceptor(
+		func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			_ = grpc.SetTrailer(ctx, metadata.Pairs("server-latency-ms", "42"))
+			return handler(ctx, req)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(a.Close)
+	b := newTestServiceB(a.Client())
+
+	rec := serve(http.HandlerFunc(b.callEcho), http.MethodGet, "/call-echo?msg=hi")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if got := decodeBody(t, rec)["upstream_latency_ms"]; got != float64(42) {
+		t.Errorf("upstream_latency_ms = %v, want A's trailer value 42", got)
+	}
+}
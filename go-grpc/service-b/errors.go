@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+
+	"google.golang.org/grpc/status"
+)
+
+// --------------------
+// Error envelope
+// --------------------
+
+// Machine-readable error codes, sent as error.code in every error response.
+// Clients should switch on these rather than on the message text.
+const (
+	errCodeBadRequest           = "BAD_REQUEST"
+	errCodeBodyTooLarge         = "BODY_TOO_LARGE"
+	errCodeRequestTimeout       = "REQUEST_TIMEOUT"
+	errCodeClientClosedRequest  = "CLIENT_CLOSED_REQUEST"
+	errCodeMethodNotAllowed     = "METHOD_NOT_ALLOWED"
+	errCodeForbidden            = "FORBIDDEN"
+	errCodeNotFound             = "NOT_FOUND"
+	errCodeRateLimited          = "RATE_LIMITED"
+	errCodeTooManyJobs          = "TOO_MANY_JOBS"
+	errCodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	errCodeInternal             = "INTERNAL"
+	errCodeCircuitOpen          = "CIRCUIT_OPEN"
+	errCodeUpstreamUnavailable  = "UPSTREAM_UNAVAILABLE"
+	errCodeUpstreamTimeout      = "UPSTREAM_TIMEOUT"
+	errCodeUpstreamError        = "UPSTREAM_ERROR"
+	errCodeUpstreamDecode       = "UPSTREAM_DECODE_ERROR"
+)
+
+// errorObject is the "error" member of an error response. grpc_code is set
+// when the error came back from service A.
+func errorObject(code, message string, err error) map[string]any {
+	obj := map[string]any{"code": code, "message": message}
+	if st, ok := status.FromError(err); ok && err != nil {
+		obj["grpc_code"] = st.Code().String()
+	}
+	return obj
+}
+
+// errorBody builds the envelope every error response shares:
+//
+//	{"service_b": "ok", "status": 503,
+//	 "error": {"code": "UPSTREAM_UNAVAILABLE", "message": "...", "grpc_code": "Unavailable"}}
+//
+// Handlers may add fields of their own (e.g. retry_after) before writing it.
+func errorBody(httpStatus int, code, message string, err error) map[string]any {
+	return map[string]any{
+		"service_b": "ok",
+		"status":    httpStatus,
+		"error":     errorObject(code, message, err),
+	}
+}
+
+// writeError writes the error envelope for err with the given HTTP status
+// and error code, using err's text as the message.
+func writeError(w http.ResponseWriter, r *http.Request, httpStatus int, code string, err error) {
+	writeJSON(w, r, httpStatus, errorBody(httpStatus, code, err.Error(), err))
+}
+
+// writeMethodNotAllowed rejects r, advertising the allowed methods.
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, allow string) {
+	w.Header().Set("Allow", allow)
+	writeJSON(w, r, http.StatusMethodNotAllowed,
+		errorBody(http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed", nil))
+}
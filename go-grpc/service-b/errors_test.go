@@ -0,0 +1,149 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/testutil"
+)
+
+// assertEnvelope checks the fields every error response shares.
+func assertEnvelope(t *testing.T, rec *httptest.ResponseRecorder, httpStatus int, code, grpcCode string) map[string]any {
+	t.Helper()
+	if rec.Code != httpStatus {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, httpStatus, rec.Body)
+	}
+	body := decodeBody(t, rec)
+	if body["service_b"] != "ok" || body["status"] != float64(httpStatus) {
+		t.Errorf("envelope = %v, want service_b ok and status %d", body, httpStatus)
+	}
+	errObj, ok := body["error"].(map[string]any)
+	if !ok {
+		t.Fatalf("body %v has no error object", body)
+	}
+	if errObj["code"] != code {
+		t.Errorf("error.code = %v, want %s", errObj["code"], code)
+	}
+	if msg, _ := errObj["message"].(string); msg == "" {
+		t.Error("error.message is empty")
+	}
+	if grpcCode != "" && errObj["grpc_code"] != grpcCode {
+		t.Errorf("error.grpc_code = %v, want %s", errObj["grpc_code"], grpcCode)
+	}
+	return body
+}
+
+func TestCallEchoErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       codes.Code
+		httpStatus int
+		errCode    string
+		reason     string
+		serviceA   string
+		retryAfter bool
+	}{
+		{"timeout", codes.DeadlineExceeded, http.StatusGatewayTimeout, errCodeUpstreamTimeout, "upstream_timeout", "unavailable", false},
+		{"unavailable", codes.Unavailable, http.StatusServiceUnavailable, errCodeUpstreamUnavailable, "upstream_down", "unavailable", true},
+		{"rejected", codes.InvalidArgument, http.StatusBadRequest, errCodeUpstreamError, "upstream_error", "error", false},
+		{"not found", codes.NotFound, http.StatusNotFound, errCodeUpstreamError, "upstream_error", "error", false},
+		{"unauthenticated", codes.Unauthenticated, http.StatusUnauthorized, errCodeUpstreamError, "upstream_error", "error", false},
+		{"exhausted", codes.ResourceExhausted, http.StatusTooManyRequests, errCodeUpstreamError, "upstream_error", "error", false},
+		{"internal", codes.Internal, http.StatusInternalServerError, errCodeUpstreamError, "upstream_error", "error", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestServiceB(&fakeEchoClient{echoFn: failingEcho(tt.code)})
+			rec := serve(http.HandlerFunc(b.callEcho), http.MethodGet, "/call-echo?msg=hi")
+			body := assertEnvelope(t, rec, tt.httpStatus, tt.errCode, tt.code.String())
+			if body["reason"] != tt.reason || body["service_a"] != tt.serviceA {
+				t.Errorf("reason, service_a = %v, %v; want %s, %s", body["reason"], body["service_a"], tt.reason, tt.serviceA)
+			}
+			if got := rec.Header().Get("Retry-After") != ""; got != tt.retryAfter {
+				t.Errorf("Retry-After present = %t, want %t", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestCallEchoCircuitOpenEnvelope(t *testing.T) {
+	b := newTestServiceB(&fakeEchoClient{echoFn: failingEcho(codes.Unavailable)})
+	b.upstream.breaker = newBreaker(1, time.Minute)
+	h := http.HandlerFunc(b.callEcho)
+
+	serve(h, http.MethodGet, "/call-echo?msg=hi") // opens the breaker
+	rec := serve(h, http.MethodGet, "/call-echo?msg=hi")
+	body := assertEnvelope(t, rec, http.StatusServiceUnavailable, errCodeCircuitOpen, "")
+	if body["circuit"] != "open" || body["reason"] != "circuit_open" {
+		t.Errorf("circuit = %v, reason = %v; want open and circuit_open", body["circuit"], body["reason"])
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("circuit-open 503 without Retry-After")
+	}
+}
+
+func TestWriteMethodNotAllowedEnvelope(t *testing.T) {
+	b := newTestServiceB(&fakeEchoClient{echoFn: echoOK})
+	rec := serve(http.HandlerFunc(b.callEcho), http.MethodDelete, "/call-echo")
+	assertEnvelope(t, rec, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "")
+	if rec.Header().Get("Allow") == "" {
+		t.Error("405 without Allow")
+	}
+}
+
+// garbageCodec reads requests as JSON but answers with bytes no codec can
+// decode, like a service A writing a truncated body.
+type garbageCodec struct{}
+
+func (garbageCodec) Marshal(any) ([]byte, error) { return []byte(`{"echo": "tru`), nil }
+func (garbageCodec) Unmarshal(data []byte, v any) error {
+	return encoding.GetCodec(echo.JSONCodecName).Unmarshal(data, v)
+}
+func (garbageCodec) Name() string { return echo.JSONCodecName }
+
+func TestCallEchoUpstreamDecodeError(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	srv, err := testutil.StartEchoServer(fakeServiceA{}, grpc.ForceServerCodec(garbageCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	b := newTestServiceB(srv.Client())
+
+	rec := serve(http.HandlerFunc(b.callEcho), http.MethodGet, "/call-echo?msg=hi")
+	body := assertEnvelope(t, rec, http.StatusBadGateway, errCodeUpstreamDecode, "Internal")
+	if body["reason"] != "upstream_decode_error" || body["service_a"] != "error" {
+		t.Errorf("body = %v, want reason upstream_decode_error and service_a error", body)
+	}
+}
+
+func TestIsUpstreamDecodeError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"decode failure", status.Error(codes.Internal, decodeErrorPrefix+" (codec json): unexpected EOF"), true},
+		{"internal error from A", status.Error(codes.Internal, "upstream said no"), false},
+		{"other code", status.Error(codes.Unavailable, decodeErrorPrefix), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isUpstreamDecodeError(tt.err); got != tt.want {
+			t.Errorf("%s: isUpstreamDecodeError = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// --------------------
+// HTTP header forwarding (B -> A metadata)
+// --------------------
+
+// parseForwardHeaders splits the comma-separated -forward-headers value into
+// canonical HTTP header names. Accept-Language is always included so A can
+// localize its error messages.
+func parseForwardHeaders(list string) []string {
+	names := []string{"Accept-Language"}
+	for _, h := range strings.Split(list, ",") {
+		if h = http.CanonicalHeaderKey(strings.TrimSpace(h)); h != "" && !slices.Contains(names, h) {
+			names = append(names, h)
+		}
+	}
+	return names
+}
+
+// forwardHeadersMiddleware copies each named header present on the request
+// into the outgoing gRPC metadata of the request context, under the
+// lower-cased header name (e.g. Tenant-Id -> tenant-id), so every call B
+// makes to A on behalf of the request carries it.
+func forwardHeadersMiddleware(names []string, next http.Handler) http.Handler {
+	if len(names) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var kv []string
+		for _, name := range names {
+			if v := r.Header.Get(name); v != "" {
+				kv = append(kv, strings.ToLower(name), v)
+			}
+		}
+		if len(kv) > 0 {
+			r = r.WithContext(metadata.AppendToOutgoingContext(r.Context(), kv...))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
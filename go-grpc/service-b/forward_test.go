@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestForwardHeadersMiddleware(t *testing.T) {
+	var got metadata.MD
+	h := forwardHeadersMiddleware(parseForwardHeaders("tenant-id, X-Caller"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = metadata.FromOutgoingContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/call-echo?msg=hi", nil)
+	req.Header.Set("Tenant-Id", "acme")
+	req.Header.Set("Accept-Language", "es")
+	req.Header.Set("X-Other", "not forwarded")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if v := got.Get("tenant-id"); !reflect.DeepEqual(v, []string{"acme"}) {
+		t.Errorf("tenant-id metadata = %q, want [acme]", v)
+	}
+	// Accept-Language is forwarded without being listed.
+	if v := got.Get("accept-language"); !reflect.DeepEqual(v, []string{"es"}) {
+		t.Errorf("accept-language metadata = %q, want [es]", v)
+	}
+	for _, k := range []string{"x-caller", "x-other"} {
+		if v := got.Get(k); len(v) != 0 {
+			t.Errorf("%s metadata = %q, want none", k, v)
+		}
+	}
+}
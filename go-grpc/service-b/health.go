@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"grpc-echo-json/echo"
+)
+
+// checkServiceA asks service A's standard grpc.health.v1.Health service
+// whether the echo service is serving. The health messages are real protobuf
+// types, so the call always uses the proto codec regardless of -codec.
+func checkServiceA(ctx context.Context, hc healthpb.HealthClient) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	resp, err := hc.Check(ctx, &healthpb.HealthCheckRequest{Service: echo.ServiceName},
+		grpc.CallContentSubtype(echo.ProtoCodecName))
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN, err
+	}
+	return resp.GetStatus(), nil
+}
+
+// watchRetryDelay is how long watchServiceA waits before re-opening a Watch
+// stream that ended.
+const watchRetryDelay = time.Second
+
+// watchServiceA subscribes to service A's grpc.health.v1.Health/Watch
+// stream and records every status A pushes, so readiness follows A as soon
+// as it changes instead of at the next poll. A failed or ended stream counts
+// as unhealthy and is re-opened after watchRetryDelay, until ctx is done.
+func watchServiceA(ctx context.Context, hc healthpb.HealthClient, r *readiness) {
+	for {
+		err := watchOnce(ctx, hc, r)
+		if ctx.Err() != nil {
+			return
+		}
+		r.record(fmt.Errorf("health watch ended: %w", err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryDelay):
+		}
+	}
+}
+
+func watchOnce(ctx context.Context, hc healthpb.HealthClient, r *readiness) error {
+	stream, err := hc.Watch(ctx, &healthpb.HealthCheckRequest{Service: echo.ServiceName},
+		grpc.CallContentSubtype(echo.ProtoCodecName))
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		st := resp.GetStatus()
+		log.Printf("service=B upstream=A health_watch=%s", st)
+		if st == healthpb.HealthCheckResponse_SERVING {
+			r.record(nil)
+		} else {
+			r.record(fmt.Errorf("service A health status is %s", st))
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"grpc-echo-json/echo"
+)
+
+// serveHealth serves a standard health server on a local port and returns
+// it with a client for it.
+func serveHealth(t *testing.T) (*health.Server, healthpb.HealthClient) {
+	t.Helper()
+	echo.RegisterCodecs()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := health.NewServer()
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return hs, healthpb.NewHealthClient(conn)
+}
+
+// waitHealthy waits for r to report healthy == want.
+func waitHealthy(t *testing.T, r *readiness, want bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		healthy, reason := r.status()
+		if healthy == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("readiness healthy = %t (%s), want %t", healthy, reason, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatchServiceAFollowsStatus(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	hs, hc := serveHealth(t)
+	hs.SetServingStatus(echo.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	r := newReadiness("a:50051", 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchServiceA(ctx, hc, r)
+	}()
+	defer func() { cancel(); <-done }()
+
+	waitHealthy(t, r, true)
+	hs.SetServingStatus(echo.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	waitHealthy(t, r, false)
+	if _, reason := r.status(); reason != "service A health status is NOT_SERVING" {
+		t.Errorf("reason = %q", reason)
+	}
+	hs.SetServingStatus(echo.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	waitHealthy(t, r, true)
+}
+
+func TestCheckServiceA(t *testing.T) {
+	hs, hc := serveHealth(t)
+	hs.SetServingStatus(echo.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if st, err := checkServiceA(ctx, hc); err != nil || st != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("checkServiceA = %s, %v; want NOT_SERVING", st, err)
+	}
+}
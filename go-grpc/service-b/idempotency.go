@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// --------------------
+// Idempotency-Key replay (/call-echo)
+// --------------------
+
+// maxIdempotencyKeyLen bounds the Idempotency-Key header B will store.
+const maxIdempotencyKeyLen = 255
+
+// storedResponse is a response kept for replay under its Idempotency-Key,
+// with the fingerprint of the request that produced it.
+type storedResponse struct {
+	fingerprint [sha256.Size]byte
+	status      int
+	contentType string
+	body        []byte
+}
+
+// idempotency replays the stored response for a repeated Idempotency-Key
+// instead of running the request again, marked by Idempotent-Replayed: true.
+// Keys are scoped to the request's method and path. Reusing a key with a
+// different payload (query string or body) is rejected with 422 rather than
+// replaying a response to some other request.
+//
+// Only 2xx and definitive 4xx responses are stored (see replayable), so an
+// attempt that failed for a transient reason can be retried with the same
+// key. Two concurrent first attempts with one key both run; the later one's
+// response is kept. A nil *idempotency passes every request straight
+// through.
+type idempotency struct {
+	responses *cache[storedResponse]
+}
+
+// newIdempotency keeps up to size keyed responses for ttl each, or returns
+// nil (no replay) when either is <= 0.
+func newIdempotency(size int, ttl time.Duration) *idempotency {
+	c := newCache[storedResponse](size, ttl)
+	if c == nil {
+		return nil
+	}
+	return &idempotency{responses: c}
+}
+
+// replayable reports whether a response with this status is kept for
+// replay. 5xx, and 4xx statuses that say "not now" rather than "never"
+// (timeout, conflict, rate limit, client hung up), are left out so a retry
+// with the same key runs again.
+func replayable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests, statusClientClosedRequest:
+		return false
+	}
+	return status >= 200 && status < 300 || status >= 400 && status < 500
+}
+
+// requestFingerprint hashes the parts of r that make up its payload: the
+// query string and body.
+func requestFingerprint(r *http.Request, body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(r.URL.RawQuery))
+	h.Write([]byte{0})
+	h.Write(body)
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (i *idempotency) middleware(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	if i == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeBadRequest(w, r, endpoint, time.Now(), fmt.Errorf("Idempotency-Key must be at most %d bytes", maxIdempotencyKeyLen))
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			if limit, ok := bodyTooLarge(err); ok {
+				writeBodyTooLarge(w, r, limit)
+				return
+			}
+			writeBadRequest(w, r, endpoint, time.Now(), fmt.Errorf("read body: %w", err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r, body)
+		key = r.Method + " " + r.URL.Path + " " + key
+
+		if prev, ok := i.responses.Get(key); ok {
+			if prev.fingerprint != fingerprint {
+				log.Printf("service=B endpoint=%s status=error error=\"idempotency key reused\"", endpoint)
+				writeError(w, r, http.StatusUnprocessableEntity, errCodeIdempotencyKeyReused,
+					errors.New("Idempotency-Key was already used with a different request"))
+				return
+			}
+			log.Printf("service=B endpoint=%s status=ok idempotent_replay=true", endpoint)
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.Header().Set("Content-Type", prev.contentType)
+			w.WriteHeader(prev.status)
+			_, _ = w.Write(prev.body)
+			return
+		}
+		rec := &recordingWriter{ResponseWriter: w}
+		next(rec, r)
+		if replayable(rec.status) {
+			i.responses.Set(key, storedResponse{
+				fingerprint: fingerprint,
+				status:      rec.status,
+				contentType: rec.Header().Get("Content-Type"),
+				body:        rec.body.Bytes(),
+			})
+		}
+	}
+}
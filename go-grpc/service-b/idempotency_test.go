@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// keyedRequest runs a request carrying Idempotency-Key key through h.
+func keyedRequest(h http.Handler, method, target, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Idempotency-Key", key)
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+// countingHandler answers with status and counts the requests it serves.
+func countingHandler(status int, calls *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"n":` + strconv.Itoa(int(n)) + `}`))
+	}
+}
+
+func TestIdempotencyReplaysRepeatedKey(t *testing.T) {
+	var calls atomic.Int32
+	h := newIdempotency(10, time.Minute).middleware("/call-echo", countingHandler(http.StatusOK, &calls))
+
+	first := keyedRequest(h, http.MethodGet, "/call-echo?msg=hi", "k1", "")
+	second := keyedRequest(h, http.MethodGet, "/call-echo?msg=hi", "k1", "")
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler ran %d times, want 1", n)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("Idempotent-Replayed should be set on the replay only")
+	}
+}
+
+func TestIdempotencyDistinctKeysRunSeparately(t *testing.T) {
+	var calls atomic.Int32
+	h := newIdempotency(10, time.Minute).middleware("/call-echo", countingHandler(http.StatusOK, &calls))
+
+	keyedRequest(h, http.MethodGet, "/call-echo?msg=hi", "k1", "")
+	keyedRequest(h, http.MethodGet, "/call-echo?msg=hi", "k2", "")
+	keyedRequest(h, http.MethodPost, "/call-echo", "k1", `{"msg":"hi"}`)
+	if n := calls.Load(); n != 3 {
+		t.Errorf("handler ran %d times, want 3 (two keys, and k1 on another method)", n)
+	}
+}
+
+func TestIdempotencyEntriesExpire(t *testing.T) {
+	var calls atomic.Int32
+	idem := newIdempotency(10, time.Minute)
+	clock := time.Unix(1000, 0)
+	idem.responses.now = func() time.Time { return clock }
+	h := idem.middleware("/call-echo", countingHandler(http.StatusOK, &calls))
+
+	keyedRequest(h, http.MethodGet, "/call-echo?msg=hi", "k1", "")
+	clock = clock.Add(time.Minute)
+	rec := keyedRequest(h, http.MethodGet, "/call-echo?msg=hi", "k1", "")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("handler ran %d times, want 2 once the key expired", n)
+	}
+	if rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("expired key was replayed")
+	}
+}
+
+func TestIdempotencyRejectsKeyReuseWithOtherPayload(t *testing.T) {
+	var calls atomic.Int32
+	h := newIdempotency(10, time.Minute).middleware("/call-echo", countingHandler(http.StatusOK, &calls))
+
+	keyedRequest(h, http.MethodPost, "/call-echo", "k1", `{"msg":"hi"}`)
+	rec := keyedRequest(h, http.MethodPost, "/call-echo", "k1", `{"msg":"bye"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rec.Code)
+	}
+	if code := decodeBody(t, rec)["error"].(map[string]any)["code"]; code != errCodeIdempotencyKeyReused {
+		t.Errorf("error.code = %v, want %s", code, errCodeIdempotencyKeyReused)
+	}
+	if rec := keyedRequest(h, http.MethodGet, "/call-echo?msg=other", "k2", ""); rec.Code != http.StatusOK {
+		t.Fatalf("first use of k2 = %d", rec.Code)
+	}
+	if rec := keyedRequest(h, http.MethodGet, "/call-echo?msg=changed", "k2", ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("k2 with another query = %d, want 422", rec.Code)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("handler ran %d times, want 2", n)
+	}
+}
+
+func TestIdempotencyHandlerStillReadsBody(t *testing.T) {
+	var got *string
+	h := newIdempotency(10, time.Minute).middleware("/call-echo", func(w http.ResponseWriter, r *http.Request) {
+		req, err := echoRequestFrom(r)
+		if err != nil {
+			t.Fatalf("echoRequestFrom: %v", err)
+		}
+		got = &req.Msg
+	})
+	keyedRequest(h, http.MethodPost, "/call-echo", "k1", `{"msg":"hi"}`)
+	if got == nil || *got != "hi" {
+		t.Errorf("handler read msg %v, want hi", got)
+	}
+}
+
+func TestIdempotencyStoresOnlyDefinitiveResponses(t *testing.T) {
+	tests := []struct {
+		status int
+		stored bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusRequestTimeout, false},
+		{http.StatusConflict, false},
+		{http.StatusTooManyRequests, false},
+		{statusClientClosedRequest, false},
+		{http.StatusBadGateway, false},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			var calls atomic.Int32
+			h := newIdempotency(10, time.Minute).middleware("/call-echo", countingHandler(tt.status, &calls))
+			keyedRequest(h, http.MethodGet, "/call-echo?msg=hi", "k1", "")
+			keyedRequest(h, http.MethodGet, "/call-echo?msg=hi", "k1", "")
+			want := int32(2)
+			if tt.stored {
+				want = 1
+			}
+			if n := calls.Load(); n != want {
+				t.Errorf("handler ran %d times for status %d, want %d", n, tt.status, want)
+			}
+		})
+	}
+}
+
+func TestIdempotencyRejectsLongKey(t *testing.T) {
+	var calls atomic.Int32
+	h := newIdempotency(10, time.Minute).middleware("/call-echo", countingHandler(http.StatusOK, &calls))
+	rec := keyedRequest(h, http.MethodGet, "/call-echo?msg=hi", strings.Repeat("k", maxIdempotencyKeyLen+1), "")
+	if rec.Code != http.StatusBadRequest || calls.Load() != 0 {
+		t.Errorf("status = %d after %d calls, want 400 without running the handler", rec.Code, calls.Load())
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// --------------------
+// Async echo jobs (/call-echo-async, /jobs/{id})
+// --------------------
+
+// job is one /call-echo-async request. Its fields are guarded by mu because
+// the background call fills them in while clients poll. Responses report
+// state as "state", leaving "status" for the HTTP status as elsewhere.
+type job struct {
+	mu       sync.Mutex
+	state    string // "pending", "done" or "failed"
+	echo     string
+	code     string
+	err      string
+	created  time.Time
+	finished time.Time
+}
+
+// snapshot returns the job as GET /jobs/{id} reports it.
+func (j *job) snapshot(id string) map[string]any {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	body := map[string]any{
+		"service_b": "ok",
+		"job_id":    id,
+		"state":     j.state,
+		"created":   j.created.UTC().Format(time.RFC3339Nano),
+	}
+	switch j.state {
+	case "done":
+		body["service_a"] = map[string]any{"echo": j.echo}
+	case "failed":
+		body["code"] = j.code
+		body["error"] = j.err
+	}
+	if !j.finished.IsZero() {
+		body["finished"] = j.finished.UTC().Format(time.RFC3339Nano)
+	}
+	return body
+}
+
+// finish records the outcome of the job's call and returns its final state.
+func (j *job) finish(msg string, err error) string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finished = time.Now()
+	if err != nil {
+		j.state, j.code, j.err = "failed", status.Code(err).String(), err.Error()
+	} else {
+		j.state, j.echo = "done", msg
+	}
+	return j.state
+}
+
+// jobRunner runs async jobs' upstream calls in the background, at most
+// limit at once when a limit is set, and lets shutdown wait for the ones
+// still running.
+type jobRunner struct {
+	slots chan struct{} // nil when there is no cap
+
+	mu      sync.Mutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+// newJobRunner caps running jobs at limit; limit <= 0 means no cap.
+func newJobRunner(limit int) *jobRunner {
+	r := &jobRunner{}
+	if limit > 0 {
+		r.slots = make(chan struct{}, limit)
+	}
+	return r
+}
+
+// start runs fn on its own goroutine and reports true, or reports false
+// without running it if limit jobs are already running or shutdown has
+// begun.
+func (r *jobRunner) start(fn func()) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	if r.slots != nil {
+		select {
+		case r.slots <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	r.running.Add(1)
+	go func() {
+		defer func() {
+			if r.slots != nil {
+				<-r.slots
+			}
+			r.running.Done()
+		}()
+		fn()
+	}()
+	return true
+}
+
+// shutdown stops new jobs from starting and waits for running ones to
+// finish, or for ctx to be done.
+func (r *jobRunner) shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() { r.running.Wait(); close(done) }()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// callEchoAsync accepts the same input as /call-echo but answers 202 with a
+// job_id straight away, calling A in the background. Jobs are kept in
+// b.jobs, a bounded LRU whose entries expire after -job-ttl, so a job whose
+// result is never fetched doesn't stay around. While -job-concurrency jobs
+// are already calling A, further requests are refused with 503 and a
+// Retry-After header.
+func (b *serviceB) callEchoAsync(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, "GET, POST")
+		return
+	}
+	req, err := echoRequestFrom(r)
+	if err != nil {
+		writeBadRequest(w, r, "/call-echo-async", start, err)
+		return
+	}
+	timeout, err := b.timeoutFor(r)
+	if err != nil {
+		writeBadRequest(w, r, "/call-echo-async", start, err)
+		return
+	}
+
+	id := newRequestID()
+	j := &job{state: "pending", created: start}
+
+	// The call outlives the HTTP request, so it keeps the request's values
+	// (request id, forwarded metadata, trace) but not its cancellation.
+	ctx := context.WithoutCancel(r.Context())
+	started := b.jobRunner.start(func() {
+		ctxUp, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		msg, err := b.upstream.Echo(ctxUp, req.Msg, withTransform(req.Transform))
+		state := j.finish(msg, err)
+		log.Printf("service=B endpoint=/call-echo-async job_id=%s state=%s code=%s latency_ms=%d",
+			id, state, status.Code(err), time.Since(start).Milliseconds())
+	})
+	if !started {
+		log.Printf("service=B endpoint=/call-echo-async status=error error=\"too many jobs\" latency_ms=%d", time.Since(start).Milliseconds())
+		w.Header().Set("Retry-After", "1")
+		body := errorBody(http.StatusServiceUnavailable, errCodeTooManyJobs, "too many async jobs running, retry later", nil)
+		body["retry_after"] = 1
+		writeJSON(w, r, http.StatusServiceUnavailable, body)
+		return
+	}
+	b.jobs.Set(id, j)
+
+	w.Header().Set("Location", "/jobs/"+id)
+	writeJSON(w, r, http.StatusAccepted, map[string]any{
+		"service_b": "ok",
+		"job_id":    id,
+		"state":     "pending",
+		"status":    http.StatusAccepted,
+	})
+}
+
+// getJob serves GET /jobs/{id}: the job's status and, once finished, its
+// result or error. Unknown and expired ids are 404.
+func (b *serviceB) getJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, r, "GET")
+		return
+	}
+	id := r.PathValue("id")
+	j, ok := b.jobs.Get(id)
+	if !ok {
+		body := errorBody(http.StatusNotFound, errCodeNotFound, "unknown or expired job", nil)
+		body["job_id"] = id
+		writeJSON(w, r, http.StatusNotFound, body)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, j.snapshot(id))
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-echo-json/echo"
+)
+
+// newJobsServiceB returns a test serviceB with async jobs enabled, at most
+// concurrency of them running at once, proxying to client.
+func newJobsServiceB(client echo.EchoServiceClient, concurrency int) (*serviceB, http.Handler) {
+	b := newTestServiceB(client)
+	b.jobs = newCache[*job](10, time.Minute)
+	b.jobRunner = newJobRunner(concurrency)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/call-echo-async", b.callEchoAsync)
+	mux.HandleFunc("/jobs/{id}", b.getJob)
+	return b, mux
+}
+
+// enqueue submits an async echo of msg and returns its job id.
+func enqueue(t *testing.T, h http.Handler, msg string) string {
+	t.Helper()
+	rec := serve(h, http.MethodGet, "/call-echo-async?msg="+msg)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("enqueue = %d, want 202; body %s", rec.Code, rec.Body)
+	}
+	body := decodeBody(t, rec)
+	id, _ := body["job_id"].(string)
+	if id == "" || body["state"] != "pending" {
+		t.Fatalf("enqueue body = %v, want a job_id in state pending", body)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/jobs/"+id {
+		t.Errorf("Location = %q, want /jobs/%s", loc, id)
+	}
+	return id
+}
+
+func TestAsyncJobPendingThenDone(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	b, h := newJobsServiceB(&fakeEchoClient{echoFn: blockingEcho(started, release)}, 1)
+
+	id := enqueue(t, h, "hi")
+	<-started
+	if state := decodeBody(t, serve(h, http.MethodGet, "/jobs/"+id))["state"]; state != "pending" {
+		t.Errorf("state while A is working = %v, want pending", state)
+	}
+
+	close(release)
+	if err := b.jobRunner.shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	body := decodeBody(t, serve(h, http.MethodGet, "/jobs/"+id))
+	if body["state"] != "done" || body["service_a"].(map[string]any)["echo"] != "hi" {
+		t.Errorf("finished job = %v, want state done with echo hi", body)
+	}
+}
+
+func TestAsyncJobUnknownID(t *testing.T) {
+	_, h := newJobsServiceB(&fakeEchoClient{echoFn: echoOK}, 0)
+	rec := serve(h, http.MethodGet, "/jobs/no-such-job")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+	if code := decodeBody(t, rec)["error"].(map[string]any)["code"]; code != errCodeNotFound {
+		t.Errorf("error.code = %v, want %s", code, errCodeNotFound)
+	}
+}
+
+func TestAsyncJobsCapped(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	b, h := newJobsServiceB(&fakeEchoClient{echoFn: blockingEcho(started, release)}, 1)
+
+	enqueue(t, h, "first")
+	<-started
+	rec := serve(h, http.MethodGet, "/call-echo-async?msg=second")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("job over the cap = %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("503 without Retry-After")
+	}
+	if code := decodeBody(t, rec)["error"].(map[string]any)["code"]; code != errCodeTooManyJobs {
+		t.Errorf("error.code = %v, want %s", code, errCodeTooManyJobs)
+	}
+
+	close(release)
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		rec := serve(h, http.MethodGet, "/call-echo-async?msg=third")
+		if rec.Code == http.StatusAccepted {
+			<-started
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job after the first finished = %d, want 202", rec.Code)
+		}
+	}
+	if err := b.jobRunner.shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJobRunnerShutdownWaitsForJobs(t *testing.T) {
+	r := newJobRunner(2)
+	release := make(chan struct{})
+	finished := false
+	r.start(func() { <-release; finished = true })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.shutdown(ctx); err == nil {
+		t.Fatal("shutdown returned while a job was still running")
+	}
+	if r.start(func() {}) {
+		t.Error("a job started after shutdown began")
+	}
+
+	close(release)
+	if err := r.shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !finished {
+		t.Error("shutdown returned before the job finished")
+	}
+}
+
+func TestJobRunnerWithoutCapWaitsForJobs(t *testing.T) {
+	r := newJobRunner(0)
+	release := make(chan struct{})
+	var finished atomic.Int32
+	for range 3 {
+		if !r.start(func() { <-release; finished.Add(1) }) {
+			t.Fatal("a job was refused with no cap")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.shutdown(ctx); err == nil {
+		t.Fatal("shutdown returned while jobs were still running")
+	}
+
+	close(release)
+	if err := r.shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := finished.Load(); n != 3 {
+		t.Errorf("shutdown returned after %d of 3 jobs finished", n)
+	}
+}
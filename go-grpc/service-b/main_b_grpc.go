@@ -0,0 +1,1030 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding/gzip"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/config"
+	"grpc-echo-json/echo"
+	"grpc-echo-json/logging"
+	"grpc-echo-json/tlsreload"
+	"grpc-echo-json/tracing"
+	"grpc-echo-json/version"
+)
+
+// --------------------
+// HTTP logging (service B)
+// --------------------
+
+// statusCapturingWriter records the status actually sent: the first final
+// WriteHeader, or 200 if the handler writes a body without one. As in
+// net/http, later WriteHeader calls don't change it; 1xx informational
+// responses are passed through without being recorded.
+type statusCapturingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusCapturingWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code >= 200 {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusCapturingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers flush through the logging middleware.
+func (w *statusCapturingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *statusCapturingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// httpLogLevel picks the log level for a finished request: successes at
+// debug, client errors at warn and server/upstream errors at error.
+func httpLogLevel(status int) slog.Level {
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	default:
+		return slog.LevelDebug
+	}
+}
+
+// httpLoggingMiddleware logs and measures every request. Metrics are
+// labelled with route(r) rather than the path; see routeOf.
+func httpLoggingMiddleware(logger *logging.Logger, serviceName string, route func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r = withRequestID(r, w)
+		sw := &statusCapturingWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		elapsed := time.Since(start)
+		observeHTTP(route(r), sw.status, elapsed)
+
+		overall := "ok"
+		if sw.status >= 400 {
+			overall = "error"
+		}
+
+		logger.Request(httpLogLevel(sw.status), "service", serviceName, "endpoint", r.URL.Path, "status", overall,
+			"http_status", sw.status, "request_id", requestIDFrom(r.Context()), "latency_ms", elapsed.Milliseconds())
+	})
+}
+
+// --------------------
+// Service B handlers
+// --------------------
+
+type serviceB struct {
+	echoClient      echo.EchoServiceClient
+	upstream        *resilientEchoClient
+	coalesce        bool
+	upstreamTimeout time.Duration
+	maxTimeout      time.Duration
+	maxDelay        time.Duration
+	ready           *upstreamReadiness
+	echoCache       *cache[string]
+	jobs            *cache[*job]
+	jobRunner       *jobRunner
+}
+
+func (b *serviceB) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// internalErrorBody is sent when a response body can't be marshaled; it is
+// static so writing it can't fail the same way.
+const internalErrorBody = `{"service_b":"error","status":500,"error":{"code":"INTERNAL","message":"failed to encode response"}}`
+
+// wantsCompactJSON reports whether the client asked for compact JSON with
+// ?pretty=0 (or false). The default is indented output, which reads better
+// from curl.
+func wantsCompactJSON(r *http.Request) bool {
+	if v := r.URL.Query().Get("pretty"); v != "" {
+		pretty, err := strconv.ParseBool(v)
+		return err == nil && !pretty
+	}
+	return false
+}
+
+// writeJSON writes body with the given HTTP status, indented unless r asks
+// for compact output (see wantsCompactJSON).
+func writeJSON(w http.ResponseWriter, r *http.Request, httpStatus int, body any) {
+	var (
+		b   []byte
+		err error
+	)
+	if wantsCompactJSON(r) {
+		b, err = json.Marshal(body)
+	} else {
+		b, err = json.MarshalIndent(body, "", "  ")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		log.Printf("service=B failed to marshal response body: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = io.WriteString(w, internalErrorBody)
+		return
+	}
+	w.WriteHeader(httpStatus)
+	_, _ = w.Write(b)
+}
+
+// writeBadRequest logs and rejects a request B couldn't make sense of. A
+// body over -max-http-body gets 413, and one that didn't arrive within
+// -read-timeout gets 408.
+func writeBadRequest(w http.ResponseWriter, r *http.Request, endpoint string, start time.Time, err error) {
+	if limit, ok := bodyTooLarge(err); ok {
+		writeBodyTooLarge(w, r, limit)
+		return
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		log.Printf("service=B endpoint=%s status=error error=\"request body read timed out\" latency_ms=%d",
+			endpoint, time.Since(start).Milliseconds())
+		writeError(w, r, http.StatusRequestTimeout, errCodeRequestTimeout, errors.New("timed out reading the request body"))
+		return
+	}
+	log.Printf("service=B endpoint=%s status=error error=%q latency_ms=%d",
+		endpoint, err.Error(), time.Since(start).Milliseconds())
+	writeError(w, r, http.StatusBadRequest, errCodeBadRequest, err)
+}
+
+// echoRequestFrom reads the message for /call-echo: the msg (and optional
+// transform) query parameters for GET, or a {"msg": "...", "transform": "..."}
+// JSON body for POST.
+func echoRequestFrom(r *http.Request) (*echo.EchoRequest, error) {
+	if r.Method != http.MethodPost {
+		q := r.URL.Query()
+		return &echo.EchoRequest{Msg: q.Get("msg"), Transform: q.Get("transform")}, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return nil, errors.New("content type must be application/json")
+	}
+
+	req := new(echo.EchoRequest)
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(req); err != nil {
+		return nil, fmt.Errorf("malformed JSON body: %w", err)
+	}
+	return req, nil
+}
+
+// writeUpstreamError logs a failed B -> A call and writes the error response.
+// Independent failure: if A is stopped, it returns 503; other failures map to
+// the closest HTTP status for their gRPC code. timeout is the upstream
+// timeout the call ran with (0 for streams, which have none).
+//
+// Every response carries a "reason" saying which side of the call failed:
+// "circuit_open" (with "circuit": "open"), "upstream_timeout",
+// "upstream_down", "upstream_decode_error" (a 502: A answered with bytes B
+// can't decode) or "upstream_error" (A reported an error). When A is down,
+// the response carries a Retry-After header and matching "retry_after"
+// field, in seconds.
+func writeUpstreamError(w http.ResponseWriter, r *http.Request, endpoint string, start time.Time, timeout time.Duration, err error) {
+	if errors.Is(err, errCircuitOpen) {
+		retryAfter := retryAfterSeconds(err)
+		log.Printf("service=B endpoint=%s status=error reason=circuit_open retry_after_s=%d latency_ms=%d",
+			endpoint, retryAfter, time.Since(start).Milliseconds())
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		body := errorBody(http.StatusServiceUnavailable, errCodeCircuitOpen, err.Error(), nil)
+		body["service_a"] = "unavailable"
+		body["circuit"] = "open"
+		body["reason"] = "circuit_open"
+		body["retry_after"] = retryAfter
+		writeJSON(w, r, http.StatusServiceUnavailable, body)
+		return
+	}
+
+	code := status.Code(err)
+	if isUpstreamDecodeError(err) {
+		// A answered, but with a body B's codec can't read: a bad gateway
+		// rather than an error A reported.
+		log.Printf("service=B endpoint=%s status=error code=%s reason=upstream_decode_error error=%q latency_ms=%d",
+			endpoint, code, err.Error(), time.Since(start).Milliseconds())
+		body := errorBody(http.StatusBadGateway, errCodeUpstreamDecode,
+			"could not decode service A's response: "+status.Convert(err).Message(), err)
+		body["service_a"] = "error"
+		body["reason"] = "upstream_decode_error"
+		writeJSON(w, r, http.StatusBadGateway, body)
+		return
+	}
+
+	// The error code tells callers what to do about it: a timeout may
+	// succeed with a longer ?timeout=, an unavailable upstream needs A
+	// restarted, anything else is a problem with the request itself.
+	var serviceAState, message, errCode, reason string
+	switch code {
+	case codes.DeadlineExceeded:
+		serviceAState, message, errCode, reason = "unavailable", "timed out waiting for service A", errCodeUpstreamTimeout, "upstream_timeout"
+	case codes.Unavailable:
+		serviceAState, message, errCode, reason = "unavailable", "failed to reach service A", errCodeUpstreamUnavailable, "upstream_down"
+	default:
+		serviceAState, message, errCode, reason = "error", "service A rejected the request", errCodeUpstreamError, "upstream_error"
+	}
+	httpStatus := httpStatusFromGRPC(code)
+	log.Printf("service=B endpoint=%s status=error code=%s reason=%s error=%q timeout_ms=%d latency_ms=%d",
+		endpoint, code, reason, err.Error(), timeout.Milliseconds(), time.Since(start).Milliseconds())
+
+	body := errorBody(httpStatus, errCode, message+": "+status.Convert(err).Message(), err)
+	body["service_a"] = serviceAState
+	body["reason"] = reason
+	if code == codes.Unavailable {
+		retryAfter := retryAfterSeconds(err)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		body["retry_after"] = retryAfter
+	}
+	writeJSON(w, r, httpStatus, body)
+}
+
+// timeoutFor returns the upstream timeout for r: the ?timeout= query
+// parameter when present (clamped to b.maxTimeout), b.upstreamTimeout
+// otherwise.
+func (b *serviceB) timeoutFor(r *http.Request) (time.Duration, error) {
+	v := r.URL.Query().Get("timeout")
+	if v == "" {
+		return b.upstreamTimeout, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q: want a positive duration such as 500ms", v)
+	}
+	if d > b.maxTimeout {
+		d = b.maxTimeout
+	}
+	return d, nil
+}
+
+// echoRPC is the shape shared by the EchoService methods that take an
+// EchoRequest and return an EchoResponse.
+type echoRPC func(context.Context, *echo.EchoRequest, ...grpc.CallOption) (*echo.EchoResponse, error)
+
+// upstreamEcho is the shape of resilientEchoClient's Echo and ReverseEcho.
+type upstreamEcho func(context.Context, string, ...echoOption) (string, error)
+
+// proxyEcho serves an endpoint that forwards a msg (query or JSON body) to
+// one of A's echo-style RPCs. With a non-nil cache, successful responses are
+// cached and served from it, marked by an X-Cache: HIT or MISS header.
+func (b *serviceB) proxyEcho(w http.ResponseWriter, r *http.Request, endpoint string, rpc upstreamEcho, c *cache[string]) {
+	start := time.Now()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, "GET, POST")
+		return
+	}
+
+	req, err := echoRequestFrom(r)
+	if err != nil {
+		writeBadRequest(w, r, endpoint, start, err)
+		return
+	}
+
+	// Timeout handling in service B
+	timeout, err := b.timeoutFor(r)
+	if err != nil {
+		writeBadRequest(w, r, endpoint, start, err)
+		return
+	}
+
+	key := echoCacheKey(req)
+	if c != nil {
+		if cached, ok := c.Get(key); ok {
+			w.Header().Set("X-Cache", "HIT")
+			log.Printf("service=B endpoint=%s status=ok cache=hit latency_ms=%d", endpoint, time.Since(start).Milliseconds())
+			writeJSON(w, r, http.StatusOK, map[string]any{
+				"service_b": "ok",
+				"service_a": map[string]any{"echo": cached},
+			})
+			return
+		}
+		w.Header().Set("X-Cache", "MISS")
+	}
+
+	call := func(ctxUp context.Context) (echoResult, error) {
+		var (
+			res echoResult
+			err error
+		)
+		res.echo, err = rpc(ctxUp, req.Msg, withTransform(req.Transform), withCallOptions(grpc.Trailer(&res.trailer)))
+		return res, err
+	}
+	var (
+		res       echoResult
+		coalesced bool
+	)
+	if b.coalesce {
+		// Concurrent identical requests share one upstream call; the ones
+		// that joined another's call are marked with X-Coalesced.
+		res, coalesced, err = sharedEcho(r.Context(), dedupKey(r.Context(), endpoint, req, timeout), timeout, call)
+		if coalesced {
+			w.Header().Set("X-Coalesced", "true")
+			coalescedRequests.WithLabelValues(endpoint).Inc()
+		}
+	} else {
+		ctxUp, cancel := context.WithTimeout(r.Context(), timeout)
+		res, err = call(ctxUp)
+		cancel()
+	}
+	if err != nil {
+		writeUpstreamError(w, r, endpoint, start, timeout, err)
+		return
+	}
+	c.Set(key, res.echo)
+
+	log.Printf("service=B endpoint=%s status=ok coalesced=%t timeout_ms=%d latency_ms=%d",
+		endpoint, coalesced, timeout.Milliseconds(), time.Since(start).Milliseconds())
+	body := map[string]any{
+		"service_b": "ok",
+		"service_a": map[string]any{"echo": res.echo},
+	}
+	// A reports its own handling time in a trailer (see service A's
+	// timing.go); omit it if A didn't send one.
+	if v := res.trailer.Get("server-latency-ms"); len(v) > 0 {
+		if ms, err := strconv.ParseInt(v[0], 10, 64); err == nil {
+			body["upstream_latency_ms"] = ms
+		}
+	}
+	writeJSON(w, r, http.StatusOK, body)
+}
+
+// callEcho serves /call-echo. A ?delay= parameter makes B sleep first (see
+// debugDelay).
+func (b *serviceB) callEcho(w http.ResponseWriter, r *http.Request) {
+	if !b.debugDelay(w, r, "/call-echo") {
+		return
+	}
+	b.proxyEcho(w, r, "/call-echo", b.upstream.Echo, b.echoCache)
+}
+
+func (b *serviceB) callReverse(w http.ResponseWriter, r *http.Request) {
+	b.proxyEcho(w, r, "/call-reverse", b.upstream.ReverseEcho, nil)
+}
+
+// batchRequestFrom decodes the JSON array of messages POSTed to /call-batch.
+func batchRequestFrom(r *http.Request) (*echo.BatchEchoRequest, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return nil, errors.New("content type must be application/json")
+	}
+
+	var msgs []string
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&msgs); err != nil {
+		return nil, fmt.Errorf("body must be a JSON array of strings: %w", err)
+	}
+	return &echo.BatchEchoRequest{Msgs: msgs}, nil
+}
+
+func (b *serviceB) callBatch(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, "POST")
+		return
+	}
+
+	req, err := batchRequestFrom(r)
+	if err != nil {
+		writeBadRequest(w, r, "/call-batch", start, err)
+		return
+	}
+
+	ctxUp, cancel := context.WithTimeout(r.Context(), b.upstreamTimeout)
+	defer cancel()
+
+	var resp *echo.BatchEchoResponse
+	err = b.upstream.call(ctxUp, func(ctx context.Context) error {
+		var err error
+		resp, err = b.echoClient.BatchEcho(ctx, req)
+		return err
+	})
+	if err != nil {
+		writeUpstreamError(w, r, "/call-batch", start, b.upstreamTimeout, err)
+		return
+	}
+
+	// An empty batch decodes as nil; report it as [] rather than null.
+	echoes := resp.Echoes
+	if echoes == nil {
+		echoes = []string{}
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"service_b": "ok",
+		"service_a": map[string]any{"echoes": echoes},
+	})
+}
+
+// chunkRequestFrom reads /call-echo-chunk's msg, offset and limit query
+// parameters; offset and limit default to 0.
+func chunkRequestFrom(r *http.Request) (*echo.EchoChunkRequest, error) {
+	q := r.URL.Query()
+	req := &echo.EchoChunkRequest{Msg: q.Get("msg")}
+	for _, p := range []struct {
+		name string
+		dst  *int32
+	}{{"offset", &req.Offset}, {"limit", &req.Limit}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: must be an integer", p.name, v)
+		}
+		*p.dst = int32(n)
+	}
+	return req, nil
+}
+
+func (b *serviceB) callEchoChunk(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	req, err := chunkRequestFrom(r)
+	if err != nil {
+		writeBadRequest(w, r, "/call-echo-chunk", start, err)
+		return
+	}
+
+	ctxUp, cancel := context.WithTimeout(r.Context(), b.upstreamTimeout)
+	defer cancel()
+
+	var resp *echo.EchoChunkResponse
+	err = b.upstream.call(ctxUp, func(ctx context.Context) error {
+		var err error
+		resp, err = b.echoClient.EchoChunk(ctx, req)
+		return err
+	})
+	if err != nil {
+		writeUpstreamError(w, r, "/call-echo-chunk", start, b.upstreamTimeout, err)
+		return
+	}
+
+	log.Printf("service=B endpoint=/call-echo-chunk status=ok latency_ms=%d", time.Since(start).Milliseconds())
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"service_b": "ok",
+		"service_a": map[string]any{"chunk": resp.Chunk, "total": resp.Total},
+	})
+}
+
+// callHash returns A's digest of msg; ?algorithm= picks sha256 (the
+// default) or sha512.
+func (b *serviceB) callHash(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	q := r.URL.Query()
+	req := &echo.HashRequest{Msg: q.Get("msg"), Algorithm: q.Get("algorithm")}
+
+	ctxUp, cancel := context.WithTimeout(r.Context(), b.upstreamTimeout)
+	defer cancel()
+
+	var resp *echo.HashResponse
+	err := b.upstream.call(ctxUp, func(ctx context.Context) error {
+		var err error
+		resp, err = b.echoClient.Hash(ctx, req)
+		return err
+	})
+	if err != nil {
+		writeUpstreamError(w, r, "/call-hash", start, b.upstreamTimeout, err)
+		return
+	}
+
+	log.Printf("service=B endpoint=/call-hash status=ok latency_ms=%d", time.Since(start).Milliseconds())
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"service_b": "ok",
+		"service_a": map[string]any{"digest": resp.Digest, "algorithm": resp.Algorithm},
+	})
+}
+
+// callStats relays A's Echo counters.
+func (b *serviceB) callStats(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctxUp, cancel := context.WithTimeout(r.Context(), b.upstreamTimeout)
+	defer cancel()
+
+	var resp *echo.StatsResponse
+	err := b.upstream.call(ctxUp, func(ctx context.Context) error {
+		var err error
+		resp, err = b.echoClient.Stats(ctx, &echo.StatsRequest{})
+		return err
+	})
+	if err != nil {
+		writeUpstreamError(w, r, "/call-stats", start, b.upstreamTimeout, err)
+		return
+	}
+
+	log.Printf("service=B endpoint=/call-stats status=ok latency_ms=%d", time.Since(start).Milliseconds())
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"service_b": "ok",
+		"service_a": map[string]any{"echo_calls": resp.EchoCalls, "echo_bytes": resp.EchoBytes},
+	})
+}
+
+func (b *serviceB) callHealth(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctxUp, cancel := context.WithTimeout(r.Context(), b.upstreamTimeout)
+	defer cancel()
+
+	resp, err := b.echoClient.Health(ctxUp, &echo.HealthRequest{})
+	if err != nil {
+		writeUpstreamError(w, r, "/call-health", start, b.upstreamTimeout, err)
+		return
+	}
+
+	log.Printf("service=B endpoint=/call-health status=ok latency_ms=%d", time.Since(start).Milliseconds())
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"service_b": "ok",
+		"service_a": map[string]any{"status": resp.Status},
+	})
+}
+
+// versionInfo reports B's own build (set by -ldflags) and, if A answers,
+// A's build as well. B's version is always known, so an unreachable A is
+// reported in the body rather than as an error status.
+func (b *serviceB) versionInfo(w http.ResponseWriter, r *http.Request) {
+	body := map[string]any{
+		"service_b": map[string]any{
+			"version":    version.Version,
+			"commit":     version.Commit,
+			"build_date": version.BuildDate,
+		},
+	}
+
+	ctxUp, cancel := context.WithTimeout(r.Context(), b.upstreamTimeout)
+	defer cancel()
+	if resp, err := b.echoClient.GetVersion(ctxUp, &echo.VersionRequest{}); err != nil {
+		body["service_a"] = map[string]any{"error": err.Error(), "code": status.Code(err).String()}
+	} else {
+		body["service_a"] = resp
+	}
+	writeJSON(w, r, http.StatusOK, body)
+}
+
+func main() {
+	var (
+		httpListen        string
+		serviceAAddr      string
+		upstreamTimeout   time.Duration
+		codec             string
+		shutdownTimeout   time.Duration
+		maxRetries        int
+		breakerThreshold  int
+		breakerCooldown   time.Duration
+		tlsCA             string
+		tlsCert           string
+		tlsKey            string
+		apiKey            string
+		keepaliveInterval time.Duration
+		connectTimeout    time.Duration
+		backoffBase       time.Duration
+		backoffMax        time.Duration
+		backoffMultiplier float64
+		readyInterval     time.Duration
+		readyTTL          time.Duration
+		readyPolicy       string
+		maxTimeout        time.Duration
+		maxDelay          time.Duration
+		logFormat         string
+		logLevel          string
+		logSampleRate     float64
+		otlpEndpoint      string
+		compress          bool
+		maxRecvMsgSize    int
+		maxSendMsgSize    int
+		rateLimit         float64
+		rateBurst         int
+		trustedProxyList  string
+		configPath        string
+		cacheSize         int
+		cacheTTL          time.Duration
+		adminToken        string
+		corsOrigins       string
+		dialWait          time.Duration
+		healthWatch       bool
+		forwardHeaders    string
+		idempotencySize   int
+		idempotencyTTL    time.Duration
+		drainGrace        time.Duration
+		outgoingMetadata  string
+		maxHTTPBody       int64
+		jobLimit          int
+		jobTTL            time.Duration
+		jobConcurrency    int
+		debugRingSize     int
+		serveH2C          bool
+		selfTest          bool
+		coalesce          bool
+		readTimeout       time.Duration
+		writeTimeout      time.Duration
+		idleTimeout       time.Duration
+	)
+
+	// String and duration flags take their defaults from SERVICE_B_<FLAG>
+	// environment variables; see config/env.go for the precedence rules.
+	flag.StringVar(&httpListen, "listen", config.EnvOr("SERVICE_B_LISTEN", ":8081"), "HTTP listen address for service B")
+	flag.StringVar(&serviceAAddr, "service-a", config.EnvOr("SERVICE_B_SERVICE_A", "127.0.0.1:50051"), "service A gRPC address, resolver target (e.g. unix:///path/to.sock), or comma-separated list of addresses to balance across")
+	flag.DurationVar(&upstreamTimeout, "timeout", config.EnvDurationOr("SERVICE_B_TIMEOUT", 1*time.Second), "timeout for calls from B -> A")
+	flag.DurationVar(&maxTimeout, "max-timeout", config.EnvDurationOr("SERVICE_B_MAX_TIMEOUT", 5*time.Second), "cap on the per-request ?timeout= override")
+	flag.DurationVar(&maxDelay, "max-delay", config.EnvDurationOr("SERVICE_B_MAX_DELAY", 0), "cap on the /call-echo ?delay= B sleeps before calling service A, for testing client timeouts (0 disables ?delay=)")
+	flag.StringVar(&codec, "codec", config.EnvOr("SERVICE_B_CODEC", echo.JSONCodecName), "codec used for calls from B -> A (json or proto)")
+	flag.IntVar(&maxRetries, "max-retries", 2, "retries for transient B -> A failures (Unavailable, DeadlineExceeded), shared between grpc's retryPolicy and B's own retry loop")
+	flag.IntVar(&breakerThreshold, "breaker-threshold", 5, "consecutive B -> A failures that open the circuit (0 disables)")
+	flag.DurationVar(&breakerCooldown, "breaker-cooldown", config.EnvDurationOr("SERVICE_B_BREAKER_COOLDOWN", 10*time.Second), "how long the circuit stays open before probing service A")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", config.EnvDurationOr("SERVICE_B_SHUTDOWN_TIMEOUT", 5*time.Second), "how long to drain in-flight HTTP requests on shutdown")
+	flag.StringVar(&tlsCA, "tls-ca", config.EnvOr("SERVICE_B_TLS_CA", ""), "CA bundle for verifying service A's TLS certificate (enables TLS to A)")
+	flag.StringVar(&tlsCert, "tls-cert", config.EnvOr("SERVICE_B_TLS_CERT", ""), "TLS certificate file for serving HTTPS and, with -tls-ca, as B's client certificate")
+	flag.StringVar(&tlsKey, "tls-key", config.EnvOr("SERVICE_B_TLS_KEY", ""), "TLS private key file for -tls-cert")
+	flag.StringVar(&apiKey, "api-key", config.EnvOr("SERVICE_B_API_KEY", ""), "API key sent to service A (empty sends none)")
+	flag.DurationVar(&keepaliveInterval, "keepalive", config.EnvDurationOr("SERVICE_B_KEEPALIVE", 30*time.Second), "interval between keepalive pings to service A (0 disables)")
+	flag.DurationVar(&connectTimeout, "connect-timeout", config.EnvDurationOr("SERVICE_B_CONNECT_TIMEOUT", 20*time.Second), "minimum time allowed for each connection attempt to service A")
+	flag.DurationVar(&backoffBase, "backoff-base", config.EnvDurationOr("SERVICE_B_BACKOFF_BASE", time.Second), "delay before retrying a failed connection to service A")
+	flag.DurationVar(&backoffMax, "backoff-max", config.EnvDurationOr("SERVICE_B_BACKOFF_MAX", 120*time.Second), "upper bound on the reconnect delay to service A")
+	flag.Float64Var(&backoffMultiplier, "backoff-multiplier", 1.6, "factor the reconnect delay grows by after each failed attempt")
+	flag.DurationVar(&readyInterval, "ready-interval", config.EnvDurationOr("SERVICE_B_READY_INTERVAL", 5*time.Second), "how often to health-check service A for /readyz")
+	flag.DurationVar(&readyTTL, "ready-ttl", config.EnvDurationOr("SERVICE_B_READY_TTL", 15*time.Second), "how long a successful health check keeps /readyz ready")
+	flag.StringVar(&readyPolicy, "ready-policy", config.EnvOr("SERVICE_B_READY_POLICY", readyPolicyAny), "with several -service-a addresses, whether /readyz needs any or all of them healthy")
+	flag.StringVar(&logFormat, "log-format", config.EnvOr("SERVICE_B_LOG_FORMAT", logging.FormatText), "request log format: text or json")
+	flag.StringVar(&logLevel, "log-level", config.EnvOr("SERVICE_B_LOG_LEVEL", "debug"), "minimum request log level: debug (successes), info, warn (client errors) or error")
+	flag.Float64Var(&logSampleRate, "log-sample-rate", 1, "fraction of successful request logs to keep (errors are always logged)")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", config.EnvOr("SERVICE_B_OTLP_ENDPOINT", ""), "OTLP/gRPC collector address for trace export, e.g. localhost:4317 (empty disables tracing)")
+	flag.BoolVar(&compress, "compress", false, "gzip-compress messages on calls from B -> A")
+	flag.IntVar(&maxRecvMsgSize, "max-recv-msg-size", 4<<20, "largest encoded gRPC message B accepts from A, in bytes")
+	flag.IntVar(&maxSendMsgSize, "max-send-msg-size", 4<<20, "largest encoded gRPC message B sends to A, in bytes")
+	flag.Float64Var(&rateLimit, "rate", 0, "per-client request rate (requests/second) allowed on /call-* endpoints (0 disables)")
+	flag.IntVar(&rateBurst, "burst", 10, "per-client burst size for -rate")
+	flag.StringVar(&trustedProxyList, "trusted-proxies", config.EnvOr("SERVICE_B_TRUSTED_PROXIES", ""), "comma-separated IPs or CIDRs of proxies whose X-Forwarded-For identifies the client for -rate (empty trusts none and uses the peer address)")
+	flag.IntVar(&cacheSize, "cache-size", 0, "maximum /call-echo responses cached (0 disables caching)")
+	flag.DurationVar(&cacheTTL, "cache-ttl", config.EnvDurationOr("SERVICE_B_CACHE_TTL", 30*time.Second), "how long a cached /call-echo response is served")
+	flag.IntVar(&idempotencySize, "idempotency-size", 1000, "maximum Idempotency-Key responses kept for /call-echo replays (0 disables)")
+	flag.DurationVar(&idempotencyTTL, "idempotency-ttl", config.EnvDurationOr("SERVICE_B_IDEMPOTENCY_TTL", 10*time.Minute), "how long a response is replayed for a repeated Idempotency-Key")
+	flag.StringVar(&adminToken, "admin-token", config.EnvOr("SERVICE_B_ADMIN_TOKEN", ""), "token required by POST /admin/shutdown and /admin/drain (empty disables them)")
+	flag.DurationVar(&drainGrace, "drain-grace", config.EnvDurationOr("SERVICE_B_DRAIN_GRACE", 30*time.Second), "after POST /admin/drain, how long B keeps serving with /readyz failing before it shuts down")
+	flag.StringVar(&corsOrigins, "cors-origins", config.EnvOr("SERVICE_B_CORS_ORIGINS", ""), "comma-separated origins allowed to call B from a browser, or * (empty disables CORS)")
+	flag.DurationVar(&dialWait, "dial-wait", config.EnvDurationOr("SERVICE_B_DIAL_WAIT", 0), "at startup, wait up to this long for the connection to service A to be ready (0 starts immediately)")
+	flag.BoolVar(&healthWatch, "health-watch", false, "follow service A's health with a grpc.health.v1 Watch stream instead of polling every -ready-interval")
+	flag.StringVar(&forwardHeaders, "forward-headers", config.EnvOr("SERVICE_B_FORWARD_HEADERS", ""), "comma-separated HTTP request headers copied into gRPC metadata for service A, e.g. Tenant-Id")
+	flag.StringVar(&outgoingMetadata, "outgoing-metadata", config.EnvOr("SERVICE_B_OUTGOING_METADATA", "caller=service-b"), "comma-separated key=value metadata sent on every call to service A, alongside the request id")
+	flag.Int64Var(&maxHTTPBody, "max-http-body", 64<<10, "largest HTTP request body B reads, in bytes; larger bodies get 413 (0 disables the limit)")
+	flag.IntVar(&jobLimit, "job-limit", 1000, "maximum /call-echo-async jobs kept for polling; the oldest are dropped first (0 disables async jobs)")
+	flag.IntVar(&jobConcurrency, "job-concurrency", 100, "maximum /call-echo-async jobs calling service A at once; further requests get 503 until one finishes (0 removes the cap)")
+	flag.DurationVar(&jobTTL, "job-ttl", config.EnvDurationOr("SERVICE_B_JOB_TTL", 10*time.Minute), "how long an async job can be polled at /jobs/{id}")
+	flag.IntVar(&debugRingSize, "debug-ring-size", 100, "number of recent requests listed at GET /debug/requests (0 disables it)")
+	flag.DurationVar(&readTimeout, "read-timeout", config.EnvDurationOr("SERVICE_B_READ_TIMEOUT", 10*time.Second), "how long B waits for a whole HTTP request, body included (0 disables)")
+	flag.DurationVar(&writeTimeout, "write-timeout", config.EnvDurationOr("SERVICE_B_WRITE_TIMEOUT", 10*time.Second), "how long B allows for writing an HTTP response, from the end of the request headers; /call-repeat streams are exempt (0 disables)")
+	flag.DurationVar(&idleTimeout, "idle-timeout", config.EnvDurationOr("SERVICE_B_IDLE_TIMEOUT", 60*time.Second), "how long an idle keep-alive connection is kept open (0 uses -read-timeout)")
+	flag.BoolVar(&coalesce, "coalesce", true, "let concurrent identical /call-echo and /call-reverse requests share one call to service A")
+	flag.BoolVar(&selfTest, "selftest", false, "make one Echo call to service A, print the result and exit (0 on success) without serving HTTP")
+	flag.BoolVar(&serveH2C, "h2c", false, "also accept HTTP/2 without TLS (h2c); HTTP/1.1 clients keep working")
+	flag.StringVar(&configPath, "config", config.EnvOr("SERVICE_B_CONFIG", ""), "YAML or JSON file of listen, timeout, TLS, logging and codec settings keyed by flag name; flags given on the command line override it")
+	flag.Parse()
+
+	if configPath != "" {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			log.Fatalf("service=B %v", err)
+		}
+		fs := flag.CommandLine
+		config.Use(fs, "listen", &httpListen, cfg.Listen)
+		config.Use(fs, "service-a", &serviceAAddr, cfg.ServiceA)
+		config.Use(fs, "timeout", &upstreamTimeout, cfg.Timeout)
+		config.Use(fs, "shutdown-timeout", &shutdownTimeout, cfg.ShutdownTimeout)
+		config.Use(fs, "tls-cert", &tlsCert, cfg.TLSCert)
+		config.Use(fs, "tls-key", &tlsKey, cfg.TLSKey)
+		config.Use(fs, "tls-ca", &tlsCA, cfg.TLSCA)
+		config.Use(fs, "log-format", &logFormat, cfg.LogFormat)
+		config.Use(fs, "log-level", &logLevel, cfg.LogLevel)
+		config.Use(fs, "otlp-endpoint", &otlpEndpoint, cfg.OTLPEndpoint)
+		config.Use(fs, "codec", &codec, cfg.Codec)
+	}
+
+	level, err := logging.ParseLevel(logLevel)
+	if err != nil {
+		log.Fatalf("service=B invalid -log-level: %v", err)
+	}
+	logger, err := logging.New(os.Stderr, logging.Config{Format: logFormat, Level: level, SampleRate: logSampleRate})
+	if err != nil {
+		log.Fatalf("service=B invalid logging flags: %v", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "service-b", otlpEndpoint)
+	if err != nil {
+		log.Fatalf("service=B failed to set up tracing: %v", err)
+	}
+
+	echo.MustRegisterJSONCodec()
+	echo.RegisterProtoCodec()
+	if err := echo.ValidateCodec(codec); err != nil {
+		log.Fatalf("service=B invalid -codec: %v", err)
+	}
+
+	if err := validateServingTLS(tlsCert, tlsKey); err != nil {
+		log.Fatalf("service=B invalid TLS flags: %v", err)
+	}
+	// One reloader holds -tls-cert for both HTTPS and mutual TLS to A, so
+	// a SIGHUP rotates both.
+	var certs *tlsreload.Reloader
+	if tlsCert != "" {
+		if certs, err = tlsreload.New(tlsCert, tlsKey); err != nil {
+			log.Fatalf("service=B failed to load TLS certificate: %v", err)
+		}
+	}
+	creds, err := clientCredentials(tlsCA, certs)
+	if err != nil {
+		log.Fatalf("service=B failed to load TLS credentials: %v", err)
+	}
+	if serveH2C && tlsCert != "" {
+		log.Fatalf("service=B -h2c can't be combined with -tls-cert; HTTPS already negotiates HTTP/2")
+	}
+
+	// Create the client for service A. grpc.NewClient never blocks or
+	// connects: the channel connects lazily on the first call and in the
+	// background after that, so B starts (and serves /health) even while A
+	// is down. Calls made before A is reachable fail with Unavailable, which
+	// /call-echo reports as a 503, and the channel keeps reconnecting with
+	// backoff so B recovers on its own once A comes back.
+	callOpts := []grpc.CallOption{
+		grpc.CallContentSubtype(codec),
+		grpc.MaxCallRecvMsgSize(maxRecvMsgSize),
+		grpc.MaxCallSendMsgSize(maxSendMsgSize),
+	}
+	if compress {
+		// Compression is independent of the codec: the message is encoded
+		// with the json/proto codec first, then gzipped on the wire.
+		callOpts = append(callOpts, grpc.UseCompressor(gzip.Name))
+	}
+	outgoingMD, err := parseOutgoingMetadata(outgoingMetadata)
+	if err != nil {
+		log.Fatalf("service=B invalid -outgoing-metadata: %v", err)
+	}
+	grpcRetries, callRetries := splitRetries(maxRetries)
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(callOpts...),
+		grpc.WithDefaultServiceConfig(serviceConfig(grpcRetries)),
+		keepaliveDialOption(keepaliveInterval),
+		// Identifies B in A's logs; grpc appends its own "grpc-go/<version>".
+		grpc.WithUserAgent("service-b/" + version.Version),
+		connectParamsDialOption(connectTimeout, backoffBase, backoffMax, backoffMultiplier),
+		// Records a client span per call to A and injects the trace context
+		// into its metadata.
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		// The metadata interceptor runs first so the logged call carries the
+		// request id it attaches.
+		grpc.WithChainUnaryInterceptor(outgoingMetadataUnaryInterceptor(outgoingMD), clientLoggingInterceptor(logger)),
+		grpc.WithStreamInterceptor(outgoingMetadataStreamInterceptor(outgoingMD)),
+	}
+	if apiKey != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(apiKeyCredentials{key: apiKey}))
+	}
+	target, resolverOpts := upstreamTarget(serviceAAddr)
+	dialOpts = append(dialOpts, resolverOpts...)
+	conn, err := grpc.NewClient(target, dialOpts...)
+	if err != nil {
+		log.Fatalf("service=B failed to create client for service A: %v", err)
+	}
+
+	if dialWait > 0 {
+		start := time.Now()
+		ready := waitForReady(conn, dialWait)
+		log.Printf("service=B upstream=A reachable_at_boot=%t state=%s waited_ms=%d",
+			ready, conn.GetState(), time.Since(start).Milliseconds())
+	}
+
+	echoClient := echo.NewEchoServiceClient(conn)
+
+	// The balanced channel can't say which replica answered a health check,
+	// so with several addresses each gets its own connection for /readyz.
+	addrs := upstreamAddrs(serviceAAddr)
+	healthConns := []*grpc.ClientConn{conn}
+	if len(addrs) > 1 {
+		healthConns = healthConns[:0]
+		for _, addr := range addrs {
+			hc, err := grpc.NewClient(addr, dialOpts...)
+			if err != nil {
+				log.Fatalf("service=B failed to create health client for %s: %v", addr, err)
+			}
+			healthConns = append(healthConns, hc)
+		}
+	}
+
+	mux := http.NewServeMux()
+
+	mux.Handle("/metrics", promhttp.Handler())
+
+	if healthWatch {
+		// Watch results are pushed only on change, so they never go stale.
+		readyTTL = 0
+	}
+	ready, err := newUpstreamReadiness(addrs, readyTTL, readyPolicy)
+	if err != nil {
+		log.Fatalf("service=B invalid -ready-policy: %v", err)
+	}
+	upstream := newResilientEchoClient(echoClient, upstreamTimeout, callRetries, newBreaker(breakerThreshold, breakerCooldown))
+	if selfTest {
+		err := runSelfTest(context.Background(), upstream, os.Stdout)
+		conn.Close()
+		if err != nil {
+			log.Printf("service=B selftest=failed error=%q", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	b := &serviceB{
+		echoClient:      echoClient,
+		upstreamTimeout: upstreamTimeout,
+		maxTimeout:      maxTimeout,
+		maxDelay:        maxDelay,
+		upstream:        upstream,
+		coalesce:        coalesce,
+		ready:           ready,
+		echoCache:       newCache[string](cacheSize, cacheTTL),
+		jobs:            newCache[*job](jobLimit, jobTTL),
+		jobRunner:       newJobRunner(jobConcurrency),
+	}
+
+	mux.HandleFunc("/health", b.health)
+	mux.HandleFunc("/livez", b.livez)
+	mux.HandleFunc("/readyz", b.readyz)
+	mux.HandleFunc("/version", b.versionInfo)
+	mux.Handle("/debug/conn", connDebug{conn: conn, target: serviceAAddr})
+	ring := newRequestRing(debugRingSize)
+	if ring != nil {
+		mux.Handle("/debug/requests", ring)
+	}
+	admin := newAdminShutdown(adminToken)
+	if adminToken != "" {
+		mux.Handle("/admin/shutdown", admin)
+		mux.Handle("/admin/drain", admin.drainHandler(b.ready, drainGrace))
+	}
+
+	// Every /call-* endpoint reaches service A, so they share the
+	// per-client rate limit.
+	proxies, err := parseTrustedProxies(trustedProxyList)
+	if err != nil {
+		log.Fatalf("service=B invalid -trusted-proxies: %v", err)
+	}
+	limiter := newRateLimiter(rateLimit, rateBurst, proxies)
+	idem := newIdempotency(idempotencySize, idempotencyTTL)
+	mux.HandleFunc("/call-echo", limiter.middleware(idem.middleware("/call-echo", b.callEcho)))
+	mux.HandleFunc("/call-health", limiter.middleware(b.callHealth))
+	mux.HandleFunc("/call-reverse", limiter.middleware(b.callReverse))
+	mux.HandleFunc("/call-batch", limiter.middleware(b.callBatch))
+	mux.HandleFunc("/call-echo-chunk", limiter.middleware(b.callEchoChunk))
+	mux.HandleFunc("/call-hash", limiter.middleware(b.callHash))
+	mux.HandleFunc("/call-ping", limiter.middleware(b.callPing))
+	mux.HandleFunc("/call-stats", limiter.middleware(b.callStats))
+	mux.HandleFunc("/call-repeat", limiter.middleware(b.callRepeat))
+	if b.jobs != nil {
+		mux.HandleFunc("/call-echo-async", limiter.middleware(b.callEchoAsync))
+		mux.HandleFunc("/jobs/{id}", b.getJob)
+	}
+
+	// Middleware, innermost first. Logging sees every response, including
+	// CORS preflights and 413s; otelhttp is outermost so the server span
+	// (named after the route) covers the whole request and its context
+	// reaches the gRPC call.
+	route := routeOf(mux)
+	var handler http.Handler = maxBytesMiddleware(maxHTTPBody, mux)
+	handler = forwardHeadersMiddleware(parseForwardHeaders(forwardHeaders), handler)
+	handler = corsMiddleware(parseCORSOrigins(corsOrigins), handler)
+	handler = ring.middleware(handler)
+	handler = httpLoggingMiddleware(logger, "B", route, handler)
+	handler = otelhttp.NewHandler(handler, "B",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return route(r) }))
+	if serveH2C {
+		// h2c hands HTTP/2 requests (prior knowledge or an Upgrade: h2c)
+		// to the same handler chain and passes HTTP/1.1 through untouched.
+		// Upgraded connections are hijacked, so srv.Shutdown doesn't wait
+		// for them.
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	// ReadTimeout and WriteTimeout bound slow-body and slow-read clients;
+	// the header timeout stays shorter so stalled connections are dropped
+	// early.
+	srv := &http.Server{
+		Addr:              httpListen,
+		Handler:           handler,
+		ReadHeaderTimeout: 2 * time.Second,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// kill -HUP rotates the certificate without dropping connections.
+	if certs != nil {
+		srv.TLSConfig = servingTLS(certs)
+		go certs.ReloadOnSIGHUP(ctx, "B")
+	}
+
+	// Track A's health in the background for /readyz; this also reports
+	// whether A was reachable at boot without delaying B's startup.
+	for i, hc := range healthConns {
+		healthClient, r := healthpb.NewHealthClient(hc), b.ready.upstreams[i]
+		if healthWatch {
+			go watchServiceA(ctx, healthClient, r)
+			continue
+		}
+		go r.run(ctx, readyInterval, upstreamTimeout, func(ctx context.Context) error {
+			st, err := checkServiceA(ctx, healthClient)
+			if err == nil && st != healthpb.HealthCheckResponse_SERVING {
+				err = fmt.Errorf("service A health status is %s", st)
+			}
+			return err
+		})
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		scheme := "HTTP"
+		if tlsCert != "" {
+			scheme = "HTTPS"
+		}
+		if serveH2C {
+			scheme = "HTTP, h2c"
+		}
+		log.Printf("service=B listening on %s (%s). Calling service A over gRPC at %s (codec=%s, compress=%t, security=%s)",
+			httpListen, scheme, serviceAAddr, codec, compress, creds.Info().SecurityProtocol)
+		if certs != nil {
+			// The certificate comes from srv.TLSConfig, not files.
+			serveErr <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		conn.Close()
+		log.Fatalf("service=B serve failed: %v", err)
+	case <-ctx.Done():
+	case <-admin.done:
+	}
+
+	log.Printf("service=B shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("service=B shutdown did not complete: %v", err)
+	}
+	// Async jobs outlive their requests; let them finish their calls to A
+	// before the connection closes.
+	if err := b.jobRunner.shutdown(shutdownCtx); err != nil {
+		log.Printf("service=B async jobs still running at shutdown: %v", err)
+	}
+	if err := conn.Close(); err != nil {
+		log.Printf("service=B failed to close connection to service A: %v", err)
+	}
+	if len(addrs) > 1 {
+		for _, hc := range healthConns {
+			hc.Close()
+		}
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("service=B failed to flush traces: %v", err)
+	}
+	log.Printf("service=B stopped")
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/logging"
+	"grpc-echo-json/version"
+)
+
+// fakeEchoClient is an EchoServiceClient whose Echo and ReverseEcho answer
+// with echoFn; other methods panic unless a test overrides them. It counts
+// the calls it gets.
+type fakeEchoClient struct {
+	echo.EchoServiceClient
+	echoFn func(context.Context, *echo.EchoRequest) (*echo.EchoResponse, error)
+	calls  atomic.Int32
+}
+
+func (f *fakeEchoClient) Echo(ctx context.Context, in *echo.EchoRequest, _ ...grpc.CallOption) (*echo.EchoResponse, error) {
+	f.calls.Add(1)
+	return f.echoFn(ctx, in)
+}
+
+func (f *fakeEchoClient) ReverseEcho(ctx context.Context, in *echo.EchoRequest, _ ...grpc.CallOption) (*echo.EchoResponse, error) {
+	f.calls.Add(1)
+	return f.echoFn(ctx, in)
+}
+
+// echoOK answers every request with its own message.
+func echoOK(_ context.Context, in *echo.EchoRequest) (*echo.EchoResponse, error) {
+	return &echo.EchoResponse{Echo: in.Msg}, nil
+}
+
+// fakeServiceA is an EchoServiceServer whose Echo answers with the
+// request's message; other methods panic unless a test overrides them.
+type fakeServiceA struct {
+	echo.EchoServiceServer
+}
+
+func (fakeServiceA) Echo(_ context.Context, in *echo.EchoRequest) (*echo.EchoResponse, error) {
+	return &echo.EchoResponse{Echo: in.Msg}, nil
+}
+
+// newTestServiceB returns a serviceB calling client with a 1s timeout, no
+// retries, no breaker and no caches.
+func newTestServiceB(client echo.EchoServiceClient) *serviceB {
+	return &serviceB{
+		echoClient:      client,
+		upstream:        newResilientEchoClient(client, time.Second, 0, newBreaker(0, 0)),
+		upstreamTimeout: time.Second,
+		maxTimeout:      5 * time.Second,
+		maxDelay:        time.Second,
+	}
+}
+
+// testLogger discards request logs.
+func testLogger(t *testing.T) *logging.Logger {
+	t.Helper()
+	l, err := logging.New(io.Discard, logging.Config{SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// serve runs one request through h and returns the recorded response.
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+// decodeBody decodes a JSON response body into a map.
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
+// loggedRequest runs one request to handler through httpLoggingMiddleware
+// and returns the request log line.
+func loggedRequest(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	var out bytes.Buffer
+	logger, err := logging.New(&out, logging.Config{Level: slog.LevelDebug, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := httpLoggingMiddleware(logger, "B", func(r *http.Request) string { return r.URL.Path }, handler)
+	serve(h, http.MethodGet, "/call-echo")
+	return strings.TrimSpace(out.String())
+}
+
+func TestHTTPLoggingStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{"implicit 200", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("body"))
+		}, "status=ok http_status=200"},
+		{"explicit 404", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}, "status=error http_status=404"},
+		{"second WriteHeader ignored", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.WriteHeader(http.StatusInternalServerError)
+		}, "status=ok http_status=201"},
+		{"WriteHeader after body ignored", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("body"))
+			w.WriteHeader(http.StatusInternalServerError)
+		}, "status=ok http_status=200"},
+		{"1xx not recorded", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusNotFound)
+		}, "status=error http_status=404"},
+		{"399 is ok", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(399)
+		}, "status=ok http_status=399"},
+		{"400 is an error", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}, "status=error http_status=400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if line := loggedRequest(t, tt.handler); !strings.Contains(line, tt.want) {
+				t.Errorf("log line %q lacks %q", line, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallEchoGETAndPOST(t *testing.T) {
+	var got []*echo.EchoRequest
+	b := newTestServiceB(&fakeEchoClient{echoFn: func(ctx context.Context, in *echo.EchoRequest) (*echo.EchoResponse, error) {
+		got = append(got, in)
+		return echoOK(ctx, in)
+	}})
+	h := http.HandlerFunc(b.callEcho)
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantEcho    string
+	}{
+		{"GET", http.MethodGet, "/call-echo?msg=hi&transform=upper", "", "", http.StatusOK, "hi"},
+		{"POST", http.MethodPost, "/call-echo", "application/json", `{"msg":"hi","transform":"upper"}`, http.StatusOK, "hi"},
+		{"POST with charset", http.MethodPost, "/call-echo", "application/json; charset=utf-8", `{"msg":"hi"}`, http.StatusOK, "hi"},
+		{"malformed body", http.MethodPost, "/call-echo", "application/json", `{"msg":`, http.StatusBadRequest, ""},
+		{"wrong content type", http.MethodPost, "/call-echo", "text/plain", `{"msg":"hi"}`, http.StatusBadRequest, ""},
+		{"other method", http.MethodPut, "/call-echo", "application/json", `{"msg":"hi"}`, http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			body := decodeBody(t, rec)
+			if tt.wantEcho != "" {
+				if echoed := body["service_a"].(map[string]any)["echo"]; echoed != tt.wantEcho {
+					t.Errorf("echo = %v, want %s", echoed, tt.wantEcho)
+				}
+			} else if _, ok := body["error"]; !ok {
+				t.Errorf("body %v has no error", body)
+			}
+		})
+	}
+	if len(got) != 3 || got[0].Transform != "upper" || got[1].Transform != "upper" {
+		t.Errorf("A got %+v, want three requests, the first two with transform upper", got)
+	}
+}
+
+// healthEchoClient is a fakeEchoClient whose Health answers with healthFn.
+type healthEchoClient struct {
+	fakeEchoClient
+	healthFn func(context.Context) (*echo.HealthResponse, error)
+}
+
+func (h *healthEchoClient) Health(ctx context.Context, _ *echo.HealthRequest, _ ...grpc.CallOption) (*echo.HealthResponse, error) {
+	return h.healthFn(ctx)
+}
+
+func TestCallHealth(t *testing.T) {
+	healthy := newTestServiceB(&healthEchoClient{healthFn: func(context.Context) (*echo.HealthResponse, error) {
+		return &echo.HealthResponse{Status: "ok"}, nil
+	}})
+	rec := serve(http.HandlerFunc(healthy.callHealth), http.MethodGet, "/call-health")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("healthy: status = %d, body %s", rec.Code, rec.Body)
+	}
+	body := decodeBody(t, rec)
+	if body["service_b"] != "ok" || body["service_a"].(map[string]any)["status"] != "ok" {
+		t.Errorf("healthy: body = %v", body)
+	}
+
+	down := newTestServiceB(&healthEchoClient{healthFn: func(context.Context) (*echo.HealthResponse, error) {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}})
+	rec = serve(http.HandlerFunc(down.callHealth), http.MethodGet, "/call-health")
+	body = assertEnvelope(t, rec, http.StatusServiceUnavailable, errCodeUpstreamUnavailable, "Unavailable")
+	if body["service_a"] != "unavailable" {
+		t.Errorf("unavailable: service_a = %v", body["service_a"])
+	}
+}
+
+// batchEchoClient is a fakeEchoClient whose BatchEcho echoes every message.
+type batchEchoClient struct{ fakeEchoClient }
+
+func (c *batchEchoClient) BatchEcho(_ context.Context, in *echo.BatchEchoRequest, _ ...grpc.CallOption) (*echo.BatchEchoResponse, error) {
+	return &echo.BatchEchoResponse{Echoes: in.Msgs}, nil
+}
+
+func TestCallBatch(t *testing.T) {
+	h := http.HandlerFunc(newTestServiceB(&batchEchoClient{}).callBatch)
+	post := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/call-batch", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := post(`["one","two","three"]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if got := decodeBody(t, rec)["service_a"].(map[string]any)["echoes"]; fmt.Sprint(got) != "[one two three]" {
+		t.Errorf("echoes = %v, want [one two three]", got)
+	}
+
+	rec = post(`[]`)
+	if !strings.Contains(rec.Body.String(), `"echoes": []`) && !strings.Contains(rec.Body.String(), `"echoes":[]`) {
+		t.Errorf("empty batch body = %s, want echoes []", rec.Body)
+	}
+
+	if rec := post(`{"msgs":["a"]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("object body: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestCallEchoTimeoutOverride(t *testing.T) {
+	var remaining time.Duration
+	b := newTestServiceB(&fakeEchoClient{echoFn: func(ctx context.Context, in *echo.EchoRequest) (*echo.EchoResponse, error) {
+		dl, _ := ctx.Deadline()
+		remaining = time.Until(dl)
+		return echoOK(ctx, in)
+	}})
+	h := http.HandlerFunc(b.callEcho)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantMax    time.Duration
+	}{
+		{"default", "", http.StatusOK, b.upstreamTimeout},
+		{"valid override", "&timeout=200ms", http.StatusOK, 200 * time.Millisecond},
+		{"clamped", "&timeout=1h", http.StatusOK, b.maxTimeout},
+		{"invalid", "&timeout=soon", http.StatusBadRequest, 0},
+		{"negative", "&timeout=-1s", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remaining = 0
+			rec := serve(h, http.MethodGet, "/call-echo?msg=hi"+tt.query)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if remaining > tt.wantMax || remaining < tt.wantMax-100*time.Millisecond {
+				t.Errorf("A saw %s left, want about %s", remaining, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestHTTPLoggingJSON(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, logging.Config{Format: logging.FormatJSON, Level: slog.LevelDebug, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := httpLoggingMiddleware(logger, "B", func(r *http.Request) string { return r.URL.Path }, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	serve(h, http.MethodGet, "/nope")
+
+	var rec map[string]any
+	if err := json.Unmarshal(out.Bytes(), &rec); err != nil {
+		t.Fatalf("log record %q is not JSON: %v", out.String(), err)
+	}
+	for _, key := range []string{"service", "endpoint", "status", "http_status", "request_id", "latency_ms"} {
+		if _, ok := rec[key]; !ok {
+			t.Errorf("record %v has no %s", rec, key)
+		}
+	}
+	if rec["endpoint"] != "/nope" || rec["http_status"] != float64(http.StatusNotFound) {
+		t.Errorf("record = %v, want endpoint /nope and http_status 404", rec)
+	}
+}
+
+// versionEchoClient is a fakeEchoClient whose GetVersion answers with resp,
+// or fails with err.
+type versionEchoClient struct {
+	fakeEchoClient
+	resp *echo.VersionResponse
+	err  error
+}
+
+func (c *versionEchoClient) GetVersion(context.Context, *echo.VersionRequest, ...grpc.CallOption) (*echo.VersionResponse, error) {
+	return c.resp, c.err
+}
+
+func TestVersionInfo(t *testing.T) {
+	// Stand in for -ldflags -X.
+	defer func(v, c, d string) { version.Version, version.Commit, version.BuildDate = v, c, d }(version.Version, version.Commit, version.BuildDate)
+	version.Version, version.Commit, version.BuildDate = "v1.2.3", "abc1234", "2024-06-01T12:00:00Z"
+
+	b := newTestServiceB(&versionEchoClient{resp: &echo.VersionResponse{Version: "v1.2.2", Commit: "def5678", BuildDate: "2024-05-30T08:00:00Z"}})
+	rec := serve(http.HandlerFunc(b.versionInfo), http.MethodGet, "/version")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	body := decodeBody(t, rec)
+	wantB := map[string]any{"version": "v1.2.3", "commit": "abc1234", "build_date": "2024-06-01T12:00:00Z"}
+	wantA := map[string]any{"version": "v1.2.2", "commit": "def5678", "build_date": "2024-05-30T08:00:00Z"}
+	if fmt.Sprint(body["service_b"]) != fmt.Sprint(wantB) {
+		t.Errorf("service_b = %v, want %v", body["service_b"], wantB)
+	}
+	if fmt.Sprint(body["service_a"]) != fmt.Sprint(wantA) {
+		t.Errorf("service_a = %v, want %v", body["service_a"], wantA)
+	}
+
+	b = newTestServiceB(&versionEchoClient{err: status.Error(codes.Unavailable, "connection refused")})
+	rec = serve(http.HandlerFunc(b.versionInfo), http.MethodGet, "/version")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("A down: status = %d, want 200 with B's own version", rec.Code)
+	}
+	if a := decodeBody(t, rec)["service_a"].(map[string]any); a["code"] != "Unavailable" {
+		t.Errorf("A down: service_a = %v, want code Unavailable", a)
+	}
+}
+
+func TestWriteJSONMarshalFailure(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSON(rec, httptest.NewRequest(http.MethodGet, "/call-echo", nil), http.StatusOK, map[string]any{"bad": func() {}})
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if rec.Body.String() != internalErrorBody {
+		t.Errorf("body = %s, want the static error body", rec.Body)
+	}
+	if !json.Valid(rec.Body.Bytes()) {
+		t.Error("static error body is not valid JSON")
+	}
+}
+
+func TestPrettyToggle(t *testing.T) {
+	b := newTestServiceB(&fakeEchoClient{echoFn: echoOK})
+	tests := []struct {
+		target   string
+		handler  http.HandlerFunc
+		indented bool
+	}{
+		{"/call-echo?msg=hi", b.callEcho, true},
+		{"/call-echo?msg=hi&pretty=1", b.callEcho, true},
+		{"/call-echo?msg=hi&pretty=0", b.callEcho, false},
+		{"/call-echo?msg=hi&pretty=false", b.callEcho, false},
+		{"/call-echo?msg=hi&pretty=bogus", b.callEcho, true},
+		{"/health", b.health, true},
+		{"/health?pretty=0", b.health, false},
+	}
+	for _, tt := range tests {
+		rec := serve(tt.handler, http.MethodGet, tt.target)
+		if rec.Code != http.StatusOK || !json.Valid(rec.Body.Bytes()) {
+			t.Fatalf("%s: status = %d, body %s", tt.target, rec.Code, rec.Body)
+		}
+		if got := bytes.Contains(rec.Body.Bytes(), []byte("\n  ")); got != tt.indented {
+			t.Errorf("%s: indented = %t, want %t; body %s", tt.target, got, tt.indented, rec.Body)
+		}
+	}
+}
+
+// hashEchoClient is a fakeEchoClient whose Hash records the request it got
+// and answers with a fixed digest, or fails unknown algorithms as A does.
+type hashEchoClient struct {
+	fakeEchoClient
+	got *echo.HashRequest
+}
+
+func (c *hashEchoClient) Hash(_ context.Context, in *echo.HashRequest, _ ...grpc.CallOption) (*echo.HashResponse, error) {
+	c.got = in
+	if in.Algorithm != "" && in.Algorithm != "sha256" {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown algorithm %q", in.Algorithm)
+	}
+	return &echo.HashResponse{Digest: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", Algorithm: "sha256"}, nil
+}
+
+func TestCallHash(t *testing.T) {
+	client := &hashEchoClient{}
+	h := http.HandlerFunc(newTestServiceB(client).callHash)
+
+	rec := serve(h, http.MethodGet, "/call-hash?msg=abc&algorithm=sha256")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if client.got.Msg != "abc" || client.got.Algorithm != "sha256" {
+		t.Errorf("A got %+v, want msg abc and algorithm sha256", client.got)
+	}
+	a := decodeBody(t, rec)["service_a"].(map[string]any)
+	if a["digest"] != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" || a["algorithm"] != "sha256" {
+		t.Errorf("service_a = %v", a)
+	}
+
+	rec = serve(h, http.MethodGet, "/call-hash?msg=abc&algorithm=md5")
+	assertEnvelope(t, rec, http.StatusBadRequest, errCodeUpstreamError, "InvalidArgument")
+}
+
+// syncBuffer is a bytes.Buffer safe to write from the server's goroutines
+// while a test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestH2CHealth(t *testing.T) {
+	var out syncBuffer
+	logger, err := logging.New(&out, logging.Config{Level: slog.LevelDebug, SampleRate: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	health := newTestServiceB(nil).health
+	h := httpLoggingMiddleware(logger, "B", func(r *http.Request) string { return r.URL.Path },
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Seen-Proto", r.Proto)
+			health(w, r)
+		}))
+	srv := httptest.NewServer(h2c.NewHandler(h, &http2.Server{}))
+	defer srv.Close()
+
+	// Prior-knowledge HTTP/2 over plain TCP.
+	h2Client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	for _, tt := range []struct {
+		client *http.Client
+		want   string
+	}{
+		{h2Client, "HTTP/2.0"},
+		{http.DefaultClient, "HTTP/1.1"},
+	} {
+		resp, err := tt.client.Get(srv.URL + "/health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if proto := resp.Header.Get("X-Seen-Proto"); resp.StatusCode != http.StatusOK || resp.Proto != tt.want || proto != tt.want {
+			t.Errorf("GET /health = %d over %s (handler saw %s), want 200 over %s", resp.StatusCode, resp.Proto, proto, tt.want)
+		}
+	}
+	if n := strings.Count(out.String(), "status=200"); n != 2 {
+		t.Errorf("logged %d successful requests, want 2:\n%s", n, out.String())
+	}
+}
+
+func TestSlowBodyTimesOut(t *testing.T) {
+	var out syncBuffer
+	log.SetOutput(&out)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	b := newTestServiceB(&fakeEchoClient{echoFn: echoOK})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(b.callEcho))
+	srv.Config.ReadTimeout = 200 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Promise a 100-byte body and send only part of it.
+	fmt.Fprintf(conn, "POST /call-echo HTTP/1.1\r\nHost: b\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"msg\":")
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("no response to a stalled body: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("status = %d, want 408", resp.StatusCode)
+	}
+	if !strings.Contains(out.String(), `error="request body read timed out"`) {
+		t.Errorf("log = %q, want the read timeout logged", out.String())
+	}
+}
+
+// statsEchoClient is a fakeEchoClient whose Stats reports fixed counters.
+type statsEchoClient struct{ fakeEchoClient }
+
+func (c *statsEchoClient) Stats(context.Context, *echo.StatsRequest, ...grpc.CallOption) (*echo.StatsResponse, error) {
+	return &echo.StatsResponse{EchoCalls: 3, EchoBytes: 42}, nil
+}
+
+func TestCallStats(t *testing.T) {
+	rec := serve(http.HandlerFunc(newTestServiceB(&statsEchoClient{}).callStats), http.MethodGet, "/call-stats")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	a := decodeBody(t, rec)["service_a"].(map[string]any)
+	if a["echo_calls"] != float64(3) || a["echo_bytes"] != float64(42) {
+		t.Errorf("service_a = %v, want echo_calls 3, echo_bytes 42", a)
+	}
+}
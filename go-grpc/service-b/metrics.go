@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/codes"
+)
+
+// --------------------
+// Prometheus metrics (served on /metrics)
+// --------------------
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "echo_http_requests_total",
+		Help: "HTTP requests handled by service B.",
+	}, []string{"endpoint", "status"})
+
+	httpErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "echo_http_errors_total",
+		Help: "HTTP requests handled by service B that returned a 4xx/5xx status.",
+	}, []string{"endpoint", "status"})
+
+	httpLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "echo_http_latency_seconds",
+		Help:    "Latency of HTTP requests handled by service B.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint", "status"})
+
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "echo_upstream_requests_total",
+		Help: "gRPC calls made by service B to service A, per attempt.",
+	}, []string{"method", "code"})
+
+	coalescedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "echo_coalesced_requests_total",
+		Help: "Requests service B answered from an identical request's in-flight call to service A.",
+	}, []string{"endpoint"})
+
+	upstreamLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "echo_upstream_latency_seconds",
+		Help:    "Latency of gRPC calls made by service B to service A.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "code"})
+)
+
+// otherRoute labels requests that match no route, so unknown paths share
+// one series instead of creating one each.
+const otherRoute = "other"
+
+// routeOf returns a func giving the mux pattern a request matches, e.g.
+// "/jobs/{id}", or otherRoute. HTTP metrics and span names use it instead of
+// the raw path, which any client could vary to grow the label set without
+// bound.
+func routeOf(mux *http.ServeMux) func(*http.Request) string {
+	return func(r *http.Request) string {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			return pattern
+		}
+		return otherRoute
+	}
+}
+
+func observeHTTP(endpoint string, httpStatus int, elapsed time.Duration) {
+	s := strconv.Itoa(httpStatus)
+	httpRequests.WithLabelValues(endpoint, s).Inc()
+	if httpStatus >= 400 {
+		httpErrors.WithLabelValues(endpoint, s).Inc()
+	}
+	httpLatency.WithLabelValues(endpoint, s).Observe(elapsed.Seconds())
+}
+
+func observeUpstream(method string, code codes.Code, elapsed time.Duration) {
+	c := code.String()
+	upstreamRequests.WithLabelValues(method, c).Inc()
+	upstreamLatency.WithLabelValues(method, c).Observe(elapsed.Seconds())
+}
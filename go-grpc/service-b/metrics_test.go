@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// scrape returns the /metrics exposition served by h.
+func scrape(t *testing.T, h http.Handler) string {
+	t.Helper()
+	rec := serve(h, http.MethodGet, "/metrics")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+// metricValue returns the value of the sample named series (name and
+// labels, as exposed) in the exposition, or 0 if it isn't there.
+func metricValue(t *testing.T, exposition, series string) float64 {
+	t.Helper()
+	for _, line := range strings.Split(exposition, "\n") {
+		if v, ok := strings.CutPrefix(line, series+" "); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				t.Fatalf("parse %q: %v", line, err)
+			}
+			return f
+		}
+	}
+	return 0
+}
+
+func newMetricsHandler(t *testing.T) http.Handler {
+	b := newTestServiceB(&fakeEchoClient{echoFn: echoOK})
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/call-echo", b.callEcho)
+	mux.HandleFunc("/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	return httpLoggingMiddleware(testLogger(t), "B", routeOf(mux), mux)
+}
+
+func TestMetricsCountEchoRequests(t *testing.T) {
+	h := newMetricsHandler(t)
+	const series = `echo_http_requests_total{endpoint="/call-echo",status="200"}`
+	before := metricValue(t, scrape(t, h), series)
+
+	if rec := serve(h, http.MethodGet, "/call-echo?msg=hi"); rec.Code != http.StatusOK {
+		t.Fatalf("GET /call-echo = %d, body %s", rec.Code, rec.Body)
+	}
+
+	if after := metricValue(t, scrape(t, h), series); after != before+1 {
+		t.Errorf("%s = %v after one request, want %v", series, after, before+1)
+	}
+}
+
+func TestMetricsLabelRoutesNotPaths(t *testing.T) {
+	h := newMetricsHandler(t)
+	serve(h, http.MethodGet, "/jobs/some-job-id")
+	serve(h, http.MethodGet, "/no/such/path")
+
+	exp := scrape(t, h)
+	for _, path := range []string{"some-job-id", "/no/such/path"} {
+		if strings.Contains(exp, path) {
+			t.Errorf("metrics mention the raw path %q", path)
+		}
+	}
+	for _, series := range []string{
+		`echo_http_requests_total{endpoint="/jobs/{id}",status="404"}`,
+		`echo_http_requests_total{endpoint="other",status="404"}`,
+	} {
+		if metricValue(t, exp, series) == 0 {
+			t.Errorf("no %s sample", series)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Standard outgoing metadata (B -> A)
+// --------------------
+
+// parseOutgoingMetadata parses the -outgoing-metadata value, a
+// comma-separated list of key=value pairs, into metadata key/value pairs.
+// Keys are lower-cased as gRPC metadata keys always are.
+func parseOutgoingMetadata(list string) ([]string, error) {
+	var kv []string
+	for _, pair := range strings.Split(list, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.ToLower(strings.TrimSpace(k))
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%q is not key=value", pair)
+		}
+		kv = append(kv, k, strings.TrimSpace(v))
+	}
+	return kv, nil
+}
+
+// withOutgoingMetadata adds the static pairs, B's schema version and, when
+// ctx belongs to an HTTP request, its request id to the outgoing metadata.
+func withOutgoingMetadata(ctx context.Context, static []string) context.Context {
+	kv := append(static[:len(static):len(static)], echo.SchemaVersionMetadataKey, strconv.Itoa(echo.SchemaVersion))
+	if id := requestIDFrom(ctx); id != "" {
+		kv = append(kv, echo.RequestIDMetadataKey, id)
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// outgoingMetadataUnaryInterceptor stamps every unary call B makes to A with
+// the standard metadata, so handlers only pass the request context along.
+func outgoingMetadataUnaryInterceptor(static []string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withOutgoingMetadata(ctx, static), method, req, reply, cc, opts...)
+	}
+}
+
+// outgoingMetadataStreamInterceptor is the streaming counterpart of
+// outgoingMetadataUnaryInterceptor.
+func outgoingMetadataStreamInterceptor(static []string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withOutgoingMetadata(ctx, static), desc, cc, method, opts...)
+	}
+}
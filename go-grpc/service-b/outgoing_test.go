@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"grpc-echo-json/echo"
+)
+
+// onceRepeatA answers RepeatEcho with a single message.
+type onceRepeatA struct {
+	fakeServiceA
+}
+
+func (onceRepeatA) RepeatEcho(req *echo.RepeatEchoRequest, stream echo.EchoService_RepeatEchoServer) error {
+	return stream.Send(&echo.EchoResponse{Echo: req.Msg})
+}
+
+func TestOutgoingMetadataReachesServiceA(t *testing.T) {
+	echo.RegisterCodecs()
+	seen := make(chan metadata.MD, 2)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			seen <- md
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			md, _ := metadata.FromIncomingContext(ss.Context())
+			seen <- md
+			return handler(srv, ss)
+		}),
+	)
+	echo.RegisterEchoServiceServer(s, onceRepeatA{})
+	go func() { _ = s.Serve(lis) }()
+	defer s.Stop()
+
+	static, err := parseOutgoingMetadata("Caller=service-b, env = test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+		grpc.WithUnaryInterceptor(outgoingMetadataUnaryInterceptor(static)),
+		grpc.WithStreamInterceptor(outgoingMetadataStreamInterceptor(static)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := echo.NewEchoServiceClient(conn)
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-1")
+	if _, err := client.Echo(ctx, &echo.EchoRequest{Msg: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := client.RepeatEcho(ctx, &echo.RepeatEchoRequest{Msg: "hi", Count: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"caller":                      "service-b",
+		"env":                         "test",
+		echo.RequestIDMetadataKey:     "req-1",
+		echo.SchemaVersionMetadataKey: strconv.Itoa(echo.SchemaVersion),
+	}
+	for _, call := range []string{"Echo", "RepeatEcho"} {
+		md := <-seen
+		for k, v := range want {
+			if got := md.Get(k); !reflect.DeepEqual(got, []string{v}) {
+				t.Errorf("%s: %s = %q, want [%s]", call, k, got, v)
+			}
+		}
+	}
+}
+
+func TestParseOutgoingMetadata(t *testing.T) {
+	got, err := parseOutgoingMetadata(" A=1,, b = two words ,c=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "1", "b", "two words", "c", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseOutgoingMetadata = %q, want %q", got, want)
+	}
+	for _, bad := range []string{"novalue", "=x"} {
+		if _, err := parseOutgoingMetadata(bad); err == nil {
+			t.Errorf("parseOutgoingMetadata(%q) succeeded, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Ping (RTT and clock skew)
+// --------------------
+
+// pingEstimate derives, NTP-style, the network round-trip time and service
+// A's clock offset from B's send/receive times (t0, t3) and A's
+// receive/send times (t1, t2). A positive offset means A's clock is ahead.
+func pingEstimate(t0, t1, t2, t3 int64) (rtt, offset time.Duration) {
+	rtt = time.Duration((t3 - t0) - (t2 - t1))
+	offset = time.Duration(((t1 - t0) + (t2 - t3)) / 2)
+	return rtt, offset
+}
+
+func (b *serviceB) callPing(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	ctxUp, cancel := context.WithTimeout(r.Context(), b.upstreamTimeout)
+	defer cancel()
+
+	// Ping is not retried: a retried attempt would make the RTT meaningless.
+	t0 := time.Now()
+	resp, err := b.echoClient.Ping(ctxUp, &echo.PingRequest{SentAtUnixNano: t0.UnixNano()})
+	if err != nil {
+		writeUpstreamError(w, r, "/call-ping", start, b.upstreamTimeout, err)
+		return
+	}
+	// t3 is taken from t0's monotonic clock, so B's side of the RTT can't go
+	// negative even if the wall clock jumps.
+	t3 := t0.UnixNano() + int64(time.Since(t0))
+
+	rtt, offset := pingEstimate(t0.UnixNano(), resp.ReceivedAtUnixNano, resp.SentAtUnixNano, t3)
+	if rtt < 0 {
+		rtt = 0
+	}
+	log.Printf("service=B endpoint=/call-ping status=ok rtt_us=%d offset_us=%d", rtt.Microseconds(), offset.Microseconds())
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"service_b":       "ok",
+		"service_a":       resp,
+		"rtt_ms":          float64(rtt.Microseconds()) / 1000,
+		"clock_offset_ms": float64(offset.Microseconds()) / 1000,
+	})
+}
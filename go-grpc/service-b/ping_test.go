@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"grpc-echo-json/echo"
+)
+
+func TestPingEstimate(t *testing.T) {
+	// 10ms each way, A took 2ms, and A's clock is 1s ahead.
+	const ms = int64(time.Millisecond)
+	t0 := int64(1_000_000) * ms
+	t1 := t0 + 10*ms + 1000*ms
+	t2 := t1 + 2*ms
+	t3 := t0 + 22*ms
+	rtt, offset := pingEstimate(t0, t1, t2, t3)
+	if rtt != 20*time.Millisecond || offset != time.Second {
+		t.Errorf("pingEstimate = rtt %s, offset %s; want 20ms, 1s", rtt, offset)
+	}
+}
+
+// pingEchoClient is a fakeEchoClient whose Ping answers as an A whose clock
+// is skew ahead of B's would.
+type pingEchoClient struct {
+	fakeEchoClient
+	skew time.Duration
+}
+
+func (c *pingEchoClient) Ping(_ context.Context, in *echo.PingRequest, _ ...grpc.CallOption) (*echo.PongResponse, error) {
+	now := time.Now().Add(c.skew).UnixNano()
+	return &echo.PongResponse{ReceivedAtUnixNano: now, SentAtUnixNano: now}, nil
+}
+
+func TestCallPing(t *testing.T) {
+	for _, skew := range []time.Duration{0, time.Hour, -time.Hour} {
+		b := newTestServiceB(&pingEchoClient{skew: skew})
+		rec := serve(http.HandlerFunc(b.callPing), http.MethodGet, "/call-ping")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("skew %s: status = %d, body %s", skew, rec.Code, rec.Body)
+		}
+		body := decodeBody(t, rec)
+		if rtt, _ := body["rtt_ms"].(float64); rtt < 0 || rtt > 1000 {
+			t.Errorf("skew %s: rtt_ms = %v, want a small non-negative value", skew, body["rtt_ms"])
+		}
+		offset, _ := body["clock_offset_ms"].(float64)
+		if diff := time.Duration(offset*float64(time.Millisecond)) - skew; diff < -time.Second || diff > time.Second {
+			t.Errorf("skew %s: clock_offset_ms = %v", skew, offset)
+		}
+	}
+}
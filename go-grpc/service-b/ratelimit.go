@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// --------------------
+// Per-client rate limiting (service B)
+// --------------------
+
+// limiterIdleTTL is how long an idle client's bucket is kept before it is
+// dropped; a returning client simply starts with a full bucket.
+const limiterIdleTTL = 3 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter keeps one token bucket per client IP. A rate <= 0 disables it.
+type rateLimiter struct {
+	rate    rate.Limit
+	burst   int
+	proxies trustedProxies
+	now     func() time.Time
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+// newRateLimiter returns a limiter allowing perSecond requests per client
+// with the given burst. Clients are identified by clientIP, trusting
+// X-Forwarded-For only from proxies.
+func newRateLimiter(perSecond float64, burst int, proxies trustedProxies) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate.Limit(perSecond),
+		burst:   burst,
+		proxies: proxies,
+		now:     time.Now,
+		clients: make(map[string]*clientLimiter),
+	}
+}
+
+// reserve takes a token from the client's bucket. It returns 0 if the
+// request may proceed, or how long the client should wait otherwise.
+func (rl *rateLimiter) reserve(client string) time.Duration {
+	if rl.rate <= 0 {
+		return 0
+	}
+	now := rl.now()
+
+	rl.mu.Lock()
+	if now.Sub(rl.lastSweep) > limiterIdleTTL {
+		for k, c := range rl.clients {
+			if now.Sub(c.lastSeen) > limiterIdleTTL {
+				delete(rl.clients, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+	c, ok := rl.clients[client]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(rl.rate, rl.burst)}
+		rl.clients[client] = c
+	}
+	c.lastSeen = now
+	rl.mu.Unlock()
+
+	r := c.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		// burst < 1: no request can ever pass.
+		return time.Second
+	}
+	if d := r.DelayFrom(now); d > 0 {
+		r.CancelAt(now)
+		return d
+	}
+	return 0
+}
+
+// trustedProxies are the -trusted-proxies networks whose X-Forwarded-For
+// headers B believes.
+type trustedProxies []netip.Prefix
+
+// parseTrustedProxies parses a comma-separated list of IPs and CIDRs, e.g.
+// "10.0.0.0/8,127.0.0.1". Empty trusts no proxy.
+func parseTrustedProxies(s string) (trustedProxies, error) {
+	var proxies trustedProxies
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if p, err := netip.ParsePrefix(v); err == nil {
+			proxies = append(proxies, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: want an IP or CIDR", v)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+func (t trustedProxies) trusts(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range t {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP identifies the caller: the host part of RemoteAddr. Only when
+// that peer is a trusted proxy is X-Forwarded-For consulted, walking it from
+// the nearest hop back and stopping at the first address that isn't itself
+// a trusted proxy; anything further left could have been made up by the
+// client.
+func clientIP(r *http.Request, trusted trustedProxies) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !trusted.trusts(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !trusted.trusts(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// middleware rejects over-limit requests with 429 and a Retry-After header
+// before they reach next (and service A).
+func (rl *rateLimiter) middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := clientIP(r, rl.proxies)
+		wait := rl.reserve(client)
+		if wait == 0 {
+			next(w, r)
+			return
+		}
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		log.Printf("service=B endpoint=%s status=throttled client=%s retry_after_s=%d", r.URL.Path, client, retryAfter)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		body := errorBody(http.StatusTooManyRequests, errCodeRateLimited, "too many requests, retry later", nil)
+		body["retry_after"] = retryAfter
+		writeJSON(w, r, http.StatusTooManyRequests, body)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestRateLimiterRejectsOverBurstAndRefills(t *testing.T) {
+	clock := time.Unix(1000, 0)
+	rl := newRateLimiter(1, 2, nil)
+	rl.now = func() time.Time { return clock }
+	h := rl.middleware(okHandler)
+
+	for i := 0; i < 2; i++ {
+		if rec := serve(h, http.MethodGet, "/call-echo"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within burst = %d", i+1, rec.Code)
+		}
+	}
+	rec := serve(h, http.MethodGet, "/call-echo")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over burst = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if code := decodeBody(t, rec)["error"].(map[string]any)["code"]; code != errCodeRateLimited {
+		t.Errorf("error.code = %v, want %s", code, errCodeRateLimited)
+	}
+
+	clock = clock.Add(time.Second)
+	if rec := serve(h, http.MethodGet, "/call-echo"); rec.Code != http.StatusOK {
+		t.Errorf("request after refill = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	h := newRateLimiter(0, 0, nil).middleware(okHandler)
+	for i := 0; i < 100; i++ {
+		if rec := serve(h, http.MethodGet, "/call-echo"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d = %d with the limiter off", i+1, rec.Code)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		remote  string
+		xff     string
+		trusted trustedProxies
+		want    string
+	}{
+		{"no proxy", "203.0.113.7:5555", "", proxies, "203.0.113.7"},
+		{"untrusted peer's XFF ignored", "203.0.113.7:5555", "1.2.3.4", proxies, "203.0.113.7"},
+		{"no trusted proxies configured", "10.0.0.1:5555", "1.2.3.4", nil, "10.0.0.1"},
+		{"trusted proxy", "10.0.0.1:5555", "1.2.3.4", proxies, "1.2.3.4"},
+		{"spoofed hop left of the real client", "10.0.0.1:5555", "6.6.6.6, 1.2.3.4", proxies, "1.2.3.4"},
+		{"chain of trusted proxies", "10.0.0.1:5555", "1.2.3.4, 192.168.1.1, 10.9.9.9", proxies, "1.2.3.4"},
+		{"only trusted hops", "10.0.0.1:5555", "10.2.2.2", proxies, "10.2.2.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/call-echo", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if got := clientIP(r, tt.trusted); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterIgnoresSpoofedXFF(t *testing.T) {
+	rl := newRateLimiter(1, 1, nil)
+	h := rl.middleware(okHandler)
+	codes := make([]int, 0, 2)
+	for _, xff := range []string{"1.1.1.1", "2.2.2.2"} {
+		r := httptest.NewRequest(http.MethodGet, "/call-echo", nil)
+		r.Header.Set("X-Forwarded-For", xff)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		codes = append(codes, rec.Code)
+	}
+	if codes[1] != http.StatusTooManyRequests {
+		t.Errorf("statuses = %v: a new X-Forwarded-For value bypassed the limit", codes)
+	}
+}
+
+func TestParseTrustedProxiesRejectsGarbage(t *testing.T) {
+	if _, err := parseTrustedProxies("10.0.0.0/8,not-an-ip"); err == nil {
+		t.Error("parseTrustedProxies accepted an invalid entry")
+	}
+}
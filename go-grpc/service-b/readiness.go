@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// --------------------
+// Readiness (/readyz) backed by periodic upstream health checks
+// --------------------
+
+// readiness caches the result of the last health check against one service
+// A upstream. The upstream is healthy only while that result is a success no
+// older than ttl. A ttl <= 0 never goes stale, for results pushed by a health
+// watch rather than refreshed by polling.
+type readiness struct {
+	addr string
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	healthy   bool
+	lastErr   string
+}
+
+func newReadiness(addr string, ttl time.Duration) *readiness {
+	return &readiness{addr: addr, ttl: ttl, now: time.Now}
+}
+
+// record stores the outcome of a health check, logging transitions.
+func (r *readiness) record(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	healthy := err == nil
+	if healthy != r.healthy || r.checkedAt.IsZero() {
+		if healthy {
+			log.Printf("service=B upstream=A addr=%s health=SERVING", r.addr)
+		} else {
+			log.Printf("service=B upstream=A addr=%s health=unavailable error=%q", r.addr, err.Error())
+		}
+	}
+	r.checkedAt = r.now()
+	r.healthy = healthy
+	r.lastErr = ""
+	if err != nil {
+		r.lastErr = err.Error()
+	}
+}
+
+// status reports whether the upstream is healthy and, if not, why.
+func (r *readiness) status() (healthy bool, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case r.checkedAt.IsZero():
+		return false, "service A not checked yet"
+	case r.ttl > 0 && r.now().Sub(r.checkedAt) > r.ttl:
+		return false, "last service A health check is stale"
+	case !r.healthy:
+		return false, r.lastErr
+	default:
+		return true, ""
+	}
+}
+
+// Values of -ready-policy.
+const (
+	readyPolicyAny = "any"
+	readyPolicyAll = "all"
+)
+
+// upstreamReadiness aggregates the readiness of every service A address B
+// was given. B is ready when at least one upstream is healthy, or with
+// requireAll when every one is, and never once it has been told to drain.
+type upstreamReadiness struct {
+	requireAll bool
+	upstreams  []*readiness
+
+	mu       sync.Mutex
+	draining bool
+}
+
+// newUpstreamReadiness tracks one readiness per address under policy, which
+// must be readyPolicyAny or readyPolicyAll.
+func newUpstreamReadiness(addrs []string, ttl time.Duration, policy string) (*upstreamReadiness, error) {
+	if policy != readyPolicyAny && policy != readyPolicyAll {
+		return nil, fmt.Errorf("unknown policy %q (want %s or %s)", policy, readyPolicyAny, readyPolicyAll)
+	}
+	u := &upstreamReadiness{requireAll: policy == readyPolicyAll}
+	for _, addr := range addrs {
+		u.upstreams = append(u.upstreams, newReadiness(addr, ttl))
+	}
+	return u, nil
+}
+
+// drain makes B report not ready from now on, whatever A's health.
+func (u *upstreamReadiness) drain() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.draining = true
+}
+
+// upstreamStatus is one upstream's entry in the /readyz body.
+type upstreamStatus struct {
+	Addr    string `json:"addr"`
+	Healthy bool   `json:"healthy"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// status reports whether B is ready, why not if it isn't, and the health of
+// each upstream.
+func (u *upstreamReadiness) status() (ready bool, reason string, upstreams []upstreamStatus) {
+	healthy := 0
+	for _, r := range u.upstreams {
+		ok, why := r.status()
+		if ok {
+			healthy++
+		} else if reason == "" {
+			reason = why
+		}
+		upstreams = append(upstreams, upstreamStatus{Addr: r.addr, Healthy: ok, Reason: why})
+	}
+
+	u.mu.Lock()
+	draining := u.draining
+	u.mu.Unlock()
+
+	switch {
+	case draining:
+		return false, "draining", upstreams
+	case u.requireAll && healthy < len(u.upstreams):
+		if len(u.upstreams) > 1 {
+			reason = fmt.Sprintf("%d of %d upstreams healthy: %s", healthy, len(u.upstreams), reason)
+		}
+		return false, reason, upstreams
+	case healthy == 0:
+		if len(u.upstreams) > 1 {
+			reason = fmt.Sprintf("no upstream healthy: %s", reason)
+		}
+		return false, reason, upstreams
+	default:
+		return true, "", upstreams
+	}
+}
+
+// run checks service A immediately and then every interval until ctx is
+// done. Each check gets its own timeout.
+func (r *readiness) run(ctx context.Context, interval, timeout time.Duration, check func(context.Context) error) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := check(checkCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		r.record(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// livez reports that the B process is up, regardless of service A.
+func (b *serviceB) livez(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz reports whether B can currently serve requests that need A, along
+// with the health of each upstream.
+func (b *serviceB) readyz(w http.ResponseWriter, r *http.Request) {
+	ready, reason, upstreams := b.ready.status()
+	if !ready {
+		writeJSON(w, r, http.StatusServiceUnavailable, map[string]any{
+			"status":    "not ready",
+			"ready":     false,
+			"reason":    reason,
+			"upstreams": upstreams,
+		})
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"status":    "ready",
+		"ready":     true,
+		"upstreams": upstreams,
+	})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestReadiness returns B with one upstream's readiness under a fake
+// clock, and a pointer to that clock.
+func newTestReadiness(t *testing.T, ttl time.Duration) (*serviceB, *readiness, *time.Time) {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	u, err := newUpstreamReadiness([]string{"a:50051"}, ttl, readyPolicyAny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1000, 0)
+	r := u.upstreams[0]
+	r.now = func() time.Time { return now }
+	return &serviceB{ready: u}, r, &now
+}
+
+func TestReadyzFlipsWhenUpstreamGoesDown(t *testing.T) {
+	b, r, _ := newTestReadiness(t, time.Minute)
+	h := http.HandlerFunc(b.readyz)
+
+	if rec := serve(h, http.MethodGet, "/readyz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before any check: status = %d, want 503", rec.Code)
+	}
+
+	r.record(nil)
+	if rec := serve(h, http.MethodGet, "/readyz"); rec.Code != http.StatusOK {
+		t.Fatalf("upstream healthy: status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	r.record(errors.New("connection refused"))
+	rec := serve(h, http.MethodGet, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("upstream down: status = %d, want 503", rec.Code)
+	}
+	if body := decodeBody(t, rec); body["ready"] != false || body["reason"] != "connection refused" {
+		t.Errorf("upstream down: body = %v", body)
+	}
+}
+
+func TestReadyzStaleResult(t *testing.T) {
+	b, r, now := newTestReadiness(t, time.Minute)
+	h := http.HandlerFunc(b.readyz)
+
+	r.record(nil)
+	*now = now.Add(59 * time.Second)
+	if rec := serve(h, http.MethodGet, "/readyz"); rec.Code != http.StatusOK {
+		t.Errorf("within ttl: status = %d, want 200", rec.Code)
+	}
+	*now = now.Add(2 * time.Second)
+	rec := serve(h, http.MethodGet, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("past ttl: status = %d, want 503", rec.Code)
+	}
+	if body := decodeBody(t, rec); body["reason"] != "last service A health check is stale" {
+		t.Errorf("past ttl: reason = %v", body["reason"])
+	}
+}
+
+func TestReadinessRunRecordsChecks(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	r := newReadiness("a:50051", time.Minute)
+	var up atomic.Bool
+	up.Store(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.run(ctx, 5*time.Millisecond, time.Second, func(context.Context) error {
+			if up.Load() {
+				return nil
+			}
+			return errors.New("down")
+		})
+	}()
+	defer func() { cancel(); <-done }()
+
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if healthy, _ := r.status(); healthy == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("readiness never became healthy=%t", want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitFor(true)
+	up.Store(false)
+	waitFor(false)
+}
+
+func TestLivezIgnoresUpstream(t *testing.T) {
+	b, _, _ := newTestReadiness(t, time.Minute)
+	if rec := serve(http.HandlerFunc(b.livez), http.MethodGet, "/livez"); rec.Code != http.StatusOK {
+		t.Errorf("/livez with A unchecked: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestReadyzAggregatesUpstreams(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	for _, tt := range []struct {
+		policy string
+		want   int
+	}{
+		{readyPolicyAny, http.StatusOK},
+		{readyPolicyAll, http.StatusServiceUnavailable},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			u, err := newUpstreamReadiness([]string{"a1:50051", "a2:50051"}, time.Minute, tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u.upstreams[0].record(nil)
+			u.upstreams[1].record(errors.New("connection refused"))
+
+			rec := serve(http.HandlerFunc((&serviceB{ready: u}).readyz), http.MethodGet, "/readyz")
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.want, rec.Body)
+			}
+			body := decodeBody(t, rec)
+			if body["ready"] != (tt.want == http.StatusOK) {
+				t.Errorf("ready = %v", body["ready"])
+			}
+			upstreams, _ := body["upstreams"].([]any)
+			if len(upstreams) != 2 {
+				t.Fatalf("upstreams = %v, want 2 entries", body["upstreams"])
+			}
+			for i, want := range []map[string]any{
+				{"addr": "a1:50051", "healthy": true},
+				{"addr": "a2:50051", "healthy": false, "reason": "connection refused"},
+			} {
+				got := upstreams[i].(map[string]any)
+				for k, v := range want {
+					if got[k] != v {
+						t.Errorf("upstreams[%d].%s = %v, want %v", i, k, got[k], v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReadyzNoUpstreamHealthy(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	u, err := newUpstreamReadiness([]string{"a1:50051", "a2:50051"}, time.Minute, readyPolicyAny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range u.upstreams {
+		r.record(errors.New("connection refused"))
+	}
+	rec := serve(http.HandlerFunc((&serviceB{ready: u}).readyz), http.MethodGet, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if reason := decodeBody(t, rec)["reason"]; reason != "no upstream healthy: connection refused" {
+		t.Errorf("reason = %v", reason)
+	}
+}
+
+func TestNewUpstreamReadinessRejectsUnknownPolicy(t *testing.T) {
+	if _, err := newUpstreamReadiness([]string{"a:50051"}, time.Minute, "most"); err == nil {
+		t.Error(`policy "most" accepted, want an error`)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// --------------------
+// Request IDs (HTTP -> gRPC correlation)
+// --------------------
+
+const requestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// newRequestID returns a random RFC 4122 version 4 UUID.
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand doesn't fail on supported platforms; an empty id just
+		// means the request goes uncorrelated.
+		return ""
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// maxRequestIDLen bounds a caller-supplied X-Request-Id.
+const maxRequestIDLen = 128
+
+// validRequestID reports whether a caller's X-Request-Id is safe to reuse:
+// 1 to maxRequestIDLen characters from [A-Za-z0-9._-]. Anything else would
+// end up verbatim in B's and A's logs and in A's metadata.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range []byte(id) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID reuses the caller's X-Request-Id if it is valid, otherwise
+// generates one, stores it in the request context and echoes it back in the
+// response header.
+func withRequestID(r *http.Request, w http.ResponseWriter) *http.Request {
+	id := r.Header.Get(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(requestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
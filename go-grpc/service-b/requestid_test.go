@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"grpc-echo-json/echo"
+)
+
+// startRecordingServiceA serves fakeServiceA, reporting the request id of
+// each call it gets on ids, and returns a client that sends B's outgoing
+// metadata.
+func startRecordingServiceA(t *testing.T, ids chan<- string) echo.EchoServiceClient {
+	t.Helper()
+	echo.RegisterCodecs()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ids <- firstOf(md.Get(echo.RequestIDMetadataKey))
+		return handler(ctx, req)
+	}))
+	echo.RegisterEchoServiceServer(s, fakeServiceA{})
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+		grpc.WithUnaryInterceptor(outgoingMetadataUnaryInterceptor(nil)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return echo.NewEchoServiceClient(conn)
+}
+
+func firstOf(v []string) string {
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0]
+}
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestIDReachesServiceA(t *testing.T) {
+	ids := make(chan string, 1)
+	b := newTestServiceB(startRecordingServiceA(t, ids))
+	h := httpLoggingMiddleware(testLogger(t), "B", func(r *http.Request) string { return r.URL.Path }, http.HandlerFunc(b.callEcho))
+
+	for _, sent := range []string{"caller-chosen-id", ""} {
+		r := httptest.NewRequest(http.MethodGet, "/call-echo?msg=hi", nil)
+		if sent != "" {
+			r.Header.Set(requestIDHeader, sent)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+
+		got := rec.Header().Get(requestIDHeader)
+		if sent != "" && got != sent {
+			t.Errorf("response %s = %q, want the caller's %q", requestIDHeader, got, sent)
+		}
+		if sent == "" && !uuidV4.MatchString(got) {
+			t.Errorf("generated %s = %q, want a v4 UUID", requestIDHeader, got)
+		}
+		if atA := <-ids; atA != got {
+			t.Errorf("service A saw request id %q, B answered with %q", atA, got)
+		}
+	}
+}
+
+func TestWithRequestIDReplacesInvalidIDs(t *testing.T) {
+	tests := []struct {
+		sent string
+		keep bool
+	}{
+		{"abc-123_X.y", true},
+		{strings.Repeat("a", maxRequestIDLen), true},
+		{strings.Repeat("a", maxRequestIDLen+1), false},
+		{"has space", false},
+		{"line\nbreak", false},
+		{"quote\"d", false},
+		{"ünïcode", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/call-echo", nil)
+		r.Header.Set(requestIDHeader, tt.sent)
+		rec := httptest.NewRecorder()
+		got := requestIDFrom(withRequestID(r, rec).Context())
+		if tt.keep && got != tt.sent {
+			t.Errorf("X-Request-Id %q replaced by %q, want it kept", tt.sent, got)
+		}
+		if !tt.keep && !uuidV4.MatchString(got) {
+			t.Errorf("X-Request-Id %q kept as %q, want a fresh v4 UUID", tt.sent, got)
+		}
+		if h := rec.Header().Get(requestIDHeader); h != got {
+			t.Errorf("response %s = %q, want %q", requestIDHeader, h, got)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// Resilient calls to service A (timeout, circuit breaker, retry)
+// --------------------
+
+// errCircuitOpen is returned by resilientEchoClient while the breaker is
+// open, as a circuitOpenError saying when the breaker will next let a call
+// through.
+var errCircuitOpen = errors.New("circuit open, not calling service A")
+
+type circuitOpenError struct {
+	retryIn time.Duration
+}
+
+func (circuitOpenError) Error() string        { return errCircuitOpen.Error() }
+func (circuitOpenError) Is(target error) bool { return target == errCircuitOpen }
+
+// resilientEchoClient applies B's resilience policy to calls on the
+// generated EchoService client: a default timeout, the circuit breaker and
+// retries of transient failures. Handlers use Echo and ReverseEcho for the
+// echo-style RPCs, and call to run any other RPC under the same policy.
+type resilientEchoClient struct {
+	client     echo.EchoServiceClient
+	timeout    time.Duration
+	maxRetries int
+	breaker    *breaker
+}
+
+func newResilientEchoClient(client echo.EchoServiceClient, timeout time.Duration, maxRetries int, br *breaker) *resilientEchoClient {
+	return &resilientEchoClient{client: client, timeout: timeout, maxRetries: maxRetries, breaker: br}
+}
+
+// call runs fn against service A behind the circuit breaker, retrying
+// transient failures until ctx is done. It adds no timeout of its own.
+func (c *resilientEchoClient) call(ctx context.Context, fn func(context.Context) error) error {
+	if !c.breaker.allow() {
+		return circuitOpenError{retryIn: c.breaker.retryIn()}
+	}
+	err := callWithRetry(ctx, c.maxRetries, fn)
+	// Only failures to reach A count against the breaker; a request A
+	// rejects on its merits says nothing about A's health.
+	c.breaker.record(err == nil || !retryable(err))
+	return err
+}
+
+// echoCall holds the settings echoOptions adjust for one Echo or
+// ReverseEcho call.
+type echoCall struct {
+	transform string
+	callOpts  []grpc.CallOption
+}
+
+// echoOption adjusts a single resilientEchoClient.Echo or ReverseEcho call.
+type echoOption func(*echoCall)
+
+// withTransform asks A to apply transform to the message; see
+// echo.EchoRequest.Transform.
+func withTransform(transform string) echoOption {
+	return func(c *echoCall) { c.transform = transform }
+}
+
+// withCallOptions passes opts, such as grpc.Trailer, to every attempt.
+func withCallOptions(opts ...grpc.CallOption) echoOption {
+	return func(c *echoCall) { c.callOpts = append(c.callOpts, opts...) }
+}
+
+// Echo calls A's Echo with msg under the resilience policy and returns the
+// echoed message. A deadline already on ctx bounds the call, retries
+// included; without one, the call gets c.timeout.
+func (c *resilientEchoClient) Echo(ctx context.Context, msg string, opts ...echoOption) (string, error) {
+	return c.echo(ctx, c.client.Echo, msg, opts)
+}
+
+// ReverseEcho is Echo for A's ReverseEcho.
+func (c *resilientEchoClient) ReverseEcho(ctx context.Context, msg string, opts ...echoOption) (string, error) {
+	return c.echo(ctx, c.client.ReverseEcho, msg, opts)
+}
+
+func (c *resilientEchoClient) echo(ctx context.Context, rpc echoRPC, msg string, opts []echoOption) (string, error) {
+	var ec echoCall
+	for _, o := range opts {
+		o(&ec)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	req := &echo.EchoRequest{Msg: msg, Transform: ec.transform}
+	var resp *echo.EchoResponse
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		resp, err = rpc(ctx, req, ec.callOpts...)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Echo, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+// flakyEcho fails the first n calls with code, then echoes.
+func flakyEcho(n int, code codes.Code) func(context.Context, *echo.EchoRequest) (*echo.EchoResponse, error) {
+	calls := 0
+	return func(ctx context.Context, in *echo.EchoRequest) (*echo.EchoResponse, error) {
+		if calls++; calls <= n {
+			return nil, status.Error(code, "flaky")
+		}
+		return echoOK(ctx, in)
+	}
+}
+
+func TestResilientEchoClientPassesTransformThrough(t *testing.T) {
+	var got *echo.EchoRequest
+	client := &fakeEchoClient{echoFn: func(ctx context.Context, in *echo.EchoRequest) (*echo.EchoResponse, error) {
+		got = in
+		return echoOK(ctx, in)
+	}}
+	c := newResilientEchoClient(client, time.Second, 0, newBreaker(0, 0))
+	msg, err := c.Echo(context.Background(), "hi", withTransform("upper"))
+	if err != nil || msg != "hi" {
+		t.Fatalf("Echo = %q, %v; want hi", msg, err)
+	}
+	if got.Transform != "upper" {
+		t.Errorf("A got transform %q, want upper", got.Transform)
+	}
+}
+
+// reversingEchoClient answers ReverseEcho with the message reversed.
+type reversingEchoClient struct{ fakeEchoClient }
+
+func (*reversingEchoClient) ReverseEcho(_ context.Context, in *echo.EchoRequest, _ ...grpc.CallOption) (*echo.EchoResponse, error) {
+	r := []rune(in.Msg)
+	slices.Reverse(r)
+	return &echo.EchoResponse{Echo: string(r)}, nil
+}
+
+func TestResilientEchoClientReverseEcho(t *testing.T) {
+	c := newResilientEchoClient(&reversingEchoClient{fakeEchoClient{echoFn: echoOK}}, time.Second, 0, newBreaker(0, 0))
+	msg, err := c.ReverseEcho(context.Background(), "abc")
+	if err != nil || msg != "cba" {
+		t.Fatalf("ReverseEcho = %q, %v; want cba", msg, err)
+	}
+	if msg, err := c.Echo(context.Background(), "abc"); err != nil || msg != "abc" {
+		t.Fatalf("Echo = %q, %v; want abc", msg, err)
+	}
+}
+
+func TestResilientEchoClientRetries(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	tests := []struct {
+		name       string
+		failures   int
+		code       codes.Code
+		maxRetries int
+		wantCode   codes.Code
+		wantCalls  int32
+	}{
+		{"transient within budget", 2, codes.Unavailable, 2, codes.OK, 3},
+		{"transient over budget", 3, codes.Unavailable, 2, codes.Unavailable, 3},
+		{"rejected not retried", 1, codes.InvalidArgument, 2, codes.InvalidArgument, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeEchoClient{echoFn: flakyEcho(tt.failures, tt.code)}
+			c := newResilientEchoClient(client, 5*time.Second, tt.maxRetries, newBreaker(0, 0))
+			_, err := c.Echo(context.Background(), "hi")
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("err = %v, want %s", err, tt.wantCode)
+			}
+			if n := client.calls.Load(); n != tt.wantCalls {
+				t.Errorf("A got %d calls, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestResilientEchoClientTimeout(t *testing.T) {
+	var deadline time.Time
+	client := &fakeEchoClient{echoFn: func(ctx context.Context, in *echo.EchoRequest) (*echo.EchoResponse, error) {
+		deadline, _ = ctx.Deadline()
+		return echoOK(ctx, in)
+	}}
+	c := newResilientEchoClient(client, 200*time.Millisecond, 0, newBreaker(0, 0))
+
+	// No deadline on ctx: the client's default applies.
+	start := time.Now()
+	if _, err := c.Echo(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if deadline.Before(start.Add(200*time.Millisecond)) || deadline.After(time.Now().Add(200*time.Millisecond)) {
+		t.Errorf("default deadline %s after the call started, want 200ms", deadline.Sub(start))
+	}
+
+	// The caller's deadline wins, even a longer one.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	if _, err := c.Echo(ctx, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if !deadline.Equal(want) {
+		t.Errorf("deadline = %s, want the caller's %s", deadline, want)
+	}
+}
+
+func TestResilientEchoClientBreaker(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	br, clock := newTestBreaker(2, 10*time.Second)
+	client := &fakeEchoClient{echoFn: failingEcho(codes.Unavailable)}
+	c := newResilientEchoClient(client, time.Second, 0, br)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Echo(context.Background(), "hi"); status.Code(err) != codes.Unavailable {
+			t.Fatalf("call %d: err = %v, want Unavailable", i, err)
+		}
+	}
+	_, err := c.Echo(context.Background(), "hi")
+	var open circuitOpenError
+	if !errors.Is(err, errCircuitOpen) || !errors.As(err, &open) || open.retryIn != 10*time.Second {
+		t.Fatalf("call with the breaker open: err = %v, want circuit open retrying in 10s", err)
+	}
+	if n := client.calls.Load(); n != 2 {
+		t.Errorf("A got %d calls, want 2: the open breaker should short-circuit", n)
+	}
+
+	// A rejection on the merits doesn't count against A's health.
+	*clock = clock.Add(10 * time.Second)
+	client.echoFn = failingEcho(codes.InvalidArgument)
+	for i := 0; i < 3; i++ {
+		if _, err := c.Echo(context.Background(), "hi"); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("rejected call %d: err = %v, want InvalidArgument", i, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// --------------------
+// Retry with exponential backoff (B -> A)
+// --------------------
+
+const (
+	retryBaseDelay = 50 * time.Millisecond
+	retryMaxDelay  = 500 * time.Millisecond
+)
+
+// retryable reports whether a failed call to A is worth repeating. Only
+// transient transport/deadline failures are; anything else (InvalidArgument
+// and friends) would fail the same way again.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before retry number attempt (0-based): an
+// exponentially growing cap with full jitter.
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << attempt
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(d) + 1))
+}
+
+// retryAfterSeconds is the Retry-After B suggests when A is unreachable:
+// the time until the breaker next probes A when it is open, otherwise the
+// longest backoff B itself waits between attempts. It is at least 1, the
+// smallest useful Retry-After.
+func retryAfterSeconds(err error) int {
+	d := retryMaxDelay
+	var open circuitOpenError
+	if errors.As(err, &open) {
+		d = open.retryIn
+	}
+	return max(int(math.Ceil(d.Seconds())), 1)
+}
+
+// callWithRetry runs call, retrying retryable failures up to maxRetries
+// times. It gives up early once ctx is done, so the caller's upstream timeout
+// bounds the total time spent including backoff.
+func callWithRetry(ctx context.Context, maxRetries int, call func(context.Context) error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = call(ctx)
+		if err == nil || !retryable(err) || attempt >= maxRetries {
+			return err
+		}
+
+		delay := backoff(attempt)
+		log.Printf("service=B upstream=A retry=%d code=%s backoff_ms=%d", attempt+1, status.Code(err), delay.Milliseconds())
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
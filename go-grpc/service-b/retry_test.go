@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+func TestCallEchoRetriesTransientFailures(t *testing.T) {
+	client := &fakeEchoClient{}
+	client.echoFn = func(ctx context.Context, in *echo.EchoRequest) (*echo.EchoResponse, error) {
+		if client.calls.Load() <= 2 {
+			return nil, status.Error(codes.Unavailable, "not yet")
+		}
+		return echoOK(ctx, in)
+	}
+	b := newTestServiceB(client)
+	b.upstream.maxRetries = 2
+
+	rec := serve(http.HandlerFunc(b.callEcho), http.MethodGet, "/call-echo?msg=hi")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	if n := client.calls.Load(); n != 3 {
+		t.Errorf("A called %d times, want 3", n)
+	}
+}
+
+func TestCallEchoDoesNotRetryInvalidArgument(t *testing.T) {
+	client := &fakeEchoClient{echoFn: func(context.Context, *echo.EchoRequest) (*echo.EchoResponse, error) {
+		return nil, status.Error(codes.InvalidArgument, "bad msg")
+	}}
+	b := newTestServiceB(client)
+	b.upstream.maxRetries = 2
+
+	rec := serve(http.HandlerFunc(b.callEcho), http.MethodGet, "/call-echo?msg=hi")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if n := client.calls.Load(); n != 1 {
+		t.Errorf("A called %d times, want 1", n)
+	}
+}
+
+func TestCallWithRetryStopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	calls := 0
+	err := callWithRetry(ctx, 100, func(context.Context) error {
+		calls++
+		return status.Error(codes.Unavailable, "down")
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+	if calls >= 100 {
+		t.Errorf("made %d attempts; the deadline should have stopped retries", calls)
+	}
+}
+
+func TestBackoffStaysWithinCap(t *testing.T) {
+	for attempt := 0; attempt < 70; attempt++ {
+		if d := backoff(attempt); d < 0 || d > retryMaxDelay {
+			t.Fatalf("backoff(%d) = %s, want within [0, %s]", attempt, d, retryMaxDelay)
+		}
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{status.Error(codes.Unavailable, "down"), 1}, // retryMaxDelay rounds up
+		{circuitOpenError{retryIn: 2500 * time.Millisecond}, 3},
+		{circuitOpenError{retryIn: 0}, 1},
+	}
+	for _, tt := range tests {
+		if got := retryAfterSeconds(tt.err); got != tt.want {
+			t.Errorf("retryAfterSeconds(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCallEchoUnavailableRetryAfter(t *testing.T) {
+	b := newTestServiceB(&fakeEchoClient{echoFn: failingEcho(codes.Unavailable)})
+	rec := serve(http.HandlerFunc(b.callEcho), http.MethodGet, "/call-echo?msg=hi")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	secs, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || secs < 1 {
+		t.Fatalf("Retry-After = %q, want a positive number of seconds", rec.Header().Get("Retry-After"))
+	}
+	if body := decodeBody(t, rec); body["retry_after"] != float64(secs) {
+		t.Errorf("retry_after = %v, want %d to match the header", body["retry_after"], secs)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// --------------------
+// Startup self-test (-selftest)
+// --------------------
+
+// selfTestMsg is the message -selftest sends to service A.
+const selfTestMsg = "selftest"
+
+// runSelfTest makes one Echo call to service A through c, with the same
+// timeout, retries and breaker as a /call-echo request, and reports the
+// round trip on w. A non-nil error means B can't serve echoes right now;
+// with -selftest, B exits non-zero on it instead of starting its HTTP
+// server, which suits container healthchecks and CI smoke tests.
+func runSelfTest(ctx context.Context, c *resilientEchoClient, w io.Writer) error {
+	start := time.Now()
+	got, err := c.Echo(ctx, selfTestMsg)
+	if err != nil {
+		return fmt.Errorf("echo failed: %w", err)
+	}
+	// A may be running with a -mode or -echo-prefix that changes the reply,
+	// so any non-empty echo counts as a successful round trip.
+	if got == "" {
+		return fmt.Errorf("echo returned an empty message")
+	}
+	fmt.Fprintf(w, "service=B selftest=ok echo=%q latency_ms=%d\n", got, time.Since(start).Milliseconds())
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+func TestRunSelfTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		echoFn  func(context.Context, *echo.EchoRequest) (*echo.EchoResponse, error)
+		wantErr string
+	}{
+		{"ok", echoOK, ""},
+		{"A down", failingEcho(codes.Unavailable), "echo failed"},
+		{"empty echo", func(context.Context, *echo.EchoRequest) (*echo.EchoResponse, error) {
+			return &echo.EchoResponse{}, nil
+		}, "empty message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newResilientEchoClient(&fakeEchoClient{echoFn: tt.echoFn}, time.Second, 0, newBreaker(0, 0))
+			var out bytes.Buffer
+			err := runSelfTest(context.Background(), c, &out)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("runSelfTest = %v, want nil", err)
+				}
+				if !strings.Contains(out.String(), `selftest=ok echo="selftest"`) {
+					t.Errorf("output = %q, want the round trip reported", out.String())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("runSelfTest = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if out.Len() != 0 {
+				t.Errorf("output = %q on failure, want none", out.String())
+			}
+		})
+	}
+}
+
+func TestRunSelfTestKeepsUpstreamCode(t *testing.T) {
+	c := newResilientEchoClient(&fakeEchoClient{echoFn: failingEcho(codes.Unavailable)}, time.Second, 0, newBreaker(0, 0))
+	if err := runSelfTest(context.Background(), c, &bytes.Buffer{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("runSelfTest err = %v, want it to wrap Unavailable", err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusClientClosedRequest is the de facto (nginx) status for a request the
+// client gave up on; net/http has no constant for it.
+const statusClientClosedRequest = 499
+
+// httpStatusFromGRPC maps the gRPC status of a failed B -> A call to the HTTP
+// status service B returns, following the usual gRPC/HTTP gateway mapping.
+func httpStatusFromGRPC(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return statusClientClosedRequest
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default: // Unknown, Internal, DataLoss
+		return http.StatusInternalServerError
+	}
+}
+
+// decodeErrorPrefix starts the message grpc gives the Internal status it
+// returns when B's codec can't unmarshal a response from A.
+const decodeErrorPrefix = "grpc: failed to unmarshal the received message"
+
+// isUpstreamDecodeError reports whether err is B failing to decode A's
+// response (a protocol error), as opposed to A returning an error or the
+// call failing in transport. grpc doesn't expose this as a distinct error,
+// so it is told apart by its message.
+func isUpstreamDecodeError(err error) bool {
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.Internal && strings.HasPrefix(st.Message(), decodeErrorPrefix)
+}
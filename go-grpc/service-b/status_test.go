@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestHTTPStatusFromGRPC(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want int
+	}{
+		{codes.OK, http.StatusOK},
+		{codes.Canceled, statusClientClosedRequest},
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.FailedPrecondition, http.StatusBadRequest},
+		{codes.OutOfRange, http.StatusBadRequest},
+		{codes.Unauthenticated, http.StatusUnauthorized},
+		{codes.PermissionDenied, http.StatusForbidden},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.AlreadyExists, http.StatusConflict},
+		{codes.Aborted, http.StatusConflict},
+		{codes.ResourceExhausted, http.StatusTooManyRequests},
+		{codes.Unimplemented, http.StatusNotImplemented},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout},
+		{codes.Unknown, http.StatusInternalServerError},
+		{codes.Internal, http.StatusInternalServerError},
+		{codes.DataLoss, http.StatusInternalServerError},
+		{codes.Code(99), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			if got := httpStatusFromGRPC(tt.code); got != tt.want {
+				t.Errorf("httpStatusFromGRPC(%s) = %d, want %d", tt.code, got, tt.want)
+			}
+		})
+	}
+}
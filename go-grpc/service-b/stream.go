@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+)
+
+// --------------------
+// /call-repeat: RepeatEcho relayed as Server-Sent Events or NDJSON
+// --------------------
+
+// ndjsonContentType is the media type of newline-delimited JSON. Clients get
+// it from /call-repeat by sending it in Accept or by passing ?format=ndjson.
+const ndjsonContentType = "application/x-ndjson"
+
+// eventWriter writes one streamed record. event is "" for a message, or
+// "done" / "error" for the record that ends the stream.
+type eventWriter func(w io.Writer, event string, data map[string]any) error
+
+// writeSSE writes one event; an empty event name means the default
+// "message" event.
+func writeSSE(w io.Writer, event string, data map[string]any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if event != "" {
+		if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", b)
+	return err
+}
+
+// writeNDJSON writes one record as a line of JSON. Since lines carry no
+// event name, the closing records are marked with a "done": true or an
+// "error" field instead.
+func writeNDJSON(w io.Writer, event string, data map[string]any) error {
+	if event == "done" {
+		data["done"] = true
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// wantsNDJSON reports whether the client asked for NDJSON rather than the
+// default event stream.
+func wantsNDJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+}
+
+// callRepeat streams A's RepeatEcho responses to the client as they arrive.
+// The stream is bounded by the client's connection rather than B's upstream
+// timeout, since it is expected to take count * A's -repeat-interval.
+func (b *serviceB) callRepeat(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	q := r.URL.Query()
+	count, err := strconv.Atoi(q.Get("count"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, errCodeBadRequest, errors.New("count must be an integer"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, errCodeInternal, errors.New("streaming not supported"))
+		return
+	}
+
+	// The stream legitimately outlives -write-timeout, so lift the write
+	// deadline for this response.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("service=B endpoint=/call-repeat write_deadline_error=%q", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	stream, err := b.echoClient.RepeatEcho(ctx, &echo.RepeatEchoRequest{Msg: q.Get("msg"), Count: int32(count)})
+	if err != nil {
+		writeUpstreamError(w, r, "/call-repeat", start, 0, err)
+		return
+	}
+
+	// Errors such as InvalidArgument only surface on the first Recv, so read
+	// it before committing to a 200 event stream.
+	first, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = status.Error(status.Code(err), "service A closed the stream without a message")
+		}
+		writeUpstreamError(w, r, "/call-repeat", start, 0, err)
+		return
+	}
+
+	// With no Content-Length and a flush per record, net/http sends the
+	// body with Transfer-Encoding: chunked.
+	writeEvent := eventWriter(writeSSE)
+	if wantsNDJSON(r) {
+		writeEvent = writeNDJSON
+		w.Header().Set("Content-Type", ndjsonContentType)
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	sent := 0
+	var streamErr error
+	for resp := first; ; {
+		if r.Context().Err() != nil {
+			break // client went away
+		}
+		if err := writeEvent(w, "", map[string]any{"seq": sent, "echo": resp.Echo}); err != nil {
+			break // client went away
+		}
+		flusher.Flush()
+		sent++
+
+		resp, err = stream.Recv()
+		if err == io.EOF {
+			_ = writeEvent(w, "done", map[string]any{"count": sent})
+			flusher.Flush()
+			break
+		}
+		if err != nil {
+			if r.Context().Err() == nil {
+				streamErr = err
+				_ = writeEvent(w, "error", map[string]any{
+					"error": errorObject(errCodeUpstreamError, status.Convert(err).Message(), err),
+				})
+				flusher.Flush()
+			}
+			break
+		}
+	}
+
+	// The 200 has already gone out, so a stream that fails part way is only
+	// visible as an error event and in this log line.
+	if streamErr != nil {
+		log.Printf("service=B endpoint=/call-repeat status=error code=%s error=%q msgs_sent=%d latency_ms=%d",
+			status.Code(streamErr), streamErr.Error(), sent, time.Since(start).Milliseconds())
+		return
+	}
+	log.Printf("service=B endpoint=/call-repeat status=ok msgs_sent=%d latency_ms=%d", sent, time.Since(start).Milliseconds())
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/testutil"
+)
+
+// endlessRepeatA streams RepeatEcho messages until the caller goes away,
+// then closes stopped.
+type endlessRepeatA struct {
+	fakeServiceA
+	stopped chan struct{}
+}
+
+func (a endlessRepeatA) RepeatEcho(req *echo.RepeatEchoRequest, stream echo.EchoService_RepeatEchoServer) error {
+	defer close(a.stopped)
+	for {
+		if err := stream.Send(&echo.EchoResponse{Echo: req.Msg}); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestCallRepeatNDJSONStopsWhenClientCancels(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	a := endlessRepeatA{stopped: make(chan struct{})}
+	srv, err := testutil.StartEchoServer(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	b := testutil.StartServiceB(srv.Conn, func(client echo.EchoServiceClient) http.Handler {
+		return http.HandlerFunc(newTestServiceB(client).callRepeat)
+	})
+	t.Cleanup(b.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, b.URL+"/call-repeat?msg=hi&count=1000&format=ndjson", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ndjsonContentType {
+		t.Fatalf("status = %d, Content-Type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("Transfer-Encoding = %v, want chunked", resp.TransferEncoding)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	for seq := 0; seq < 3; seq++ {
+		if !lines.Scan() {
+			t.Fatalf("stream ended after %d lines: %v", seq, lines.Err())
+		}
+		var rec map[string]any
+		if err := json.Unmarshal(lines.Bytes(), &rec); err != nil {
+			t.Fatalf("line %d %q: %v", seq, lines.Text(), err)
+		}
+		if rec["seq"] != float64(seq) || rec["echo"] != "hi" {
+			t.Errorf("line %d = %v", seq, rec)
+		}
+	}
+
+	// Hanging up must cancel the upstream stream rather than leave B
+	// relaying the remaining messages to nobody.
+	cancel()
+	select {
+	case <-a.stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("service A's stream was still running 2s after the client went away")
+	}
+}
+
+// failingRepeatA sends two RepeatEcho messages, then fails the stream.
+type failingRepeatA struct{ fakeServiceA }
+
+func (failingRepeatA) RepeatEcho(req *echo.RepeatEchoRequest, stream echo.EchoService_RepeatEchoServer) error {
+	for i := 0; i < 2; i++ {
+		if err := stream.Send(&echo.EchoResponse{Echo: req.Msg}); err != nil {
+			return err
+		}
+	}
+	return status.Error(codes.Internal, "boom")
+}
+
+func TestCallRepeatLogsMidStreamFailure(t *testing.T) {
+	var out syncBuffer
+	log.SetOutput(&out)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	srv, err := testutil.StartEchoServer(failingRepeatA{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	b := testutil.StartServiceB(srv.Conn, func(client echo.EchoServiceClient) http.Handler {
+		return http.HandlerFunc(newTestServiceB(client).callRepeat)
+	})
+	t.Cleanup(b.Close)
+
+	resp, err := http.Get(b.URL + "/call-repeat?msg=hi&count=5&format=ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "boom") {
+		t.Fatalf("status = %d, body %q; want 200 ending in an error record", resp.StatusCode, body)
+	}
+
+	logged := out.String()
+	if !strings.Contains(logged, "status=error code=Internal") || !strings.Contains(logged, "msgs_sent=2") {
+		t.Errorf("log = %q, want status=error code=Internal with msgs_sent=2", logged)
+	}
+	if strings.Contains(logged, "status=ok") {
+		t.Errorf("log = %q, reports a failed stream as ok", logged)
+	}
+}
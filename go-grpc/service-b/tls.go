@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"grpc-echo-json/tlsreload"
+)
+
+// clientCredentials returns the credentials B dials A with: TLS verified
+// against -tls-ca when set, plaintext otherwise. Over TLS, B also presents
+// the -tls-cert/-tls-key pair held by certs (when non-nil) as its client
+// certificate, so service A can require mutual TLS.
+func clientCredentials(caFile string, certs *tlsreload.Reloader) (credentials.TransportCredentials, error) {
+	if caFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+	cfg, err := buildClientTLS(caFile, certs)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// buildClientTLS trusts caFile for verifying service A and, when certs is
+// non-nil, presents its certificate as the client certificate.
+func buildClientTLS(caFile string, certs *tlsreload.Reloader) (*tls.Config, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	cfg := &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	if certs != nil {
+		cfg.GetClientCertificate = certs.GetClientCertificate
+	}
+	return cfg, nil
+}
+
+// servingTLS is the HTTPS config for B's own listener, serving the
+// certificate held by certs.
+func servingTLS(certs *tlsreload.Reloader) *tls.Config {
+	return &tls.Config{
+		GetCertificate: certs.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// validateServingTLS checks that B's certificate flags are set together.
+func validateServingTLS(certFile, keyFile string) error {
+	if (certFile == "") != (keyFile == "") {
+		return errors.New("-tls-cert and -tls-key must be set together")
+	}
+	return nil
+}
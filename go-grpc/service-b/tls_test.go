@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"grpc-echo-json/testutil"
+	"grpc-echo-json/tlsreload"
+)
+
+func TestBuildClientTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := testutil.NewCA("test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM, err := ca.Issue("service-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	caFile := write("ca.pem", ca.PEM)
+	certs, err := tlsreload.New(write("b.crt", certPEM), write("b.key", keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := buildClientTLS(caFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RootCAs == nil || cfg.GetClientCertificate != nil {
+		t.Errorf("without certs: RootCAs set = %t, client certificate = %t; want a CA and no certificate",
+			cfg.RootCAs != nil, cfg.GetClientCertificate != nil)
+	}
+
+	cfg, err = buildClientTLS(caFile, certs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.GetClientCertificate == nil {
+		t.Fatal("with certs: no client certificate for mutual TLS")
+	}
+	if cert, err := cfg.GetClientCertificate(nil); err != nil || len(cert.Certificate) == 0 {
+		t.Errorf("client certificate = %v, %v", cert, err)
+	}
+
+	if _, err := buildClientTLS(filepath.Join(dir, "missing.pem"), nil); err == nil {
+		t.Error("buildClientTLS accepted a missing CA file")
+	}
+	if _, err := buildClientTLS(write("junk.pem", []byte("not a certificate")), nil); err == nil {
+		t.Error("buildClientTLS accepted a CA file with no certificates")
+	}
+}
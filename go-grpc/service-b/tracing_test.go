@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"grpc-echo-json/echo"
+)
+
+func TestTraceSpansFromHTTPIntoGRPC(t *testing.T) {
+	echo.RegisterCodecs()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prop := propagation.TraceContext{}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(grpc.StatsHandler(otelgrpc.NewServerHandler(
+		otelgrpc.WithTracerProvider(tp), otelgrpc.WithPropagators(prop))))
+	echo.RegisterEchoServiceServer(s, fakeServiceA{})
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler(
+			otelgrpc.WithTracerProvider(tp), otelgrpc.WithPropagators(prop))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	b := newTestServiceB(echo.NewEchoServiceClient(conn))
+	h := otelhttp.NewHandler(http.HandlerFunc(b.callEcho), "B",
+		otelhttp.WithTracerProvider(tp), otelhttp.WithPropagators(prop),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.URL.Path }))
+	if rec := serve(h, http.MethodGet, "/call-echo?msg=traced"); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	// A ends its server span after sending the response, possibly after B
+	// has returned; stopping the server waits for that.
+	s.Stop()
+
+	var httpSpan, clientSpan, serverSpan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch {
+		case span.SpanKind() == trace.SpanKindClient:
+			clientSpan = span
+		case span.Name() == "/call-echo":
+			httpSpan = span
+		default:
+			serverSpan = span
+		}
+	}
+	if httpSpan == nil || clientSpan == nil || serverSpan == nil {
+		t.Fatalf("recorded spans %v, want B's HTTP span, B's gRPC client span and A's gRPC server span", spanNames(recorder.Ended()))
+	}
+
+	if clientSpan.Parent().SpanID() != httpSpan.SpanContext().SpanID() {
+		t.Errorf("gRPC client span's parent is %s, want the HTTP span %s", clientSpan.Parent().SpanID(), httpSpan.SpanContext().SpanID())
+	}
+	if serverSpan.Parent().SpanID() != clientSpan.SpanContext().SpanID() || !serverSpan.Parent().IsRemote() {
+		t.Errorf("A's span's parent is %s, want B's client span %s propagated over the wire", serverSpan.Parent().SpanID(), clientSpan.SpanContext().SpanID())
+	}
+	if id := httpSpan.SpanContext().TraceID(); clientSpan.SpanContext().TraceID() != id || serverSpan.SpanContext().TraceID() != id {
+		t.Error("spans belong to different traces")
+	}
+	if want := "echo.EchoService/Echo"; serverSpan.Name() != want {
+		t.Errorf("A's span is named %q, want %q", serverSpan.Name(), want)
+	}
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.SpanKind().String()+" "+s.Name())
+	}
+	return names
+}
@@ -0,0 +1,52 @@
+package testutil_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"grpc-echo-json/echo"
+	"grpc-echo-json/testutil"
+)
+
+// upperEcho answers Echo with its request's message; other methods panic.
+type upperEcho struct {
+	echo.EchoServiceServer
+}
+
+func (upperEcho) Echo(_ context.Context, in *echo.EchoRequest) (*echo.EchoResponse, error) {
+	return &echo.EchoResponse{Echo: "echo: " + in.Msg}, nil
+}
+
+func ExampleStartServiceB() {
+	a, err := testutil.StartEchoServer(upperEcho{})
+	if err != nil {
+		panic(err)
+	}
+	defer a.Close()
+
+	b := testutil.StartServiceB(a.Conn, func(client echo.EchoServiceClient) http.Handler {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/call-echo", func(w http.ResponseWriter, r *http.Request) {
+			resp, err := client.Echo(r.Context(), &echo.EchoRequest{Msg: r.URL.Query().Get("msg")})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"echo": resp.Echo})
+		})
+		return mux
+	})
+	defer b.Close()
+
+	resp, err := http.Get(b.URL + "/call-echo?msg=hi")
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	fmt.Print(resp.StatusCode, " ", string(body))
+	// Output: 200 {"echo":"echo: hi"}
+}
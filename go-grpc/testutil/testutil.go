@@ -0,0 +1,78 @@
+// Package testutil runs an EchoService implementation in-process, over an
+// in-memory bufconn listener, so callers can exercise it through a real gRPC
+// client without opening ports.
+//
+// Service A and service B are main packages and can't be imported, so the
+// server here is whatever echo.EchoServiceServer the caller passes in, and
+// StartServiceB serves whatever HTTP handler the caller builds on top of the
+// connection.
+package testutil
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"grpc-echo-json/echo"
+)
+
+const bufSize = 1 << 20
+
+// Server is an in-process EchoService reachable through Conn.
+type Server struct {
+	Conn *grpc.ClientConn
+
+	srv *grpc.Server
+	lis *bufconn.Listener
+}
+
+// StartEchoServer serves impl on a bufconn listener and returns a client
+// connection to it. serverOpts are passed to grpc.NewServer (e.g.
+// interceptors); the client uses the JSON codec, as service B does by
+// default. Call Close when done.
+func StartEchoServer(impl echo.EchoServiceServer, serverOpts ...grpc.ServerOption) (*Server, error) {
+	echo.RegisterCodecs()
+
+	lis := bufconn.Listen(bufSize)
+	srv := grpc.NewServer(serverOpts...)
+	echo.RegisterEchoServiceServer(srv, impl)
+	go func() { _ = srv.Serve(lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(echo.JSONCodecName)),
+	)
+	if err != nil {
+		srv.Stop()
+		return nil, err
+	}
+	return &Server{Conn: conn, srv: srv, lis: lis}, nil
+}
+
+// Client returns an EchoService client on the server's connection.
+func (s *Server) Client() echo.EchoServiceClient {
+	return echo.NewEchoServiceClient(s.Conn)
+}
+
+// Close closes the client connection and stops the server.
+func (s *Server) Close() {
+	_ = s.Conn.Close()
+	s.srv.Stop()
+	_ = s.lis.Close()
+}
+
+// StartServiceB serves the HTTP handler built by newHandler on an httptest
+// server. newHandler gets an EchoService client on conn, typically a
+// Server's Conn, so the handler proxies to the in-process service. Call
+// Close on the returned server when done.
+func StartServiceB(conn grpc.ClientConnInterface, newHandler func(echo.EchoServiceClient) http.Handler) *httptest.Server {
+	return httptest.NewServer(newHandler(echo.NewEchoServiceClient(conn)))
+}
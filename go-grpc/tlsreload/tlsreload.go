@@ -0,0 +1,77 @@
+// Package tlsreload keeps a TLS certificate loaded from disk and swaps in a
+// fresh copy on demand, so both services can rotate their certificates on
+// SIGHUP without restarting.
+package tlsreload
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// Reloader serves the most recently loaded key pair from certFile and
+// keyFile. Its Get methods plug into tls.Config, so new handshakes pick up
+// a reloaded certificate while established connections keep the one they
+// negotiated.
+type Reloader struct {
+	certFile, keyFile string
+	cert              atomic.Pointer[tls.Certificate]
+}
+
+// New loads the key pair once, failing if it can't be read.
+func New(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the key pair again. On error the previous certificate stays
+// in use.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+	r.cert.Store(&cert)
+	return nil
+}
+
+// GetCertificate is a tls.Config.GetCertificate for servers.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// GetClientCertificate is a tls.Config.GetClientCertificate for clients
+// presenting a certificate for mutual TLS.
+func (r *Reloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// ReloadOnSIGHUP reloads the key pair every time the process receives
+// SIGHUP, until ctx is done, logging each outcome under service's name. A
+// failed reload is logged and the old certificate kept, so a half-written
+// file can't take the service down.
+func (r *Reloader) ReloadOnSIGHUP(ctx context.Context, service string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := r.Reload(); err != nil {
+				log.Printf("service=%s tls_reload=failed cert=%s error=%q", service, r.certFile, err.Error())
+				continue
+			}
+			log.Printf("service=%s tls_reload=ok cert=%s", service, r.certFile)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package tlsreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"grpc-echo-json/testutil"
+)
+
+// writeKeyPair issues a certificate for name from ca and writes it and its
+// key to certFile and keyFile, replacing whatever was there.
+func writeKeyPair(t *testing.T, ca *testutil.CA, name, certFile, keyFile string) {
+	t.Helper()
+	certPEM, keyPEM, err := ca.Issue(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve accepts TLS connections on a local port with r's certificate until
+// the test ends and returns the address.
+func serve(t *testing.T, r *Reloader) string {
+	t.Helper()
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: r.GetCertificate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+	return lis.Addr().String()
+}
+
+// servedName dials addr and returns the common name of the certificate it
+// presents.
+func servedName(t *testing.T, addr string, roots *x509.CertPool) string {
+	t.Helper()
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 2 * time.Second}, "tcp", addr, &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func TestReloadOnSIGHUPServesNewCertificate(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	// Keep SIGHUP from killing the test binary before the reloader has
+	// subscribed to it.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ca, err := testutil.NewCA("test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.PEM)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeKeyPair(t, ca, "old", certFile, keyFile)
+
+	r, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serve(t, r)
+	if got := servedName(t, addr, roots); got != "old" {
+		t.Fatalf("served %q before rotation, want old", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.ReloadOnSIGHUP(ctx, "test")
+
+	writeKeyPair(t, ca, "new", certFile, keyFile)
+	if got := servedName(t, addr, roots); got != "old" {
+		t.Fatalf("served %q after rewriting the files but before SIGHUP, want old", got)
+	}
+	// The reloader may not be listening yet, so signal until it reloads.
+	deadline := time.Now().Add(5 * time.Second)
+	for servedName(t, addr, roots) != "new" {
+		if time.Now().After(deadline) {
+			t.Fatal("still serving the old certificate after SIGHUP")
+		}
+		if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestReloadKeepsCertificateOnError(t *testing.T) {
+	ca, err := testutil.NewCA("test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeKeyPair(t, ca, "old", certFile, keyFile)
+	r, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := r.GetCertificate(nil)
+
+	// A half-written rotation: the certificate file is truncated.
+	if err := os.WriteFile(certFile, []byte("-----BEGIN CERT"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("Reload of a truncated certificate succeeded")
+	}
+	if after, _ := r.GetCertificate(nil); after != before {
+		t.Error("a failed Reload replaced the certificate")
+	}
+	if client, _ := r.GetClientCertificate(nil); client != before {
+		t.Error("GetClientCertificate doesn't return the loaded certificate")
+	}
+}
+
+func TestNewFailsOnMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := New(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key")); err == nil {
+		t.Error("New succeeded without a key pair on disk")
+	}
+}
@@ -0,0 +1,13 @@
+// Package version reports which build of a service is running. The values
+// are set at build time, e.g.
+//
+//	go build -ldflags "-X grpc-echo-json/version.Version=v1.2.0 \
+//	  -X grpc-echo-json/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X grpc-echo-json/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./service-b
+package version
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)